@@ -130,14 +130,20 @@ func ZGrab2Main() {
 			f, _ := fl.(zgrab2.ScanFlags)
 			mod := zgrab2.GetModule(modTypes[i])
 			s := mod.NewScanner()
-			s.Init(f)
+			if err := s.Init(f); err != nil {
+				log.Fatalf("could not initialize %s: %s", modTypes[i], err)
+			}
 			zgrab2.RegisterScan(s.GetName(), s)
+			zgrab2.SetScanRetries(s, f)
 		}
 	} else {
 		mod := zgrab2.GetModule(moduleType)
 		s := mod.NewScanner()
-		s.Init(flag)
+		if err := s.Init(flag); err != nil {
+			log.Fatalf("could not initialize %s: %s", moduleType, err)
+		}
 		zgrab2.RegisterScan(moduleType, s)
+		zgrab2.SetScanRetries(s, flag)
 	}
 	wg := sync.WaitGroup{}
 	monitor := zgrab2.MakeMonitor(1, &wg)
@@ -146,16 +152,21 @@ func ZGrab2Main() {
 	}
 	start := time.Now()
 	log.Infof("started grab at %s", start.Format(time.RFC3339))
+	stopProgress := zgrab2.StartProgress(monitor)
 	zgrab2.Process(monitor)
 	end := time.Now()
 	log.Infof("finished grab at %s", end.Format(time.RFC3339))
 	monitor.Stop()
 	wg.Wait()
+	stopProgress()
 	s := Summary{
 		StatusesPerModule: monitor.GetStatuses(),
 		StartTime:         start.Format(time.RFC3339),
 		EndTime:           end.Format(time.RFC3339),
 		Duration:          end.Sub(start).String(),
+		Sample:            zgrab2.GetSampleStats(),
+		ClientIdentities:  zgrab2.GetClientIdentities(),
+		ScanSummaries:     zgrab2.GetScanSummaries(),
 	}
 	enc := json.NewEncoder(zgrab2.GetMetaFile())
 	if err := enc.Encode(&s); err != nil {
@@ -8,4 +8,7 @@ type Summary struct {
 	StartTime         string                   `json:"start"`
 	EndTime           string                   `json:"end"`
 	Duration          string                   `json:"duration"`
+	Sample            *zgrab2.SampleStats      `json:"sample,omitempty"`
+	ClientIdentities  map[string]interface{}   `json:"client_identities,omitempty"`
+	ScanSummaries     map[string]interface{}   `json:"scan_summaries,omitempty"`
 }
@@ -18,6 +18,7 @@ type Config struct {
 	MetaFileName       string          `short:"m" long:"metadata-file" default:"-" description:"Metadata filename, use - for stderr"`
 	LogFileName        string          `short:"l" long:"log-file" default:"-" description:"Log filename, use - for stderr"`
 	Senders            int             `short:"s" long:"senders" default:"1000" description:"Number of send goroutines to use"`
+	FDHeadroom         int             `long:"fd-headroom" default:"64" description:"File descriptors to leave free when capping --senders to the open file limit"`
 	Debug              bool            `long:"debug" description:"Include debug fields in the output."`
 	Flush              bool            `long:"flush" description:"Flush after each line of output."`
 	GOMAXPROCS         int             `long:"gomaxprocs" default:"0" description:"Set GOMAXPROCS"`
@@ -25,8 +26,21 @@ type Config struct {
 	ReadLimitPerHost   int             `long:"read-limit-per-host" default:"96" description:"Maximum total kilobytes to read for a single host (default 96kb)"`
 	Prometheus         string          `long:"prometheus" description:"Address to use for Prometheus server (e.g. localhost:8080). If empty, Prometheus is disabled."`
 	CustomDNS          string          `long:"dns" description:"Address of a custom DNS server for lookups. Default port is 53."`
+	OutputFormat       string          `long:"output-format" default:"json" choice:"json" choice:"es-bulk" description:"Output format: one JSON object per line, or Elasticsearch _bulk NDJSON"`
+	ESIndex            string          `long:"es-index" default:"zgrab2" description:"Index name used in es-bulk action lines"`
+	ESIDTemplate       string          `long:"es-id-template" default:"{host}:{port}" description:"Document _id template for es-bulk output; {ip}, {domain}, {host} and {port} are substituted"`
+	SampleLimit        int             `long:"sample-limit" default:"0" description:"Stop emitting results once this many successful results have been emitted (0 = no limit)"`
+	SampleRate         float64         `long:"sample-rate" default:"1" description:"Probability with which each result is emitted"`
+	SampleStop         bool            `long:"sample-stop" description:"Stop scanning, not just emitting, once --sample-limit is reached"`
+	Progress           bool            `long:"progress" description:"Print a periodically-updating progress line to stderr"`
+	RescanFileName     string          `long:"rescan-file" description:"Instead of --input-file, rescan the targets in this previous output file that failed with one of --rescan-statuses"`
+	RescanStatuses     string          `long:"rescan-statuses" default:"connection-timeout,io-timeout" description:"Comma-separated statuses that --rescan-file treats as transient failures"`
+	ScanSeq            bool            `long:"seq" description:"Add a scan_seq field numbering results in the order they are output"`
+	UnixSocket         string          `long:"unix-socket" description:"Also stream results to a local consumer connected to a Unix domain socket at this path"`
+	UnixSocketPolicy   string          `long:"unix-socket-policy" default:"drop" choice:"drop" choice:"block" description:"What to do with results when no --unix-socket consumer keeps up and the buffer is full"`
 	Multiple           MultipleCommand `command:"multiple" description:"Multiple module actions"`
 	inputFile          *os.File
+	rescanFile         *os.File
 	outputFile         *os.File
 	metaFile           *os.File
 	logFile            *os.File
@@ -74,6 +88,14 @@ func validateFrameworkConfiguration() {
 		}
 	}
 
+	if config.RescanFileName != "" {
+		var err error
+		if config.rescanFile, err = os.Open(config.RescanFileName); err != nil {
+			log.Fatal(err)
+		}
+		SetInputFunc(InputTargetsRescan)
+	}
+
 	if config.OutputFileName == "-" {
 		config.outputFile = os.Stdout
 	} else {
@@ -83,6 +105,13 @@ func validateFrameworkConfiguration() {
 		}
 	}
 	outputFunc := OutputResultsWriterFunc(config.outputFile)
+	if config.UnixSocket != "" {
+		sock, err := newUnixSocketOutput(config.UnixSocket, config.UnixSocketPolicy == UnixSocketPolicyBlock)
+		if err != nil {
+			log.Fatalf("could not listen on unix socket: %s", err)
+		}
+		outputFunc = teeUnixSocket(outputFunc, sock)
+	}
 	SetOutputFunc(outputFunc)
 
 	if config.MetaFileName == "-" {
@@ -114,6 +143,15 @@ func validateFrameworkConfiguration() {
 	if config.Senders <= 0 {
 		log.Fatalf("need at least one sender, given %d", config.Senders)
 	}
+	if config.FDHeadroom < 0 {
+		log.Fatalf("fd-headroom must be non-negative, given %d", config.FDHeadroom)
+	}
+	if limit, ok := openFileLimit(); ok {
+		if senders := capSenders(config.Senders, limit, config.FDHeadroom); senders != config.Senders {
+			log.Warnf("%d senders exceeds RLIMIT_NOFILE=%d less %d descriptors of headroom; using %d senders", config.Senders, limit, config.FDHeadroom, senders)
+			config.Senders = senders
+		}
+	}
 
 	// validate connections per host
 	if config.ConnectionsPerHost <= 0 {
@@ -130,6 +168,20 @@ func validateFrameworkConfiguration() {
 		DefaultBytesReadLimit = config.ReadLimitPerHost * 1024
 	}
 
+	// Validate sampling
+	if config.SampleLimit < 0 {
+		log.Fatalf("sample-limit must be non-negative, given %d", config.SampleLimit)
+	}
+	if config.SampleRate <= 0 || config.SampleRate > 1 {
+		log.Fatalf("sample-rate must be in the range (0,1], given %v", config.SampleRate)
+	}
+	resultSampler = newSampler(config.SampleLimit, config.SampleRate)
+
+	// Validate Elasticsearch bulk output
+	if config.OutputFormat == OutputFormatESBulk && config.ESIndex == "" {
+		log.Fatalf("es-bulk output requires an index name")
+	}
+
 	// Validate custom DNS
 	if config.CustomDNS != "" {
 		var err error
@@ -144,6 +196,11 @@ func GetMetaFile() *os.File {
 	return config.metaFile
 }
 
+// GetSenders returns the number of send goroutines the scan will use.
+func GetSenders() int {
+	return config.Senders
+}
+
 func includeDebugOutput() bool {
 	return config.Debug
 }
@@ -0,0 +1,15 @@
+package zgrab2
+
+// capSenders returns the number of senders to use so that one connection per
+// sender, plus headroom for log/output files and the like, fits within the
+// open file limit. It always allows at least one sender.
+func capSenders(senders int, limit uint64, headroom int) int {
+	max := int64(limit) - int64(headroom)
+	if max < 1 {
+		max = 1
+	}
+	if int64(senders) <= max {
+		return senders
+	}
+	return int(max)
+}
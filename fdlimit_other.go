@@ -0,0 +1,9 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package zgrab2
+
+// openFileLimit is not supported on this platform.
+func openFileLimit() (limit uint64, ok bool) {
+	return 0, false
+}
@@ -0,0 +1,24 @@
+package zgrab2
+
+import "testing"
+
+func TestCapSenders(t *testing.T) {
+	tests := []struct {
+		senders  int
+		limit    uint64
+		headroom int
+		want     int
+	}{
+		{1000, 1024, 64, 960},
+		{500, 1024, 64, 500},
+		{960, 1024, 64, 960},
+		{1000, 1024, 0, 1000},
+		{1000, 32, 64, 1},
+		{1000, 64, 64, 1},
+	}
+	for _, test := range tests {
+		if got := capSenders(test.senders, test.limit, test.headroom); got != test.want {
+			t.Errorf("capSenders(%d, %d, %d) = %d, wanted %d", test.senders, test.limit, test.headroom, got, test.want)
+		}
+	}
+}
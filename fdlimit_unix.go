@@ -0,0 +1,22 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package zgrab2
+
+import (
+	"math"
+	"syscall"
+)
+
+// openFileLimit returns the soft RLIMIT_NOFILE of the process. ok is false if
+// the limit could not be read or is effectively unlimited.
+func openFileLimit() (limit uint64, ok bool) {
+	var rlim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlim); err != nil {
+		return 0, false
+	}
+	if uint64(rlim.Cur) >= math.MaxInt32 {
+		return 0, false
+	}
+	return uint64(rlim.Cur), true
+}
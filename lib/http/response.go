@@ -70,6 +70,11 @@ type Response struct {
 	BodyHash string `json:"body_hash,omitempty"`
 	// Number of bytes read from the server and encoded into BodyText
 	BodyTextLength int64 `json:"body_length,omitempty"`
+	// BodyTruncated is set when the body was cut short at the scanner's
+	// size limit.
+	BodyTruncated bool `json:"body_truncated,omitempty"`
+	// Title is the whitespace-collapsed text of an HTML body's first <title>.
+	Title string `json:"title,omitempty"`
 
 	// ContentLength records the length of the associated content. The
 	// value -1 indicates that the length is unknown. Unless Request.Method
@@ -95,6 +100,13 @@ type Response struct {
 	// the server, set Transport.DisableCompression to true.
 	Uncompressed bool `json:"-"`
 
+	// ContentEncoding is the deleted "Content-Encoding" of a response the
+	// http package decompressed, and CompressedLength the number of
+	// compressed bytes read from the server to produce what was read of
+	// Body.
+	ContentEncoding  string `json:"content_encoding,omitempty"`
+	CompressedLength int64  `json:"compressed_length,omitempty"`
+
 	// Trailer maps trailer keys to values in the same
 	// format as Header.
 	//
@@ -12,7 +12,9 @@ package http
 import (
 	"bufio"
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
+	"compress/zlib"
 	"container/list"
 	"context"
 	"errors"
@@ -247,6 +249,19 @@ func (t *Transport) onceSetNextProtoDefaults() {
 	}
 }
 
+// ConfigureHTTP2 enables HTTP/2 for connections whose TLS handshake
+// negotiated "h2". onceSetNextProtoDefaults leaves HTTP/2 off when DialTLS
+// is set, so callers with their own TLS dialer opt in here; the dialer must
+// offer "h2" in its NextProtos itself.
+func (t *Transport) ConfigureHTTP2() error {
+	t2, err := http2configureTransport(t)
+	if err != nil {
+		return err
+	}
+	t.h2transport = t2
+	return nil
+}
+
 // ProxyFromEnvironment returns the URL of the proxy to use for a
 // given request, as indicated by the environment variables
 // HTTP_PROXY, HTTPS_PROXY and NO_PROXY (or the lowercase versions
@@ -1001,7 +1016,7 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (*persistCon
 		if pconn.conn == nil {
 			return nil, errors.New("net/http: Transport.DialTLS returned (nil, nil)")
 		}
-		if tc, ok := pconn.conn.(*tls.Conn); ok {
+		if tc, ok := underlyingTLSConn(pconn.conn); ok {
 			// Handshake here, in case DialTLS didn't. TLSNextProto below
 			// depends on it for knowing the connection state.
 			if trace != nil && trace.TLSHandshakeStart != nil {
@@ -1128,7 +1143,10 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (*persistCon
 
 	if s := pconn.tlsState; s != nil && s.NegotiatedProtocolIsMutual && s.NegotiatedProtocol != "" {
 		if next, ok := t.TLSNextProto[s.NegotiatedProtocol]; ok {
-			return &persistConn{alt: next(cm.targetAddr, pconn.conn.(*tls.Conn))}, nil
+			tc, _ := underlyingTLSConn(pconn.conn)
+			// conn is kept so that RoundTrip can still fill in the TLSLog;
+			// the alternate protocol owns closing it.
+			return &persistConn{alt: next(cm.targetAddr, tc), conn: pconn.conn}, nil
 		}
 	}
 
@@ -1140,6 +1158,18 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (*persistCon
 	return pconn, nil
 }
 
+// underlyingTLSConn returns the *tls.Conn behind conn, unwrapping the
+// *zgrab2.TLSConnection that zgrab2's TLS dialers return.
+func underlyingTLSConn(conn net.Conn) (*tls.Conn, bool) {
+	switch c := conn.(type) {
+	case *tls.Conn:
+		return c, true
+	case *zgrab2.TLSConnection:
+		return &c.Conn, true
+	}
+	return nil, false
+}
+
 // The underlying br Reader is bufio, so it will perform read-ahead.
 // The underlying tb is a bytes buffer, that acts as a tee, receiving
 // the raw bytes for reads against the io.Reader backing br.
@@ -1639,12 +1669,23 @@ func (pc *persistConn) readLoop() {
 		}
 
 		resp.Body = body
-		if rc.addedGzip && resp.Header.Get("Content-Encoding") == "gzip" {
-			resp.Body = &gzipReader{body: body}
-			resp.Header.Del("Content-Encoding")
-			resp.Header.Del("Content-Length")
-			resp.ContentLength = -1
-			resp.Uncompressed = true
+		if rc.addedGzip {
+			encoding := resp.Header.Get("Content-Encoding")
+			switch encoding {
+			case "gzip":
+				resp.Body = &gzipReader{body: body, counted: &resp.CompressedLength}
+			case "deflate":
+				resp.Body = &deflateReader{body: body, counted: &resp.CompressedLength}
+			default:
+				encoding = ""
+			}
+			if encoding != "" {
+				resp.ContentEncoding = encoding
+				resp.Header.Del("Content-Encoding")
+				resp.Header.Del("Content-Length")
+				resp.ContentLength = -1
+				resp.Uncompressed = true
+			}
 		}
 
 		select {
@@ -1906,7 +1947,8 @@ func (pc *persistConn) roundTrip(req *transportRequest) (resp *Response, err err
 		req.Header.Get("Range") == "" &&
 		req.Method != "HEAD" {
 		// Request gzip only, not deflate. Deflate is ambiguous and
-		// not as universally supported anyway.
+		// not as universally supported anyway, though deflateReader
+		// decodes it for servers that send it regardless.
 		// See: http://www.gzip.org/zlib/zlib_faq.html#faq38
 		//
 		// Note that we don't request this for HEAD requests,
@@ -2132,15 +2174,16 @@ func (es *bodyEOFSignal) condfn(err error) error {
 // gzipReader wraps a response body so it can lazily
 // call gzip.NewReader on the first call to Read
 type gzipReader struct {
-	body *bodyEOFSignal // underlying HTTP/1 response body framing
-	zr   *gzip.Reader   // lazily-initialized gzip reader
-	zerr error          // any error from gzip.NewReader; sticky
+	body    *bodyEOFSignal // underlying HTTP/1 response body framing
+	counted *int64         // incremented by the compressed bytes read
+	zr      *gzip.Reader   // lazily-initialized gzip reader
+	zerr    error          // any error from gzip.NewReader; sticky
 }
 
 func (gz *gzipReader) Read(p []byte) (n int, err error) {
 	if gz.zr == nil {
 		if gz.zerr == nil {
-			gz.zr, gz.zerr = gzip.NewReader(gz.body)
+			gz.zr, gz.zerr = gzip.NewReader(countingReader{gz.body, gz.counted})
 		}
 		if gz.zerr != nil {
 			return 0, gz.zerr
@@ -2163,6 +2206,58 @@ func (gz *gzipReader) Close() error {
 	return gz.body.Close()
 }
 
+// deflateReader is gzipReader for "Content-Encoding: deflate", which
+// should be a zlib stream but is raw deflate from some servers.
+type deflateReader struct {
+	body    *bodyEOFSignal
+	counted *int64
+	zr      io.ReadCloser
+	zerr    error
+}
+
+func (df *deflateReader) Read(p []byte) (n int, err error) {
+	if df.zr == nil && df.zerr == nil {
+		br := bufio.NewReader(countingReader{df.body, df.counted})
+		// A zlib header is a CMF byte with compression method 8 and a
+		// FLG byte that makes the pair a multiple of 31.
+		if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+			df.zr, df.zerr = zlib.NewReader(br)
+		} else {
+			df.zr = flate.NewReader(br)
+		}
+	}
+	if df.zerr != nil {
+		return 0, df.zerr
+	}
+
+	df.body.mu.Lock()
+	if df.body.closed {
+		err = errReadOnClosedResBody
+	}
+	df.body.mu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+	return df.zr.Read(p)
+}
+
+func (df *deflateReader) Close() error {
+	return df.body.Close()
+}
+
+// countingReader adds the number of bytes read from r to *n.
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
+
 type readerAndCloser struct {
 	io.Reader
 	io.Closer
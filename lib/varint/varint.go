@@ -0,0 +1,61 @@
+// Package varint reads and writes the VarInts of length-prefixed protocols
+// such as Minecraft's: 32-bit two's complement integers stored seven bits at
+// a time, least significant group first, with the high bit of each byte set
+// when another byte follows.
+package varint
+
+import (
+	"errors"
+	"io"
+)
+
+// MaxLen is the length of the longest VarInt encoding.
+const MaxLen = 5
+
+var (
+	// ErrTooLong is returned by Read for a VarInt that continues past
+	// MaxLen bytes.
+	ErrTooLong = errors.New("varint too long")
+
+	// ErrOverflow is returned by Read for a VarInt whose last byte sets
+	// bits beyond the 32 a VarInt holds.
+	ErrOverflow = errors.New("varint overflows 32 bits")
+)
+
+// Append appends v, truncated to 32 bits, to b as a VarInt.
+func Append(b []byte, v int) []byte {
+	u := uint32(v)
+	for u >= 0x80 {
+		b = append(b, byte(u)|0x80)
+		u >>= 7
+	}
+	return append(b, byte(u))
+}
+
+// Write writes v to w as a VarInt, returning the number of bytes written.
+func Write(w io.Writer, v int) (int, error) {
+	var buf [MaxLen]byte
+	return w.Write(Append(buf[:0], v))
+}
+
+// Read reads a VarInt from r, returning its value and the number of bytes
+// it took. Overlong encodings, such as 0x80 0x00 for 0, are accepted as
+// long as they fit in MaxLen bytes. An error from r is returned as is, so
+// a VarInt cut short reports io.EOF.
+func Read(r io.Reader) (int, int, error) {
+	var result uint32
+	var b [1]byte
+	for n := 1; n <= MaxLen; n++ {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, n - 1, err
+		}
+		if n == MaxLen && b[0]&0x70 != 0 {
+			return 0, n, ErrOverflow
+		}
+		result |= uint32(b[0]&0x7f) << (7 * (n - 1))
+		if b[0]&0x80 == 0 {
+			return int(int32(result)), n, nil
+		}
+	}
+	return 0, MaxLen, ErrTooLong
+}
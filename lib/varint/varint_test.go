@@ -0,0 +1,61 @@
+package varint
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+var encodings = map[int][]byte{
+	0:           {0x00},
+	1:           {0x01},
+	127:         {0x7f},
+	128:         {0x80, 0x01},
+	255:         {0xff, 0x01},
+	25565:       {0xdd, 0xc7, 0x01},
+	2097151:     {0xff, 0xff, 0x7f},
+	2147483647:  {0xff, 0xff, 0xff, 0xff, 0x07},
+	-1:          {0xff, 0xff, 0xff, 0xff, 0x0f},
+	-2147483648: {0x80, 0x80, 0x80, 0x80, 0x08},
+}
+
+func TestWrite(t *testing.T) {
+	for v, want := range encodings {
+		var buf bytes.Buffer
+		if n, err := Write(&buf, v); err != nil || n != len(want) || !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("Write(%d) = %x, %d, %v; wanted %x", v, buf.Bytes(), n, err, want)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	for want, encoding := range encodings {
+		// A trailing byte checks that Read stops at the end of the VarInt.
+		r := bytes.NewReader(append(append([]byte(nil), encoding...), 0x2a))
+		if v, n, err := Read(r); err != nil || v != want || n != len(encoding) {
+			t.Errorf("Read(%x) = %d, %d, %v; wanted %d", encoding, v, n, err, want)
+		}
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	tests := map[string]struct {
+		encoding []byte
+		want     int
+		n        int
+		err      error
+	}{
+		"overlong 0":   {encoding: []byte{0x80, 0x00}, n: 2},
+		"overlong 127": {encoding: []byte{0xff, 0x80, 0x80, 0x80, 0x00}, want: 127, n: 5},
+		"too long":     {encoding: []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x00}, n: 5, err: ErrTooLong},
+		"overflow":     {encoding: []byte{0xff, 0xff, 0xff, 0xff, 0x1f}, n: 5, err: ErrOverflow},
+		"empty":        {err: io.EOF},
+		"cut short":    {encoding: []byte{0x80, 0x80}, n: 2, err: io.EOF},
+	}
+	for name, test := range tests {
+		v, n, err := Read(bytes.NewReader(test.encoding))
+		if v != test.want || n != test.n || err != test.err {
+			t.Errorf("%s: got %d, %d, %v; wanted %d, %d, %v", name, v, n, err, test.want, test.n, test.err)
+		}
+	}
+}
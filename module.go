@@ -36,6 +36,10 @@ type ScanResponse struct {
 	Result    interface{} `json:"result,omitempty"`
 	Timestamp string      `json:"timestamp,omitempty"`
 	Error     *string     `json:"error,omitempty"`
+
+	// Attempts is the number of times the scan was run, set when the
+	// scanner has --retries.
+	Attempts int `json:"attempts,omitempty"`
 }
 
 // ScanModule is an interface which represents a module that the framework can
@@ -54,6 +58,25 @@ type ScanModule interface {
 	Description() string
 }
 
+// Plugin is a ScanModule that also describes the command it is run with, so
+// that it can register itself with RegisterPlugin from its package's init()
+// function. A plugin is compiled in by importing its package, e.g. with a
+// blank import in the binary's main package; nothing in the core module list
+// needs to change.
+type Plugin interface {
+	ScanModule
+
+	// Command returns the name of the module's command, which is also the
+	// default scan name.
+	Command() string
+
+	// ShortDescription returns a one-line description for the command list.
+	ShortDescription() string
+
+	// DefaultPort returns the default value of the module's --port flag.
+	DefaultPort() int
+}
+
 // ScanFlags is an interface which must be implemented by all types sent to
 // the flag parser
 type ScanFlags interface {
@@ -72,6 +95,7 @@ type BaseFlags struct {
 	Timeout        time.Duration `short:"t" long:"timeout" description:"Set connection timeout (0 = no timeout)" default:"10s"`
 	Trigger        string        `short:"g" long:"trigger" description:"Invoke only on targets with specified tag"`
 	BytesReadLimit int           `short:"m" long:"maxbytes" description:"Maximum byte read limit per scan (0 = defaults)"`
+	Retries        int           `long:"retries" default:"0" description:"Retry a scan up to this many times, with backoff, after a connection reset or timeout"`
 }
 
 // UDPFlags contains the common options used for all UDP scans
@@ -85,6 +109,11 @@ func (b *BaseFlags) GetName() string {
 	return b.Name
 }
 
+// GetRetries returns the number of times a failed scan may be retried
+func (b *BaseFlags) GetRetries() int {
+	return b.Retries
+}
+
 // GetModule returns the registered module that corresponds to the given name
 // or nil otherwise
 func GetModule(name string) ScanModule {
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"math/bits"
+	"net/url"
+	"strings"
+
+	"github.com/zmap/zgrab2/lib/http"
+)
+
+// Favicon is the icon fetched with --favicon.
+type Favicon struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+
+	// MMH3 is the Shodan-style favicon hash: the 32-bit MurmurHash3 of the
+	// icon's base64 encoding, wrapped at 76 characters.
+	MMH3      int32                `json:"mmh3"`
+	Size      int                  `json:"size"`
+	SHA256    http.PageFingerprint `json:"sha256,omitempty"`
+	Truncated bool                 `json:"truncated,omitempty"`
+}
+
+// grabFavicon fetches FaviconPath from the host of the final response. Its
+// redirects are not followed, and a failed fetch only leaves the favicon
+// out of the results.
+func (scan *scan) grabFavicon(final *url.URL) *Favicon {
+	ref, err := url.Parse(scan.scanner.config.FaviconPath)
+	if err != nil {
+		return nil
+	}
+	request, err := http.NewRequest("GET", final.ResolveReference(ref).String(), nil)
+	if err != nil {
+		return nil
+	}
+	request.Header.Set("Accept", "*/*")
+	client := *scan.client
+	client.CheckRedirect = func(*http.Request, *http.Response, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	favicon := &Favicon{URL: request.URL.String(), StatusCode: resp.StatusCode}
+	buf, _ := scan.readBody(resp)
+	favicon.Truncated = resp.BodyTruncated
+	if buf.Len() > 0 {
+		favicon.Size = buf.Len()
+		favicon.MMH3 = faviconHash(buf.Bytes())
+		sum := sha256.Sum256(buf.Bytes())
+		favicon.SHA256 = sum[:]
+	}
+	return favicon
+}
+
+// faviconHash returns the MurmurHash3 of icon's base64 encoding, with a
+// newline after every 76 characters and at the end, as Python's
+// base64.encodebytes writes it.
+func faviconHash(icon []byte) int32 {
+	encoded := base64.StdEncoding.EncodeToString(icon)
+	var b strings.Builder
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76])
+		b.WriteByte('\n')
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded)
+	b.WriteByte('\n')
+	return int32(murmur3([]byte(b.String())))
+}
+
+// murmur3 is the 32-bit MurmurHash3 of data with a seed of 0.
+func murmur3(data []byte) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	var h uint32
+	n := len(data)
+	for ; len(data) >= 4; data = data[4:] {
+		k := binary.LittleEndian.Uint32(data)
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+	var k uint32
+	switch len(data) {
+	case 3:
+		k ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(data[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+	h ^= uint32(n)
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/http"
+)
+
+func TestMurmur3(t *testing.T) {
+	for data, want := range map[string]int32{"": 0, "foo": -156908512, "hello": 613153351} {
+		if got := int32(murmur3([]byte(data))); got != want {
+			t.Errorf("murmur3(%q) = %d, wanted %d", data, got, want)
+		}
+	}
+}
+
+func TestFavicon(t *testing.T) {
+	// 768 bytes encode to 1024 base64 characters: several wrapped lines.
+	icon := make([]byte, 0, 768)
+	for i := 0; i < 768; i++ {
+		icon = append(icon, byte(i))
+	}
+	requested := make(chan string, 2)
+	port := serveHTTP(t, func(conn net.Conn, request *http.Request) {
+		requested <- request.URL.Path
+		if request.URL.Path != "/static/icon.png" {
+			conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"))
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: " + strconv.Itoa(len(icon)) + "\r\n\r\n"))
+		conn.Write(icon)
+	})
+	scanner := newTestScanner(t, port, func(flags *Flags) {
+		flags.Favicon = true
+		flags.FaviconPath = "/static/icon.png"
+	})
+	status, res, err := scanner.Scan(localTarget())
+	if status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("got %s, %v", status, err)
+	}
+	if first, second := <-requested, <-requested; first != "/" || second != "/static/icon.png" {
+		t.Errorf("requested %s then %s", first, second)
+	}
+	results := res.(*Results)
+	favicon := results.Favicon
+	if favicon == nil || favicon.StatusCode != 200 || favicon.Size != 768 || favicon.MMH3 != 1836528006 || len(favicon.SHA256) != 32 {
+		t.Errorf("got favicon %+v", favicon)
+	}
+	if len(results.RedirectResponseChain) != 0 || results.Response.BodyText != "ok" {
+		t.Errorf("the favicon request changed the main results: %+v", results)
+	}
+}
@@ -423,6 +423,7 @@ func TestReadLimitHTTP(t *testing.T) {
 	if zgrab2.DefaultReadLimitExceededAction != zgrab2.ReadLimitExceededActionTruncate {
 		t.Logf("Warning: DefaultReadLimitExceededAction is %s, not %s", zgrab2.DefaultReadLimitExceededAction, zgrab2.ReadLimitExceededActionTruncate)
 	}
+	defer func(limit int) { zgrab2.DefaultBytesReadLimit = limit }(zgrab2.DefaultBytesReadLimit)
 	for testName, cfg := range readLimitTestConfigs {
 		cfg.runTest(t, testName)
 	}
@@ -7,10 +7,12 @@
 package http
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/sha1"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/csv"
 	"encoding/hex"
 	"errors"
@@ -18,6 +20,7 @@ import (
 	"io"
 	"net"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -26,7 +29,11 @@ import (
 	"github.com/zmap/zcrypto/tls"
 	"github.com/zmap/zgrab2"
 	"github.com/zmap/zgrab2/lib/http"
+	"github.com/zmap/zgrab2/lib/http/cookiejar"
+	"github.com/zmap/zgrab2/lib/http/httptrace"
+	"golang.org/x/net/html"
 	"golang.org/x/net/html/charset"
+	"golang.org/x/net/publicsuffix"
 )
 
 var (
@@ -37,16 +44,27 @@ var (
 	// ErrTooManyRedirects is returned when the number of HTTP redirects exceeds
 	// MaxRedirects.
 	ErrTooManyRedirects = errors.New("Too many redirects")
+
+	// ErrRedirLoop is returned when an HTTP redirect points back to a URL
+	// already requested in the redirect chain.
+	ErrRedirLoop = errors.New("Redirect loop")
+
+	// ErrRedirSchemeChange is returned when an HTTP redirect changes the
+	// scheme (http to https or back) and RedirectsSameScheme is set.
+	ErrRedirSchemeChange = errors.New("Redirect changes scheme")
+
+	// ErrProxyRefused is returned when the proxy does not answer the CONNECT
+	// sent with ProxyDomain with a 2xx status.
+	ErrProxyRefused = errors.New("Proxy refused CONNECT")
 )
 
 // Flags holds the command-line configuration for the HTTP scan module.
 // Populated by the framework.
-//
-// TODO: Custom headers?
 type Flags struct {
 	zgrab2.BaseFlags
 	zgrab2.TLSFlags
 	Method          string `long:"method" default:"GET" description:"Set HTTP request method type"`
+	CustomMethod    bool   `long:"custom-method" description:"Allow a --method other than GET, HEAD, POST, PUT, DELETE, OPTIONS, PATCH and TRACE"`
 	Endpoint        string `long:"endpoint" default:"/" description:"Send an HTTP request to an endpoint"`
 	FailHTTPToHTTPS bool   `long:"fail-http-to-https" description:"Trigger retry-https logic on known HTTP/400 protocol mismatch responses"`
 	UserAgent       string `long:"user-agent" default:"Mozilla/5.0 zgrab/0.x" description:"Set a custom user agent"`
@@ -62,16 +80,44 @@ type Flags struct {
 	// redirect to HTTPS. It does not change the port used for the connection.
 	UseHTTPS bool `long:"use-https" description:"Perform an HTTPS connection on the initial host"`
 
+	// HTTP2 offers h2 in the ALPN list of every TLS connection. Servers that
+	// pick it are spoken to over HTTP/2, and the response's protocol records
+	// which was used. Cleartext h2c is not supported.
+	HTTP2 bool `long:"http2" description:"Offer HTTP/2 via ALPN on TLS connections, falling back to HTTP/1.1 if the server does not select it"`
+
 	// RedirectsSucceed causes the ErrTooManRedirects error to be suppressed
 	RedirectsSucceed bool `long:"redirects-succeed" description:"Redirects are always a success, even if max-redirects is exceeded"`
 
+	// RedirectsSameScheme stops redirects that switch between http and https
+	// from being followed, as ErrRedirLocalhost does for localhost.
+	RedirectsSameScheme bool `long:"redirects-same-scheme" description:"Do not follow redirects that change the scheme between http and https"`
+
 	// Set arbitrary HTTP headers
 	CustomHeadersNames     string `long:"custom-headers-names" description:"CSV of custom HTTP headers to send to server"`
 	CustomHeadersValues    string `long:"custom-headers-values" description:"CSV of custom HTTP header values to send to server. Should match order of custom-headers-names."`
 	CustomHeadersDelimiter string `long:"custom-headers-delimiter" description:"Delimiter for customer header name/value CSVs"`
+	// Headers are sent in addition to, and in place of any of the same name
+	// among, the default and custom headers.
+	Headers []string `long:"header" description:"HTTP header to send, as \"Name: Value\"; may be repeated, and replaces any default or custom header of the same name (Host sets the request's Host)"`
+	// CookieJar keeps the cookies each response sets and sends them with
+	// the requests that follow it, such as redirects.
+	CookieJar bool `long:"cookie-jar" description:"Keep cookies set during the scan, send them on redirects, and record those that apply to the final URL"`
+
+	// Favicon fetches FaviconPath after the main request and records its
+	// hash, as a Favicon.
+	Favicon     bool   `long:"favicon" description:"Also fetch the favicon from the final response's host and record its Shodan-style mmh3 hash"`
+	FaviconPath string `long:"favicon-path" default:"/favicon.ico" description:"Path, or URL relative to the final response, of the favicon fetched with --favicon"`
+
+	// BasicAuth and BearerToken set the Authorization header, unless it is
+	// given with --header or --custom-headers-names. The credentials are
+	// redacted from the recorded requests.
+	BasicAuth   string `long:"basic-auth" description:"Send HTTP Basic credentials, as \"user:password\""`
+	BearerToken string `long:"bearer-token" description:"Send an HTTP Bearer token"`
+
 	// Set HTTP Request body
-	RequestBody    string `long:"request-body" description:"HTTP request body to send to server"`
-	RequestBodyHex string `long:"request-body-hex" description:"HTTP request body to send to server"`
+	RequestBody     string `long:"request-body" description:"HTTP request body to send to server"`
+	RequestBodyHex  string `long:"request-body-hex" description:"HTTP request body to send to server"`
+	RequestBodyFile string `long:"request-body-file" description:"File holding the HTTP request body to send to server"`
 
 	OverrideSH bool `long:"override-sig-hash" description:"Override the default SignatureAndHashes TLS option with more expansive default"`
 
@@ -84,6 +130,10 @@ type Flags struct {
 
 	// Extract the raw header as it is on the wire
 	RawHeaders bool `long:"raw-headers" description:"Extract raw response up through headers"`
+
+	// ProxyDomain treats the target as an HTTP proxy: each connection to it
+	// is tunnelled to ProxyDomain with CONNECT before the request is sent.
+	ProxyDomain string `long:"proxy-domain" description:"Send a CONNECT <domain> first, treating the target as a proxy, and send the request through the tunnel (port 80, or 443 with --use-https, unless given)"`
 }
 
 // A Results object is returned by the HTTP module's Scanner.Scan()
@@ -95,6 +145,46 @@ type Results struct {
 	// RedirectResponseChain is non-empty is the scanner follows a redirect.
 	// It contains all redirect response prior to the final response.
 	RedirectResponseChain []*http.Response `json:"redirect_response_chain,omitempty"`
+
+	// FinalURL is the absolute URL of the final response's request, each
+	// Location having been resolved against the URL before it.
+	FinalURL string `json:"final_url,omitempty"`
+
+	// ProxyRequest is the CONNECT request sent with --proxy-domain, exactly
+	// as written, and ProxyResponse is the proxy's reply to it.
+	ProxyRequest  string         `json:"proxy_request,omitempty"`
+	ProxyResponse *http.Response `json:"proxy_response,omitempty"`
+
+	// Cookies are the --cookie-jar cookies that would be sent to the final
+	// response's URL.
+	Cookies []Cookie `json:"cookies,omitempty"`
+
+	// Favicon is set by --favicon when the favicon request got a response.
+	Favicon *Favicon `json:"favicon,omitempty"`
+
+	// AuthScheme is "Basic" or "Bearer" when --basic-auth or --bearer-token
+	// credentials were sent.
+	AuthScheme string `json:"auth_scheme,omitempty"`
+
+	// Timing is set whenever a response was received.
+	Timing *Timing `json:"timing,omitempty"`
+}
+
+// Cookie is a cookie held in the --cookie-jar.
+type Cookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Timing records, in milliseconds since the request was started, when the
+// first connection was established, when the first response byte arrived,
+// and when the final response's body had been read. The first byte is not
+// observed over HTTP/2, whose bundled client reports to net/http/httptrace,
+// and is left 0.
+type Timing struct {
+	ConnectMS   int64 `json:"connect_ms"`
+	FirstByteMS int64 `json:"first_byte_ms"`
+	TotalMS     int64 `json:"total_ms"`
 }
 
 // Module is an implementation of the zgrab2.Module interface.
@@ -105,10 +195,30 @@ type Module struct {
 type Scanner struct {
 	config        *Flags
 	customHeaders map[string]string
+	headers       []header
 	requestBody   string
+	authorization string
 	decodedHashFn func([]byte) string
 }
 
+// header is a header given with --header.
+type header struct {
+	name, value string
+}
+
+// parseHeader splits a --header value into its name and value.
+func parseHeader(s string) (header, error) {
+	name, value, found := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" || strings.ContainsAny(name, " \t") {
+		return header{}, fmt.Errorf("invalid --header %q: want \"Name: Value\"", s)
+	}
+	if strings.EqualFold(name, "content-length") {
+		return header{}, fmt.Errorf("invalid --header %q: Content-Length is set from the body", s)
+	}
+	return header{name: name, value: strings.TrimSpace(value)}, nil
+}
+
 // scan holds the state for a single scan. This may entail multiple connections.
 // It is used to implement the zgrab2.Scanner interface.
 type scan struct {
@@ -120,6 +230,13 @@ type scan struct {
 	results        Results
 	url            string
 	globalDeadline time.Time
+
+	// proxyAddr is the target's address, which connections are made to in
+	// place of the requested host when it is a ProxyDomain proxy.
+	proxyAddr string
+
+	// started, connected and firstByte are the times behind Results.Timing.
+	started, connected, firstByte time.Time
 }
 
 // NewFlags returns an empty Flags object.
@@ -139,9 +256,104 @@ func (module *Module) Description() string {
 
 // Validate performs any needed validation on the arguments
 func (flags *Flags) Validate(args []string) error {
+	if err := flags.validateMethod(); err != nil {
+		return err
+	}
+	for _, h := range flags.Headers {
+		if _, err := parseHeader(h); err != nil {
+			return err
+		}
+	}
+	bodies := 0
+	for _, body := range []string{flags.RequestBody, flags.RequestBodyHex, flags.RequestBodyFile} {
+		if body != "" {
+			bodies++
+		}
+	}
+	if flags.BasicAuth != "" && flags.BearerToken != "" {
+		return fmt.Errorf("only one of --basic-auth and --bearer-token may be given")
+	}
+	if flags.BasicAuth != "" && !strings.Contains(flags.BasicAuth, ":") {
+		return fmt.Errorf("--basic-auth must be \"user:password\"")
+	}
+	if strings.ContainsAny(flags.BearerToken, "\r\n") {
+		return fmt.Errorf("--bearer-token must not contain line breaks")
+	}
+	if bodies > 1 {
+		return fmt.Errorf("only one of --request-body, --request-body-hex and --request-body-file may be given")
+	}
+	if _, err := hex.DecodeString(flags.RequestBodyHex); err != nil {
+		return fmt.Errorf("invalid --request-body-hex: %v", err)
+	}
+	if bodies > 0 && !methodAllowsBody(flags.Method) {
+		return fmt.Errorf("a %s request cannot have a body", flags.Method)
+	}
 	return nil
 }
 
+// authorization returns the Authorization header value for --basic-auth or
+// --bearer-token, or "" if neither was given.
+func (flags *Flags) authorization() string {
+	switch {
+	case flags.BasicAuth != "":
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(flags.BasicAuth))
+	case flags.BearerToken != "":
+		return "Bearer " + flags.BearerToken
+	}
+	return ""
+}
+
+// hasExplicitAuthorization reports whether an Authorization header was
+// given with --header or --custom-headers-names.
+func (scanner *Scanner) hasExplicitAuthorization() bool {
+	if _, ok := scanner.customHeaders["authorization"]; ok {
+		return true
+	}
+	for _, h := range scanner.headers {
+		if strings.EqualFold(h.name, "authorization") {
+			return true
+		}
+	}
+	return false
+}
+
+// knownMethods are the methods --method accepts without --custom-method.
+var knownMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS", "PATCH", "TRACE"}
+
+// validateMethod upper-cases a known --method and rejects any other unless
+// --custom-method is given. Methods that would break the request line are
+// always rejected.
+func (flags *Flags) validateMethod() error {
+	if flags.Method == "" {
+		return fmt.Errorf("--method must not be empty")
+	}
+	for _, c := range flags.Method {
+		if c <= ' ' || c >= 0x7f {
+			return fmt.Errorf("invalid --method %q", flags.Method)
+		}
+	}
+	upper := strings.ToUpper(flags.Method)
+	for _, method := range knownMethods {
+		if upper == method {
+			flags.Method = method
+			return nil
+		}
+	}
+	if !flags.CustomMethod {
+		return fmt.Errorf("unknown --method %q (use --custom-method to send it anyway)", flags.Method)
+	}
+	return nil
+}
+
+// methodAllowsBody reports whether a request with method may carry a body.
+func methodAllowsBody(method string) bool {
+	switch strings.ToUpper(method) {
+	case "HEAD", "TRACE", "CONNECT":
+		return false
+	}
+	return true
+}
+
 // Help returns module-specific help
 func (flags *Flags) Help() string {
 	return ""
@@ -156,7 +368,22 @@ func (scanner *Scanner) Protocol() string {
 func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
 	fl, _ := flags.(*Flags)
 	scanner.config = fl
-	scanner.config.RequestBody = fl.RequestBody
+	switch {
+	case fl.RequestBody != "":
+		scanner.requestBody = fl.RequestBody
+	case fl.RequestBodyHex != "":
+		body, err := hex.DecodeString(fl.RequestBodyHex)
+		if err != nil {
+			return err
+		}
+		scanner.requestBody = string(body)
+	case fl.RequestBodyFile != "":
+		body, err := os.ReadFile(fl.RequestBodyFile)
+		if err != nil {
+			return fmt.Errorf("could not read --request-body-file: %v", err)
+		}
+		scanner.requestBody = string(body)
+	}
 
 	// parse out custom headers at initialization so that they can be easily
 	// iterated over when constructing individual scanners
@@ -217,6 +444,20 @@ func (scanner *Scanner) Init(flags zgrab2.ScanFlags) error {
 		}
 	}
 
+	for _, h := range fl.Headers {
+		parsed, err := parseHeader(h)
+		if err != nil {
+			return err
+		}
+		scanner.headers = append(scanner.headers, parsed)
+	}
+
+	scanner.authorization = fl.authorization()
+	if scanner.authorization != "" && scanner.hasExplicitAuthorization() {
+		log.Warnf("an Authorization header was given explicitly; not sending the --basic-auth or --bearer-token credentials")
+		scanner.authorization = ""
+	}
+
 	if fl.ComputeDecodedBodyHashAlgorithm == "sha1" {
 		scanner.decodedHashFn = func(body []byte) string {
 			rawHash := sha1.Sum(body)
@@ -305,14 +546,68 @@ func (scan *scan) dialContext(ctx context.Context, network string, addr string)
 
 	timeoutContext, _ := context.WithTimeout(context.Background(), scan.scanner.config.Timeout)
 
-	conn, err := dialer.DialContext(scan.withDeadlineContext(timeoutContext), network, addr)
+	dialAddr := addr
+	if scan.proxyAddr != "" {
+		dialAddr = scan.proxyAddr
+	}
+	conn, err := dialer.DialContext(scan.withDeadlineContext(timeoutContext), network, dialAddr)
 	if err != nil {
 		return nil, err
 	}
 	scan.connections = append(scan.connections, conn)
+	if scan.connected.IsZero() {
+		scan.connected = time.Now()
+	}
+	if scan.proxyAddr != "" {
+		return scan.connectProxy(conn, addr)
+	}
 	return conn, nil
 }
 
+// bufferedConn is a net.Conn whose reads go through the bufio.Reader that
+// was used to read the proxy's CONNECT response, so that nothing the proxy
+// sent after it is lost.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// connectProxy asks the proxy on conn to open a tunnel to addr, recording
+// the first CONNECT exchange in the results, and returns the tunnel.
+func (scan *scan) connectProxy(conn net.Conn, addr string) (net.Conn, error) {
+	request := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	request.Header.Set("User-Agent", scan.scanner.config.UserAgent)
+	var raw bytes.Buffer
+	if err := request.Write(&raw); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(raw.Bytes()); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if scan.results.ProxyResponse == nil {
+		scan.results.ProxyRequest = raw.String()
+		scan.results.ProxyResponse = response
+	}
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, zgrab2.NewScanError(zgrab2.SCAN_APPLICATION_ERROR, ErrProxyRefused)
+	}
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
 // getTLSDialer returns a Dial function that connects using the
 // zgrab2.GetTLSConnection()
 func (scan *scan) getTLSDialer(t *zgrab2.ScanTarget) func(network, addr string) (net.Conn, error) {
@@ -355,6 +650,9 @@ func (scan *scan) getTLSDialer(t *zgrab2.ScanTarget) func(network, addr string)
 				{0x01, 0x06}, // rsa, sha512
 			}
 		}
+		if scan.scanner.config.HTTP2 {
+			cfg.NextProtos = alpnWithHTTP2(cfg.NextProtos)
+		}
 		tlsConn := scan.scanner.config.TLSFlags.GetWrappedConnection(outer, cfg)
 
 		// lib/http/transport.go fills in the TLSLog in the http.Request instance(s)
@@ -363,6 +661,26 @@ func (scan *scan) getTLSDialer(t *zgrab2.ScanTarget) func(network, addr string)
 	}
 }
 
+// alpnWithHTTP2 puts h2 first in protos and makes sure http/1.1 is offered
+// to fall back to.
+func alpnWithHTTP2(protos []string) []string {
+	ret := []string{"h2"}
+	hasHTTP11 := false
+	for _, proto := range protos {
+		switch proto {
+		case "h2":
+			continue
+		case "http/1.1":
+			hasHTTP11 = true
+		}
+		ret = append(ret, proto)
+	}
+	if !hasHTTP11 {
+		ret = append(ret, "http/1.1")
+	}
+	return ret
+}
+
 // Taken from zgrab/zlib/grabber.go -- check if the URL points to localhost
 func redirectsToLocalhost(host string) bool {
 	if i := net.ParseIP(host); i != nil {
@@ -391,16 +709,18 @@ func (scan *scan) getCheckRedirect() func(*http.Request, *http.Response, []*http
 		if !scan.scanner.config.FollowLocalhostRedirects && redirectsToLocalhost(req.URL.Hostname()) {
 			return ErrRedirLocalhost
 		}
-		scan.results.RedirectResponseChain = append(scan.results.RedirectResponseChain, res)
-		b := new(bytes.Buffer)
-		maxReadLen := int64(scan.scanner.config.MaxSize) * 1024
-		readLen := maxReadLen
-		if res.ContentLength >= 0 && res.ContentLength < maxReadLen {
-			readLen = res.ContentLength
+		for _, prev := range via {
+			if prev.URL.String() == req.URL.String() {
+				return ErrRedirLoop
+			}
+		}
+		if scan.scanner.config.RedirectsSameScheme && len(via) > 0 && via[len(via)-1].URL.Scheme != req.URL.Scheme {
+			return ErrRedirSchemeChange
 		}
-		bytesRead, _ := io.CopyN(b, res.Body, readLen)
+		scan.results.RedirectResponseChain = append(scan.results.RedirectResponseChain, res)
+		b, _ := scan.readBody(res)
 		if scan.scanner.config.WithBodyLength {
-			res.BodyTextLength = bytesRead
+			res.BodyTextLength = int64(b.Len())
 		}
 		res.BodyText = b.String()
 		if len(res.BodyText) > 0 {
@@ -421,6 +741,82 @@ func (scan *scan) getCheckRedirect() func(*http.Request, *http.Response, []*http
 	}
 }
 
+// readBody reads the body of res up to MaxSize kilobytes, or its
+// Content-Length if that is less, returning it and the length it was
+// limited to. res.BodyTruncated is set if the body went on past MaxSize.
+func (scan *scan) readBody(res *http.Response) (*bytes.Buffer, int64) {
+	buf := new(bytes.Buffer)
+	maxReadLen := int64(scan.scanner.config.MaxSize) * 1024
+	readLen := maxReadLen
+	if res.ContentLength >= 0 && res.ContentLength < maxReadLen {
+		readLen = res.ContentLength
+	}
+	n, _ := io.CopyN(buf, res.Body, readLen)
+	if readLen == maxReadLen && n == maxReadLen {
+		// The rest is left unread: one more byte tells whether there is any.
+		var next [1]byte
+		more, _ := io.ReadFull(res.Body, next[:])
+		res.BodyTruncated = more > 0
+	}
+	return buf, readLen
+}
+
+// redactAuthorization replaces the credentials in the Authorization header
+// of every recorded request, leaving only the scheme.
+func (scan *scan) redactAuthorization() {
+	responses := append([]*http.Response{scan.results.Response}, scan.results.RedirectResponseChain...)
+	for _, res := range responses {
+		if res == nil || res.Request == nil {
+			continue
+		}
+		if res.Request.Header.Get("Authorization") == scan.scanner.authorization {
+			res.Request.Header.Set("Authorization", scan.results.AuthScheme+" [redacted]")
+		}
+	}
+}
+
+// sinceStart returns the milliseconds from start to t, or 0 if t was never
+// reached.
+func sinceStart(start, t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Sub(start).Milliseconds()
+}
+
+// isHTML reports whether a body is HTML, from its Content-Type or, when there
+// is none, from sniffing it.
+func isHTML(contentType string, body []byte) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// htmlTitle returns the text of the first <title> in body, entities decoded
+// and whitespace collapsed, or "" if there is none.
+func htmlTitle(body string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			if name, _ := tokenizer.TagName(); string(name) != "title" {
+				continue
+			}
+			// The tokenizer reads a title as raw text: a single text
+			// token, if any, up to </title>.
+			if tokenizer.Next() != html.TextToken {
+				return ""
+			}
+			return strings.Join(strings.Fields(string(tokenizer.Text())), " ")
+		}
+	}
+}
+
 // Maps URL protocol to the default port for that protocol
 var protoToPort = map[string]uint16{
 	"http":  80,
@@ -464,10 +860,19 @@ func (scanner *Scanner) newHTTPScan(t *zgrab2.ScanTarget, useHTTPS bool) *scan {
 	}
 	ret.transport.DialTLS = ret.getTLSDialer(t)
 	ret.transport.DialContext = ret.dialContext
+	if scanner.config.HTTP2 {
+		if err := ret.transport.ConfigureHTTP2(); err != nil {
+			log.Warnf("could not enable HTTP/2: %v", err)
+		}
+	}
 	ret.client.UserAgent = scanner.config.UserAgent
 	ret.client.CheckRedirect = ret.getCheckRedirect()
 	ret.client.Transport = ret.transport
 	ret.client.Jar = nil // Don't send or receive cookies (otherwise use CookieJar)
+	if scanner.config.CookieJar {
+		// Options are valid, so New cannot fail.
+		ret.client.Jar, _ = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	}
 	ret.client.Timeout = scanner.config.Timeout
 	host := t.Domain
 	if host == "" {
@@ -480,6 +885,23 @@ func (scanner *Scanner) newHTTPScan(t *zgrab2.ScanTarget, useHTTPS bool) *scan {
 	} else {
 		port = uint16(scanner.config.BaseFlags.Port)
 	}
+	if scanner.config.ProxyDomain != "" {
+		// Connect to the proxy, and request the domain through it.
+		proxyHost := host
+		if t.IP != nil {
+			proxyHost = t.IP.String()
+		}
+		ret.proxyAddr = net.JoinHostPort(proxyHost, strconv.FormatUint(uint64(port), 10))
+		host, port = scanner.config.ProxyDomain, protoToPort["http"]
+		if useHTTPS {
+			port = protoToPort["https"]
+		}
+		if h, p, err := net.SplitHostPort(scanner.config.ProxyDomain); err == nil {
+			if n, err := strconv.ParseUint(p, 10, 16); err == nil {
+				host, port = h, uint16(n)
+			}
+		}
+	}
 	ret.url = getHTTPURL(useHTTPS, host, port, scanner.config.Endpoint)
 
 	return &ret
@@ -487,16 +909,12 @@ func (scanner *Scanner) newHTTPScan(t *zgrab2.ScanTarget, useHTTPS bool) *scan {
 
 // Grab performs the HTTP scan -- implementation taken from zgrab/zlib/grabber.go
 func (scan *scan) Grab() *zgrab2.ScanError {
-	// TODO: Allow body?
 	var (
 		request *http.Request
 		err     error
 	)
-	if len(scan.scanner.config.RequestBody) > 0 {
-		request, err = http.NewRequest(scan.scanner.config.Method, scan.url, strings.NewReader(scan.scanner.config.RequestBody))
-	} else if len(scan.scanner.config.RequestBodyHex) > 0 {
-		reqbody, _ := hex.DecodeString(scan.scanner.config.RequestBodyHex)
-		request, err = http.NewRequest(scan.scanner.config.Method, scan.url, bytes.NewReader(reqbody))
+	if len(scan.scanner.requestBody) > 0 {
+		request, err = http.NewRequest(scan.scanner.config.Method, scan.url, strings.NewReader(scan.scanner.requestBody))
 	} else {
 		request, err = http.NewRequest(scan.scanner.config.Method, scan.url, nil)
 	}
@@ -516,12 +934,38 @@ func (scan *scan) Grab() *zgrab2.ScanError {
 		// to set the Accept header
 		request.Header.Set("Accept", "*/*")
 	}
+	if auth := scan.scanner.authorization; auth != "" {
+		request.Header.Set("Authorization", auth)
+		scan.results.AuthScheme, _, _ = strings.Cut(auth, " ")
+		defer scan.redactAuthorization()
+	}
+	for _, h := range scan.scanner.headers {
+		request.Header.Del(h.name)
+	}
+	for _, h := range scan.scanner.headers {
+		if strings.EqualFold(h.name, "host") {
+			request.Host = h.value
+		} else {
+			request.Header.Add(h.name, h.value)
+		}
+	}
 
+	request = request.WithContext(httptrace.WithClientTrace(request.Context(), &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			if scan.firstByte.IsZero() {
+				scan.firstByte = time.Now()
+			}
+		},
+	}))
+	scan.started = time.Now()
 	resp, err := scan.client.Do(request)
 	if resp != nil && resp.Body != nil {
 		defer resp.Body.Close()
 	}
 	scan.results.Response = resp
+	if resp != nil && resp.Request != nil {
+		scan.results.FinalURL = resp.Request.URL.String()
+	}
 	if err != nil {
 		if urlError, ok := err.(*url.Error); ok {
 			err = urlError.Err
@@ -529,7 +973,7 @@ func (scan *scan) Grab() *zgrab2.ScanError {
 	}
 	if err != nil {
 		switch err {
-		case ErrRedirLocalhost:
+		case ErrRedirLocalhost, ErrRedirLoop, ErrRedirSchemeChange:
 			break
 		case ErrTooManyRedirects:
 			if scan.scanner.config.RedirectsSucceed {
@@ -541,13 +985,17 @@ func (scan *scan) Grab() *zgrab2.ScanError {
 		}
 	}
 
-	buf := new(bytes.Buffer)
-	maxReadLen := int64(scan.scanner.config.MaxSize) * 1024
-	readLen := maxReadLen
-	if resp.ContentLength >= 0 && resp.ContentLength < maxReadLen {
-		readLen = resp.ContentLength
+	if scan.client.Jar != nil && resp.Request != nil {
+		for _, cookie := range scan.client.Jar.Cookies(resp.Request.URL) {
+			scan.results.Cookies = append(scan.results.Cookies, Cookie{Name: cookie.Name, Value: cookie.Value})
+		}
+	}
+	buf, readLen := scan.readBody(resp)
+	scan.results.Timing = &Timing{
+		ConnectMS:   sinceStart(scan.started, scan.connected),
+		FirstByteMS: sinceStart(scan.started, scan.firstByte),
+		TotalMS:     sinceStart(scan.started, time.Now()),
 	}
-	io.CopyN(buf, resp.Body, readLen)
 	encoder, encoding, certain := charset.DetermineEncoding(buf.Bytes(), resp.Header.Get("content-type"))
 
 	bodyText := ""
@@ -591,7 +1039,8 @@ func (scan *scan) Grab() *zgrab2.ScanError {
 		}
 	}
 
-	// re-enforce readlen
+	// re-enforce readlen. This clips the decoded text, which can be longer
+	// than the bytes read, so BodyTruncated is left to readBody.
 	if int64(len(bodyText)) > readLen {
 		scan.results.Response.BodyText = bodyText[:int(readLen)]
 	} else {
@@ -602,6 +1051,10 @@ func (scan *scan) Grab() *zgrab2.ScanError {
 		scan.results.Response.BodyTextLength = int64(len(scan.results.Response.BodyText))
 	}
 
+	if isHTML(resp.Header.Get("Content-Type"), buf.Bytes()) {
+		scan.results.Response.Title = htmlTitle(bodyText)
+	}
+
 	if len(scan.results.Response.BodyText) > 0 {
 		if scan.scanner.decodedHashFn != nil {
 			scan.results.Response.BodyHash = scan.scanner.decodedHashFn([]byte(scan.results.Response.BodyText))
@@ -612,6 +1065,10 @@ func (scan *scan) Grab() *zgrab2.ScanError {
 		}
 	}
 
+	if scan.scanner.config.Favicon && resp.Request != nil {
+		scan.results.Favicon = scan.grabFavicon(resp.Request.URL)
+	}
+
 	return nil
 }
 
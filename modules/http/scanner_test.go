@@ -0,0 +1,524 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/http"
+)
+
+// newTestScanner returns a scanner for a server on port of the local host,
+// after letting configure adjust the flags.
+func newTestScanner(t *testing.T, port int, configure func(*Flags)) *Scanner {
+	var module Module
+	flags := module.NewFlags().(*Flags)
+	flags.Endpoint = "/"
+	flags.Method = "GET"
+	flags.UserAgent = "Mozilla/5.0 zgrab/0.x"
+	flags.MaxSize = 256
+	flags.Timeout = 2 * time.Second
+	flags.Port = uint(port)
+	if configure != nil {
+		configure(flags)
+	}
+	if err := flags.Validate(nil); err != nil {
+		t.Fatalf("flags rejected: %v", err)
+	}
+	scanner := module.NewScanner().(*Scanner)
+	if err := scanner.Init(flags); err != nil {
+		t.Fatal(err)
+	}
+	return scanner
+}
+
+// serveHTTP accepts connections on a local listener and hands each request
+// read from them to handle, which writes the raw response. It returns the
+// listener's port.
+func serveHTTP(t *testing.T, handle func(conn net.Conn, request *http.Request)) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				reader := bufio.NewReader(conn)
+				for {
+					request, err := http.ReadRequest(reader)
+					if err != nil {
+						return
+					}
+					handle(conn, request)
+				}
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func localTarget() zgrab2.ScanTarget {
+	return zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1")}
+}
+
+func TestProxyDomain(t *testing.T) {
+	for name, test := range map[string]struct {
+		proxyStatus string
+		wantStatus  zgrab2.ScanStatus
+	}{
+		"open":    {proxyStatus: "200 Connection established", wantStatus: zgrab2.SCAN_SUCCESS},
+		"refused": {proxyStatus: "407 Proxy Authentication Required", wantStatus: zgrab2.SCAN_APPLICATION_ERROR},
+	} {
+		t.Run(name, func(t *testing.T) {
+			port := serveHTTP(t, func(conn net.Conn, request *http.Request) {
+				if request.Method == "CONNECT" {
+					if request.Host != "www.example.com:80" {
+						conn.Write([]byte("HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\n\r\n"))
+						return
+					}
+					conn.Write([]byte("HTTP/1.1 " + test.proxyStatus + "\r\nContent-Length: 0\r\n\r\n"))
+					return
+				}
+				// Through the tunnel, the request is for the proxied domain.
+				conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: " + strconv.Itoa(len(request.Host)) + "\r\n\r\n" + request.Host))
+			})
+			scanner := newTestScanner(t, port, func(flags *Flags) { flags.ProxyDomain = "www.example.com" })
+			status, res, err := scanner.Scan(localTarget())
+			if status != test.wantStatus {
+				t.Fatalf("got %s, %v", status, err)
+			}
+			results := res.(*Results)
+			if !strings.HasPrefix(results.ProxyRequest, "CONNECT www.example.com:80 HTTP/1.1\r\n") {
+				t.Errorf("got proxy request %q", results.ProxyRequest)
+			}
+			if results.ProxyResponse == nil || results.ProxyResponse.Status != test.proxyStatus {
+				t.Errorf("got proxy response %+v", results.ProxyResponse)
+			}
+			if status == zgrab2.SCAN_SUCCESS && results.Response.BodyText != "www.example.com" {
+				t.Errorf("got body %q", results.Response.BodyText)
+			}
+		})
+	}
+}
+
+// redirectTo answers every request with a redirect to location(request).
+func redirectTo(location func(request *http.Request) string) func(net.Conn, *http.Request) {
+	return func(conn net.Conn, request *http.Request) {
+		conn.Write([]byte("HTTP/1.1 302 Found\r\nLocation: " + location(request) + "\r\nContent-Length: 0\r\n\r\n"))
+	}
+}
+
+func TestRedirectGuards(t *testing.T) {
+	// / and /a redirect to each other.
+	port := serveHTTP(t, redirectTo(func(request *http.Request) string {
+		if request.URL.Path == "/" {
+			return "/a"
+		}
+		return "/"
+	}))
+	scanner := newTestScanner(t, port, func(flags *Flags) {
+		flags.MaxRedirects = 5
+		flags.FollowLocalhostRedirects = true
+	})
+	status, res, err := scanner.Scan(localTarget())
+	if status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("loop: got %s, %v", status, err)
+	}
+	if results := res.(*Results); len(results.RedirectResponseChain) != 1 || results.Response.Request.URL.Path != "/a" {
+		t.Errorf("loop: got %d hops ending at %s", len(results.RedirectResponseChain), results.Response.Request.URL)
+	}
+
+	port = serveHTTP(t, redirectTo(func(request *http.Request) string {
+		return "https://" + request.Host + "/"
+	}))
+	scanner = newTestScanner(t, port, func(flags *Flags) {
+		flags.MaxRedirects = 5
+		flags.FollowLocalhostRedirects = true
+		flags.RedirectsSameScheme = true
+	})
+	status, res, err = scanner.Scan(localTarget())
+	if status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("scheme change: got %s, %v", status, err)
+	}
+	if results := res.(*Results); results.Response.StatusCode != 302 || results.Response.Request.URL.Scheme != "http" {
+		t.Errorf("scheme change: followed to %s", results.Response.Request.URL)
+	}
+}
+
+func TestBodyTruncated(t *testing.T) {
+	for name, test := range map[string]struct {
+		size          int
+		contentLength bool
+		want          bool
+	}{
+		"over":               {size: 3000, contentLength: true, want: true},
+		"at limit":           {size: 1024, contentLength: true},
+		"over, unframed":     {size: 3000, want: true},
+		"at limit, unframed": {size: 1024},
+	} {
+		port := serveHTTP(t, func(conn net.Conn, request *http.Request) {
+			head := "HTTP/1.1 200 OK\r\n"
+			if test.contentLength {
+				head += "Content-Length: " + strconv.Itoa(test.size) + "\r\n"
+			}
+			conn.Write([]byte(head + "\r\n" + strings.Repeat("x", test.size)))
+			conn.Close()
+		})
+		scanner := newTestScanner(t, port, func(flags *Flags) { flags.MaxSize = 1 })
+		status, res, err := scanner.Scan(localTarget())
+		if status != zgrab2.SCAN_SUCCESS {
+			t.Fatalf("%s: got %s, %v", name, status, err)
+		}
+		response := res.(*Results).Response
+		if response.BodyTruncated != test.want || len(response.BodyText) != 1024 {
+			t.Errorf("%s: got body_truncated=%v with %d bytes", name, response.BodyTruncated, len(response.BodyText))
+		}
+	}
+}
+
+func TestBodyTruncatedDecoded(t *testing.T) {
+	// 1000 bytes of Latin-1 decode to 2000 bytes of UTF-8, which are clipped
+	// to the 1000 read, although the whole body arrived.
+	port := serveHTTP(t, func(conn net.Conn, request *http.Request) {
+		conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Type: text/plain; charset=iso-8859-1\r\nContent-Length: 1000\r\n\r\n"))
+		conn.Write(bytes.Repeat([]byte{0xe9}, 1000))
+		conn.Close()
+	})
+	scanner := newTestScanner(t, port, func(flags *Flags) { flags.MaxSize = 1 })
+	status, res, err := scanner.Scan(localTarget())
+	if status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("got %s, %v", status, err)
+	}
+	response := res.(*Results).Response
+	if response.BodyTruncated || len(response.BodyText) != 1000 {
+		t.Errorf("got body_truncated=%v with %d bytes", response.BodyTruncated, len(response.BodyText))
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	port := serveHTTP(t, func(conn net.Conn, request *http.Request) {
+		received <- request
+		conn.Write([]byte("HTTP/1.1 204 No Content\r\n\r\n"))
+	})
+	scanner := newTestScanner(t, port, func(flags *Flags) {
+		flags.Headers = []string{"Accept: text/html", "X-Token: a", "x-token: b", "Host: vhost.example.com"}
+	})
+	if status, _, err := scanner.Scan(localTarget()); status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("got %s, %v", status, err)
+	}
+	request := <-received
+	if got := request.Header["Accept"]; len(got) != 1 || got[0] != "text/html" {
+		t.Errorf("got Accept %q", got)
+	}
+	if got := request.Header["X-Token"]; len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("got X-Token %q", got)
+	}
+	if request.Host != "vhost.example.com" {
+		t.Errorf("got Host %q", request.Host)
+	}
+
+	for _, bad := range []string{"no colon", ": value", "Two Words: x", "Content-Length: 5"} {
+		if err := (&Flags{Method: "GET", Headers: []string{bad}}).Validate(nil); err == nil {
+			t.Errorf("accepted --header %q", bad)
+		}
+	}
+}
+
+func TestRequestBodyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "body")
+	if err := os.WriteFile(path, []byte("a=1&b=2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 1)
+	port := serveHTTP(t, func(conn net.Conn, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		received <- string(body)
+		conn.Write([]byte("HTTP/1.1 204 No Content\r\n\r\n"))
+	})
+	scanner := newTestScanner(t, port, func(flags *Flags) {
+		flags.Method = "POST"
+		flags.RequestBodyFile = path
+	})
+	if status, _, err := scanner.Scan(localTarget()); status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("got %s, %v", status, err)
+	}
+	if body := <-received; body != "a=1&b=2" {
+		t.Errorf("got body %q", body)
+	}
+
+	for name, flags := range map[string]*Flags{
+		"two bodies": {Method: "POST", RequestBody: "x", RequestBodyFile: path},
+		"bad hex":    {Method: "POST", RequestBodyHex: "zz"},
+		"HEAD":       {Method: "HEAD", RequestBody: "x"},
+	} {
+		if err := flags.Validate(nil); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestHTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	for http2, want := range map[bool]string{true: "HTTP/2.0", false: "HTTP/1.1"} {
+		scanner := newTestScanner(t, port, func(flags *Flags) {
+			flags.UseHTTPS = true
+			flags.HTTP2 = http2
+		})
+		status, res, err := scanner.Scan(localTarget())
+		if status != zgrab2.SCAN_SUCCESS {
+			t.Fatalf("http2 %v: got %s, %v", http2, status, err)
+		}
+		response := res.(*Results).Response
+		if response.Protocol.Name != want || response.BodyText != want || response.StatusCode != 200 {
+			t.Errorf("http2 %v: got %s %d %q", http2, response.Protocol.Name, response.StatusCode, response.BodyText)
+		}
+		if response.Request.TLSLog == nil {
+			t.Errorf("http2 %v: no TLS log", http2)
+		}
+	}
+}
+
+func TestValidateMethod(t *testing.T) {
+	flags := &Flags{Method: "post"}
+	if err := flags.Validate(nil); err != nil || flags.Method != "POST" {
+		t.Errorf("got %q, %v", flags.Method, err)
+	}
+	if err := (&Flags{Method: "PROPFIND", CustomMethod: true}).Validate(nil); err != nil {
+		t.Errorf("rejected a custom method: %v", err)
+	}
+	for _, bad := range []string{"", "PROPFIND", "GET /", "G\tET"} {
+		if err := (&Flags{Method: bad, CustomMethod: bad != "PROPFIND"}).Validate(nil); err == nil {
+			t.Errorf("accepted --method %q", bad)
+		}
+	}
+}
+
+func TestContentEncoding(t *testing.T) {
+	body := strings.Repeat("zgrab2 ", 1000)
+	compress := map[string]func(io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		"raw deflate": func(w io.Writer) io.WriteCloser {
+			zw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return zw
+		},
+	}
+	for name, newWriter := range compress {
+		var compressed bytes.Buffer
+		zw := newWriter(&compressed)
+		zw.Write([]byte(body))
+		zw.Close()
+		encoding := strings.TrimPrefix(name, "raw ")
+		port := serveHTTP(t, func(conn net.Conn, request *http.Request) {
+			conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Encoding: " + encoding +
+				"\r\nContent-Length: " + strconv.Itoa(compressed.Len()) + "\r\n\r\n"))
+			conn.Write(compressed.Bytes())
+		})
+		for maxSize, want := range map[int]string{256: body, 1: body[:1024]} {
+			scanner := newTestScanner(t, port, func(flags *Flags) { flags.MaxSize = maxSize })
+			status, res, err := scanner.Scan(localTarget())
+			if status != zgrab2.SCAN_SUCCESS {
+				t.Fatalf("%s: got %s, %v", name, status, err)
+			}
+			response := res.(*Results).Response
+			if response.BodyText != want || response.BodyTruncated != (maxSize == 1) {
+				t.Errorf("%s, max %dKB: got %d bytes, body_truncated=%v", name, maxSize, len(response.BodyText), response.BodyTruncated)
+			}
+			if response.ContentEncoding != encoding || response.CompressedLength == 0 || response.CompressedLength > int64(compressed.Len()) {
+				t.Errorf("%s: got encoding %q, compressed length %d", name, response.ContentEncoding, response.CompressedLength)
+			}
+			if maxSize == 256 && response.CompressedLength != int64(compressed.Len()) {
+				t.Errorf("%s: got compressed length %d, wanted %d", name, response.CompressedLength, compressed.Len())
+			}
+		}
+	}
+}
+
+func TestTiming(t *testing.T) {
+	port := serveHTTP(t, func(conn net.Conn, request *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\n"))
+		time.Sleep(50 * time.Millisecond)
+		conn.Write([]byte("ok"))
+	})
+	status, res, err := newTestScanner(t, port, nil).Scan(localTarget())
+	if status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("got %s, %v", status, err)
+	}
+	timing := res.(*Results).Timing
+	if timing == nil || timing.ConnectMS > timing.FirstByteMS || timing.FirstByteMS < 50 || timing.TotalMS < timing.FirstByteMS+50 {
+		t.Errorf("got timing %+v", timing)
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	received := make(chan string, 1)
+	port := serveHTTP(t, func(conn net.Conn, request *http.Request) {
+		received <- request.Header.Get("Authorization")
+		conn.Write([]byte("HTTP/1.1 204 No Content\r\n\r\n"))
+	})
+	for name, test := range map[string]struct {
+		configure func(*Flags)
+		sent      string
+		scheme    string
+		recorded  string
+	}{
+		"basic": {
+			configure: func(flags *Flags) { flags.BasicAuth = "user:pass" },
+			sent:      "Basic dXNlcjpwYXNz",
+			scheme:    "Basic",
+			recorded:  "Basic [redacted]",
+		},
+		"bearer": {
+			configure: func(flags *Flags) { flags.BearerToken = "s3cret" },
+			sent:      "Bearer s3cret",
+			scheme:    "Bearer",
+			recorded:  "Bearer [redacted]",
+		},
+		"explicit header wins": {
+			configure: func(flags *Flags) {
+				flags.BearerToken = "s3cret"
+				flags.Headers = []string{"Authorization: Token abc"}
+			},
+			sent:     "Token abc",
+			recorded: "Token abc",
+		},
+	} {
+		status, res, err := newTestScanner(t, port, test.configure).Scan(localTarget())
+		if status != zgrab2.SCAN_SUCCESS {
+			t.Fatalf("%s: got %s, %v", name, status, err)
+		}
+		if sent := <-received; sent != test.sent {
+			t.Errorf("%s: sent %q", name, sent)
+		}
+		results := res.(*Results)
+		if got := results.Response.Request.Header.Get("Authorization"); got != test.recorded || results.AuthScheme != test.scheme {
+			t.Errorf("%s: recorded %q with auth scheme %q", name, got, results.AuthScheme)
+		}
+	}
+
+	for _, flags := range []*Flags{
+		{Method: "GET", BasicAuth: "nocolon"},
+		{Method: "GET", BasicAuth: "user:pass", BearerToken: "s3cret"},
+	} {
+		if err := flags.Validate(nil); err == nil {
+			t.Errorf("accepted %+v", flags)
+		}
+	}
+}
+
+func TestCookieJar(t *testing.T) {
+	received := make(chan string, 2)
+	port := serveHTTP(t, func(conn net.Conn, request *http.Request) {
+		received <- request.Header.Get("Cookie")
+		if request.URL.Path == "/" {
+			conn.Write([]byte("HTTP/1.1 302 Found\r\nLocation: /portal\r\nSet-Cookie: session=abc; Path=/\r\nContent-Length: 0\r\n\r\n"))
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 200 OK\r\nSet-Cookie: seen=1\r\nContent-Length: 0\r\n\r\n"))
+	})
+	scanner := newTestScanner(t, port, func(flags *Flags) {
+		flags.MaxRedirects = 1
+		flags.FollowLocalhostRedirects = true
+		flags.CookieJar = true
+	})
+	status, res, err := scanner.Scan(localTarget())
+	if status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("got %s, %v", status, err)
+	}
+	if first, second := <-received, <-received; first != "" || second != "session=abc" {
+		t.Errorf("sent cookies %q then %q", first, second)
+	}
+	cookies := res.(*Results).Cookies
+	if len(cookies) != 2 || cookies[0] != (Cookie{Name: "session", Value: "abc"}) || cookies[1] != (Cookie{Name: "seen", Value: "1"}) {
+		t.Errorf("got cookies %+v", cookies)
+	}
+}
+
+func TestHTMLTitle(t *testing.T) {
+	for body, want := range map[string]string{
+		"<html><head><title>\n  Welcome   to\tnginx!\n</title></head></html>": "Welcome to nginx!",
+		"<TITLE>Fish &amp; Chips &#8211; &lt;Menu&gt;</TITLE>":                "Fish & Chips – <Menu>",
+		"<title><b>not a tag</b></title>":                                     "<b>not a tag</b>",
+		"<title></title>":                                                     "",
+		"<p>no title</p>":                                                     "",
+		"<title>unterminated":                                                 "unterminated",
+	} {
+		if got := htmlTitle(body); got != want {
+			t.Errorf("htmlTitle(%q) = %q, wanted %q", body, got, want)
+		}
+	}
+
+	port := serveHTTP(t, func(conn net.Conn, request *http.Request) {
+		body := "<title>Caf\xe9</title>"
+		contentType := "text/html; charset=iso-8859-1"
+		if request.URL.Path == "/text" {
+			contentType = "text/plain"
+		}
+		conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Type: " + contentType + "\r\nContent-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body))
+	})
+	for endpoint, want := range map[string]string{"/": "Café", "/text": ""} {
+		scanner := newTestScanner(t, port, func(flags *Flags) { flags.Endpoint = endpoint })
+		status, res, err := scanner.Scan(localTarget())
+		if status != zgrab2.SCAN_SUCCESS {
+			t.Fatalf("%s: got %s, %v", endpoint, status, err)
+		}
+		if got := res.(*Results).Response.Title; got != want {
+			t.Errorf("%s: got title %q, wanted %q", endpoint, got, want)
+		}
+	}
+}
+
+func TestFinalURL(t *testing.T) {
+	var port int
+	port = serveHTTP(t, func(conn net.Conn, request *http.Request) {
+		switch request.URL.Path {
+		case "/":
+			redirectTo(func(*http.Request) string { return "//127.0.0.1:" + strconv.Itoa(port) + "/a/b?x=1" })(conn, request)
+		case "/a/b":
+			redirectTo(func(*http.Request) string { return "c?y=2" })(conn, request)
+		default:
+			conn.Write([]byte("HTTP/1.1 204 No Content\r\n\r\n"))
+		}
+	})
+	scanner := newTestScanner(t, port, func(flags *Flags) {
+		flags.MaxRedirects = 2
+		flags.FollowLocalhostRedirects = true
+	})
+	status, res, err := scanner.Scan(localTarget())
+	if status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("got %s, %v", status, err)
+	}
+	want := "http://127.0.0.1:" + strconv.Itoa(port) + "/a/c?y=2"
+	if got := res.(*Results).FinalURL; got != want {
+		t.Errorf("got final URL %q, wanted %q", got, want)
+	}
+}
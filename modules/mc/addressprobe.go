@@ -0,0 +1,50 @@
+package mc
+
+import (
+	"strings"
+
+	"github.com/zmap/zgrab2"
+)
+
+const (
+	// maxAddressLength is the longest server address, in characters, the
+	// protocol allows in a handshake.
+	maxAddressLength = 255
+
+	// maxAddressProbe caps --address-probe, so that the probe stays a
+	// modest packet however it is configured.
+	maxAddressProbe = 1024
+)
+
+// Results.AddressProbe values.
+const (
+	addressProbeAccepted = "accepted"
+	addressProbeKicked   = "kicked"
+	addressProbeClosed   = "closed"
+	addressProbeTimeout  = "timeout"
+)
+
+// probeAddress fetches the status again, on a connection of its own, with
+// a handshake whose server address is --address-probe bytes long, and
+// records how the server took it: a status with a version means it
+// accepted the address, any other packet that it kicked the client, and no
+// packet at all that it closed the connection or let it time out. This is a
+// robustness probe, sending one handshake no longer than maxAddressProbe;
+// it does not retry or escalate.
+func (s *Scanner) probeAddress(target *zgrab2.ScanTarget, results *Results) {
+	host := strings.Repeat("a", s.config.AddressProbe)
+	results.AddressProbeSize = len(host)
+	packet, err := s.fetchStatus(target, results, host, s.config.ProtocolVer)
+	switch {
+	case err == nil:
+		if status := parseStatus(packet); status != nil && status.Version.Name != "" {
+			results.AddressProbe = addressProbeAccepted
+		} else {
+			results.AddressProbe = addressProbeKicked
+		}
+	case zgrab2.IsTimeoutError(err):
+		results.AddressProbe = addressProbeTimeout
+	default:
+		results.AddressProbe = addressProbeClosed
+	}
+}
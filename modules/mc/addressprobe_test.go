@@ -0,0 +1,50 @@
+package mc
+
+import (
+	"testing"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestAddressProbe(t *testing.T) {
+	tests := map[string]struct {
+		answer func(host string, protocol int) []byte
+		want   string
+	}{
+		"accepted": {
+			answer: func(string, int) []byte { return []byte(`{"version":{"name":"1.20.4","protocol":765}}`) },
+			want:   addressProbeAccepted,
+		},
+		"kicked": {
+			answer: func(host string, _ int) []byte {
+				if len(host) > maxAddressLength {
+					return []byte(`{"text":"Invalid hostname"}`)
+				}
+				return []byte(`{"version":{"name":"1.20.4","protocol":765}}`)
+			},
+			want: addressProbeKicked,
+		},
+		"closed": {
+			answer: func(host string, _ int) []byte {
+				if len(host) > maxAddressLength {
+					return nil
+				}
+				return []byte(`{"version":{"name":"1.20.4","protocol":765}}`)
+			},
+			want: addressProbeClosed,
+		},
+	}
+	for name, test := range tests {
+		target := serveHandshakes(t, test.answer)
+		scanner := newTestScanner(testHandshake(765))
+		scanner.config.AddressProbe = 300
+		status, res, err := scanner.Scan(target)
+		if err != nil || status != zgrab2.SCAN_SUCCESS {
+			t.Fatalf("%s: received unexpected status: %s (%v)", name, status, err)
+		}
+		results := res.(*Results)
+		if results.AddressProbe != test.want || results.AddressProbeSize != 300 {
+			t.Errorf("%s: got %q for %d bytes", name, results.AddressProbe, results.AddressProbeSize)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package mc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+// RakNet unconnected ping and pong packet IDs.
+const (
+	raknetUnconnectedPing = 0x01
+	raknetUnconnectedPong = 0x1C
+)
+
+// raknetMagic marks RakNet offline messages.
+var raknetMagic = []byte{0x00, 0xff, 0xff, 0x00, 0xfe, 0xfe, 0xfe, 0xfe, 0xfd, 0xfd, 0xfd, 0xfd, 0x12, 0x34, 0x56, 0x78}
+
+// The default Bedrock ports, expected by --bedrock unless --expected-ports
+// is given.
+const (
+	defaultJavaPorts    = "25565,25566"
+	defaultBedrockPorts = "19132,19133"
+)
+
+// BedrockStatus is the server status from a Bedrock unconnected pong. Fields
+// after the player counts were added over time and may be missing.
+// ServerGUID is kept as the decimal string sent, since it does not fit the
+// integers JSON consumers can hold exactly; with the MOTD it identifies a
+// server across IP changes.
+type BedrockStatus struct {
+	Edition         string `json:"edition"`
+	MOTD            string `json:"motd"`
+	ProtocolVersion int    `json:"protocol_version"`
+	Version         string `json:"version"`
+	OnlinePlayers   int    `json:"online_players"`
+	MaxPlayers      int    `json:"max_players"`
+	ServerGUID      string `json:"server_guid,omitempty"`
+	LevelName       string `json:"level_name,omitempty"`
+	GameMode        string `json:"game_mode,omitempty"`
+	GameModeNumeric *int   `json:"game_mode_numeric,omitempty"`
+	PortV4          int    `json:"port_v4,omitempty"`
+	PortV6          int    `json:"port_v6,omitempty"`
+
+	// Raw is the whole server ID string, kept when a trailing field is
+	// not a number or there are more fields than are known.
+	Raw string `json:"raw,omitempty"`
+}
+
+// bedrockPing builds an unconnected ping: the packet ID, the client's time
+// in milliseconds, the RakNet magic and a client GUID.
+func bedrockPing(now time.Time, guid uint64) []byte {
+	b := []byte{raknetUnconnectedPing}
+	b = binary.BigEndian.AppendUint64(b, uint64(now.UnixMilli()))
+	b = append(b, raknetMagic...)
+	return binary.BigEndian.AppendUint64(b, guid)
+}
+
+// bedrockPongString extracts the server ID string from an unconnected pong:
+// the packet ID, the echoed time, the server GUID, the magic, then a string
+// with a uint16 length.
+func bedrockPongString(pong []byte) (string, error) {
+	const header = 1 + 8 + 8 + 16 + 2
+	if len(pong) < header || pong[0] != raknetUnconnectedPong {
+		return "", errors.New("not a RakNet unconnected pong")
+	}
+	if !bytes.Equal(pong[17:33], raknetMagic) {
+		return "", errors.New("bad RakNet magic")
+	}
+	length := int(binary.BigEndian.Uint16(pong[33:]))
+	if len(pong) < header+length {
+		return "", errors.New("truncated RakNet pong")
+	}
+	return string(pong[header : header+length]), nil
+}
+
+// parseBedrockStatus parses the semicolon-delimited server ID string,
+// "edition;motd;protocol;version;online;max;server guid;level;game mode;game
+// mode number;v4 port;v6 port;". Strings with fewer than the first six
+// fields, or non-numeric counts, give nil. Older servers stop before the
+// game mode number.
+func parseBedrockStatus(id string) *BedrockStatus {
+	fields := strings.Split(id, ";")
+	if len(fields) < 6 {
+		return nil
+	}
+	status := &BedrockStatus{Edition: fields[0], MOTD: fields[1], Version: fields[3]}
+	var err error
+	if status.ProtocolVersion, err = strconv.Atoi(fields[2]); err != nil {
+		return nil
+	}
+	if status.OnlinePlayers, err = strconv.Atoi(fields[4]); err != nil {
+		return nil
+	}
+	if status.MaxPlayers, err = strconv.Atoi(fields[5]); err != nil {
+		return nil
+	}
+	optional := []*string{&status.ServerGUID, &status.LevelName, &status.GameMode}
+	for i, field := range optional {
+		if len(fields) > 6+i {
+			*field = fields[6+i]
+		}
+	}
+	anomalous := false
+	number := func(i int) (int, bool) {
+		if len(fields) <= i || fields[i] == "" {
+			return 0, false
+		}
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			anomalous = true
+			return 0, false
+		}
+		return n, true
+	}
+	if mode, ok := number(9); ok {
+		status.GameModeNumeric = &mode
+	}
+	status.PortV4, _ = number(10)
+	status.PortV6, _ = number(11)
+	// The string usually ends with a ';', leaving an empty last field.
+	for i := 12; i < len(fields); i++ {
+		if fields[i] != "" {
+			anomalous = true
+		}
+	}
+	if anomalous {
+		status.Raw = id
+	}
+	return status
+}
+
+// scanBedrock sends an unconnected ping over UDP and parses the pong. UDP
+// gives no connection close, so a silent host is only detected by the read
+// timing out after --read-timeout, or --timeout if that is not set.
+func (s *Scanner) scanBedrock(target *zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	results := new(Results)
+	conn, err := s.openUDP(target, results)
+	if errors.Is(err, ErrExcluded) {
+		return zgrab2.SCAN_SKIPPED, &Results{Skipped: skippedExcluded}, ErrExcluded
+	}
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	defer conn.Close()
+
+	sent := time.Now()
+	if _, err := conn.Write(bedrockPing(sent, rand.Uint64())); err != nil {
+		return zgrab2.TryGetScanStatus(err), results, err
+	}
+	if timeout := s.responseTimeout(); timeout > 0 {
+		conn.SetReadDeadline(sent.Add(timeout))
+	}
+	// A pong fits in one datagram.
+	buf := make([]byte, 1<<16)
+	n, err := conn.Read(buf)
+	if err != nil {
+		if zgrab2.IsTimeoutError(err) {
+			results.TimeoutPhase = phaseRead
+		}
+		return zgrab2.TryGetScanStatus(err), results, err
+	}
+	results.LatencyMS = float64(time.Since(sent)) / float64(time.Millisecond)
+	if s.config.RawBanner {
+		results.Banner1 = s.encodeBanner(buf[:n])
+	}
+	if s.config.Decode {
+		results.Banner1Text = decodeText(buf[:n])
+	}
+	results.responses = append(results.responses, buf[:n])
+	id, err := bedrockPongString(buf[:n])
+	if err != nil {
+		return zgrab2.SCAN_PROTOCOL_ERROR, results, err
+	}
+	if results.Bedrock = parseBedrockStatus(id); results.Bedrock == nil {
+		return zgrab2.SCAN_PROTOCOL_ERROR, results, errors.New("malformed Bedrock server ID string")
+	}
+	results.MOTD = results.Bedrock.MOTD
+	results.MOTDClean = stripFormatting(results.MOTD)
+	s.matchTrap(buf[:n], results)
+	return s.succeed(target, results)
+}
@@ -0,0 +1,105 @@
+package mc
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+const testBedrockID = "MCPE;Dedicated Server;594;1.20.10;2;10;13253860892328930865;Bedrock level;Survival;1;19132;19133;"
+
+// bedrockPong builds the unconnected pong answering ping with id.
+func bedrockPong(ping []byte, id string) []byte {
+	b := []byte{raknetUnconnectedPong}
+	b = append(b, ping[1:9]...) // echoed time
+	b = binary.BigEndian.AppendUint64(b, 0x1234)
+	b = append(b, raknetMagic...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(id)))
+	return append(b, id...)
+}
+
+func TestParseBedrockStatus(t *testing.T) {
+	survival := 1
+	want := BedrockStatus{
+		Edition: "MCPE", MOTD: "Dedicated Server", ProtocolVersion: 594, Version: "1.20.10",
+		OnlinePlayers: 2, MaxPlayers: 10, ServerGUID: "13253860892328930865", LevelName: "Bedrock level",
+		GameMode: "Survival", GameModeNumeric: &survival, PortV4: 19132, PortV6: 19133,
+	}
+	if got := parseBedrockStatus(testBedrockID); got == nil || !reflect.DeepEqual(*got, want) {
+		t.Errorf("parseBedrockStatus() = %+v, wanted %+v", got, want)
+	}
+	short := BedrockStatus{Edition: "MCPE", MOTD: "Old", ProtocolVersion: 291, Version: "1.7.0", OnlinePlayers: 0, MaxPlayers: 20}
+	if got := parseBedrockStatus("MCPE;Old;291;1.7.0;0;20"); got == nil || !reflect.DeepEqual(*got, short) {
+		t.Errorf("parseBedrockStatus() = %+v, wanted %+v", got, short)
+	}
+	// Older servers stop after the game mode name.
+	noNumber := "MCPE;Old;390;1.14.60;0;20;123;world;Creative"
+	if got := parseBedrockStatus(noNumber); got == nil || got.GameMode != "Creative" || got.GameModeNumeric != nil || got.Raw != "" {
+		t.Errorf("parseBedrockStatus(%q) = %+v", noNumber, got)
+	}
+	odd := "MCPE;Odd;594;1.20.10;0;20;123;world;Survival;x;19132;19133;extra;"
+	if got := parseBedrockStatus(odd); got == nil || got.GameModeNumeric != nil || got.PortV4 != 19132 || got.Raw != odd {
+		t.Errorf("parseBedrockStatus(%q) = %+v", odd, got)
+	}
+	for _, bad := range []string{"", "MCPE;too;few", "MCPE;motd;x;1.0;0;20"} {
+		if got := parseBedrockStatus(bad); got != nil {
+			t.Errorf("parseBedrockStatus(%q) = %+v, wanted nil", bad, got)
+		}
+	}
+}
+
+func TestBedrockPongString(t *testing.T) {
+	ping := bedrockPing(time.Now(), 1)
+	if got, err := bedrockPongString(bedrockPong(ping, testBedrockID)); err != nil || got != testBedrockID {
+		t.Errorf("bedrockPongString() = %q, %v", got, err)
+	}
+	pong := bedrockPong(ping, testBedrockID)
+	if _, err := bedrockPongString(pong[:len(pong)-1]); err == nil {
+		t.Errorf("truncated pong: expected an error")
+	}
+	pong[20] ^= 0xff
+	if _, err := bedrockPongString(pong); err == nil {
+		t.Errorf("bad magic: expected an error")
+	}
+}
+
+func TestScanBedrock(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		n, addr, err := server.ReadFromUDP(buf)
+		if err != nil || n != 33 || buf[0] != raknetUnconnectedPing {
+			return
+		}
+		server.WriteToUDP(bedrockPong(buf[:n], testBedrockID), addr)
+	}()
+	port := uint(server.LocalAddr().(*net.UDPAddr).Port)
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+
+	scanner := newTestScanner(nil)
+	scanner.config.Bedrock = true
+	status, res, err := scanner.Scan(target)
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("received unexpected status: %s (%v)", status, err)
+	}
+	results := res.(*Results)
+	if results.Bedrock == nil || results.Bedrock.ProtocolVersion != 594 || results.MOTD != "Dedicated Server" {
+		t.Errorf("received unexpected results: %+v", results)
+	}
+
+	// Nothing answers a second ping, so the read times out after
+	// --read-timeout, well before --timeout.
+	scanner.config.ReadTimeout = 100 * time.Millisecond
+	status, res, _ = scanner.Scan(target)
+	if status != zgrab2.SCAN_IO_TIMEOUT || res.(*Results).TimeoutPhase != phaseRead {
+		t.Errorf("silent server: got %s, %+v", status, res)
+	}
+}
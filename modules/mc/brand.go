@@ -0,0 +1,185 @@
+package mc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/varint"
+)
+
+// brandChannel is the plugin channel clients announce their brand on.
+const brandChannel = "minecraft:brand"
+
+// Serverbound login packet IDs.
+const (
+	loginPluginResponseID = 0x02
+	loginAcknowledgedID   = 0x03
+)
+
+// Protocol versions that changed the configuration state.
+const (
+	protocolConfigState = 764 // 1.20.2 added the configuration state
+	protocolNBTChat     = 765 // 1.20.3 sent chat as NBT rather than JSON
+	protocolKnownPacks  = 766 // 1.20.5 added Known Packs, shifting packet IDs
+)
+
+// maxBrandPackets bounds how many configuration packets are read while
+// waiting for the server to answer the brand.
+const maxBrandPackets = 64
+
+// Results.BrandOutcome values.
+const (
+	brandProceeded = "proceeded"
+	brandKicked    = "kicked"
+	brandClosed    = "closed"
+)
+
+// configPacketIDs returns the IDs, in protoVer's configuration state, of the
+// serverbound plugin message and of the clientbound disconnect and finish
+// configuration packets.
+func configPacketIDs(protoVer int) (plugin, disconnect, finish int) {
+	if protoVer >= protocolKnownPacks {
+		return 0x02, 0x02, 0x03
+	}
+	return 0x01, 0x01, 0x02
+}
+
+// brandConn reads and writes login and configuration packets, in compressed
+// framing once the server has turned compression on.
+type brandConn struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	maxLength  int
+	compressed bool
+}
+
+func (c *brandConn) read() ([]byte, error) {
+	var data []byte
+	var count *ReadCount
+	var err error
+	if c.compressed {
+		data, count, err = readCompressedPacket(c.reader, c.maxLength)
+	} else {
+		data, count, err = readFramedPacket(c.reader, c.maxLength)
+	}
+	if err == nil && count.Read < count.Declared {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil && len(data) == 0 {
+		err = errors.New("empty packet")
+	}
+	return data, err
+}
+
+func (c *brandConn) write(id int, payload []byte) error {
+	packet := buildPacket(id, payload)
+	if c.compressed {
+		packet = compressedFrame(packet)
+	}
+	_, err := c.conn.Write(packet)
+	return err
+}
+
+// sendBrand carries the login on from first, the server's reply to Login
+// Start, to the configuration state, and announces --client-brand there.
+// Set Compression is followed and login plugin requests are declined, as a
+// vanilla client declines them; a server that asks for encryption is left
+// there, since it would take an authenticated session to go on. Once the
+// brand is sent, the server's next packets are read until it kicks the
+// client, finishes the configuration, or stops sending: a kick, with its
+// reason, is recorded as kicked, and the other two as proceeded. A
+// connection that ends without either is recorded as closed.
+func (s *Scanner) sendBrand(conn net.Conn, phase *deadlineReader, reader *bufio.Reader, first []byte, protoVer int, results *Results) {
+	c := &brandConn{conn: conn, reader: reader, maxLength: maxDecompressed}
+	packet := first
+	for i := 0; packet[0] != loginSuccessID; i++ {
+		if i == maxBrandPackets {
+			return
+		}
+		r := bytes.NewReader(packet[1:])
+		switch packet[0] {
+		case loginCompressionID:
+			threshold, err := readVarInt(r)
+			if err != nil {
+				return
+			}
+			c.compressed = threshold >= 0
+		case loginPluginRequestID:
+			messageID, err := readVarInt(r)
+			if err != nil {
+				return
+			}
+			if c.write(loginPluginResponseID, append(varint.Append(nil, messageID), 0)) != nil {
+				return
+			}
+		default:
+			return
+		}
+		phase.startPhase(s.config.ReadTimeout)
+		var err error
+		if packet, err = c.read(); err != nil {
+			return
+		}
+	}
+
+	pluginID, disconnectID, finishID := configPacketIDs(protoVer)
+	if c.write(loginAcknowledgedID, nil) != nil {
+		return
+	}
+	message := appendString(appendString(nil, brandChannel), s.config.ClientBrand)
+	if c.write(pluginID, message) != nil {
+		return
+	}
+	results.ClientBrand = s.config.ClientBrand
+	for i := 0; i < maxBrandPackets; i++ {
+		phase.startPhase(s.config.ReadTimeout)
+		packet, err := c.read()
+		switch {
+		case zgrab2.IsTimeoutError(err):
+			results.BrandOutcome = brandProceeded
+			return
+		case err != nil:
+			results.BrandOutcome = brandClosed
+			return
+		case int(packet[0]) == disconnectID:
+			results.BrandOutcome = brandKicked
+			results.BrandDisconnect = configDisconnectReason(packet[1:], protoVer)
+			return
+		case int(packet[0]) == finishID:
+			results.BrandOutcome = brandProceeded
+			return
+		}
+	}
+	results.BrandOutcome = brandProceeded
+}
+
+// configDisconnectReason returns the text of a configuration-state
+// disconnect reason: a JSON chat component before 1.20.3, and an NBT one
+// from then on, of which only a plain string tag is read.
+func configDisconnectReason(payload []byte, protoVer int) string {
+	r := bytes.NewReader(payload)
+	if protoVer < protocolNBTChat {
+		reason, err := readString(r)
+		if err != nil {
+			return ""
+		}
+		return flattenChat(json.RawMessage(reason))
+	}
+	const nbtString = 0x08
+	if tag, err := r.ReadByte(); err != nil || tag != nbtString {
+		return ""
+	}
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil || int(length) > r.Len() {
+		return ""
+	}
+	text := make([]byte, length)
+	r.Read(text)
+	return string(text)
+}
@@ -0,0 +1,148 @@
+package mc
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/varint"
+)
+
+// serveBrand plays an offline-mode 1.20.4 server up to the client brand:
+// it optionally turns compression on, sends a login plugin request, then
+// Login Success, and answers the brand with whatever react returns for it,
+// or nothing if that is nil.
+func serveBrand(t *testing.T, compress bool, react func(brand string) []byte) zgrab2.ScanTarget {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err := readTestPacket(conn); err != nil {
+			return
+		}
+		if _, err := readTestPacket(conn); err != nil {
+			return
+		}
+		// Packets in compressed framing carry an uncompressed length of 0
+		// and the packet as is.
+		send := func(id int, payload []byte) {
+			packet := framePacket(id, payload)
+			if compress {
+				packet = compressedFrame(packet)
+			}
+			conn.Write(packet)
+		}
+		receive := func() []byte {
+			packet, err := readTestPacket(conn)
+			if err != nil || !compress {
+				return packet
+			}
+			if len(packet) == 0 || packet[0] != 0 {
+				return nil
+			}
+			return packet[1:]
+		}
+		if compress {
+			conn.Write(framePacket(loginCompressionID, varint.Append(nil, 256)))
+		}
+		send(loginPluginRequestID, append(appendString([]byte{7}, "velocity:player_info"), 1))
+		if reply := receive(); !bytes.Equal(reply, []byte{loginPluginResponseID, 7, 0}) {
+			t.Errorf("plugin response: got %x", reply)
+			return
+		}
+		send(loginSuccessID, append(make([]byte, 16), appendString(nil, loginName)...))
+		if ack := receive(); !bytes.Equal(ack, []byte{loginAcknowledgedID}) {
+			t.Errorf("login acknowledged: got %x", ack)
+			return
+		}
+		message := receive()
+		r := bytes.NewReader(message[1:])
+		channel, _ := readString(r)
+		brand, _ := readString(r)
+		if message[0] != 0x01 || channel != brandChannel {
+			t.Errorf("plugin message: got %x", message)
+			return
+		}
+		if reply := react(brand); reply != nil {
+			send(int(reply[0]), reply[1:])
+		}
+		// Wait for the client to give up.
+		conn.Read(make([]byte, 1))
+	}()
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	return zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+}
+
+func TestClientBrand(t *testing.T) {
+	// A 1.20.3+ disconnect reason as an NBT string tag.
+	kick := append([]byte{0x01, 0x08, 0x00, byte(len("Use the official launcher"))}, "Use the official launcher"...)
+	tests := map[string]struct {
+		compress   bool
+		react      func(brand string) []byte
+		outcome    string
+		disconnect string
+	}{
+		"kicked": {
+			react: func(brand string) []byte {
+				if brand == "vanilla" {
+					return nil
+				}
+				return kick
+			},
+			outcome:    brandKicked,
+			disconnect: "Use the official launcher",
+		},
+		"finished": {
+			react:   func(string) []byte { return []byte{0x02} },
+			outcome: brandProceeded,
+		},
+		"silent": {
+			compress: true,
+			react:    func(string) []byte { return nil },
+			outcome:  brandProceeded,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			scanner := newTestScanner(nil)
+			scanner.config.LoginProbe = true
+			scanner.config.ProtocolVer = -1
+			scanner.config.ClientBrand = "fabric"
+			scanner.config.ReadTimeout = 200 * time.Millisecond
+			status, res, err := scanner.Scan(serveBrand(t, test.compress, test.react))
+			if err != nil || status != zgrab2.SCAN_SUCCESS {
+				t.Fatalf("got %s, %v", status, err)
+			}
+			results := res.(*Results)
+			if results.ClientBrand != "fabric" || results.BrandOutcome != test.outcome || results.BrandDisconnect != test.disconnect {
+				t.Errorf("got brand %q, outcome %q, disconnect %q", results.ClientBrand, results.BrandOutcome, results.BrandDisconnect)
+			}
+		})
+	}
+}
+
+func TestClientBrandOnlineMode(t *testing.T) {
+	encryption := appendString(appendString(appendString(nil, ""), "key"), "abcd")
+	scanner := newTestScanner(nil)
+	scanner.config.LoginProbe = true
+	scanner.config.ProtocolVer = -1
+	scanner.config.ClientBrand = "fabric"
+	status, res, err := scanner.Scan(serveLogin(t, framePacket(loginEncryptionID, encryption)))
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("got %s, %v", status, err)
+	}
+	// The login cannot go on without authenticating, so no brand is sent.
+	if results := res.(*Results); !results.OnlineMode || results.ClientBrand != "" || results.BrandOutcome != "" {
+		t.Errorf("got %+v", results)
+	}
+}
@@ -0,0 +1,41 @@
+package mc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/zmap/zgrab2"
+)
+
+// catchAllHost makes up a server address no real virtual host answers to.
+func catchAllHost() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "zgrab2-" + hex.EncodeToString(b) + ".invalid"
+}
+
+// probeCatchAll fetches the status twice more, on connections of their own:
+// once addressed to the target's own name, once to a made-up one. A server
+// that routes by virtual host answers them differently, while a catch-all
+// proxy or honeypot sends the same bytes to both. Nothing is recorded if
+// either fetch fails.
+func (s *Scanner) probeCatchAll(target *zgrab2.ScanTarget, results *Results) {
+	host, _ := s.handshakeAddr(target, &Results{SRVHost: results.SRVHost, SRVPort: results.SRVPort})
+	fake := catchAllHost()
+	if s.config.FML {
+		fake += fmlMarker(s.config.ProtocolVer)
+	}
+	var hashes []string
+	for _, h := range []string{host, fake} {
+		status, err := s.fetchStatus(target, results, h, s.config.ProtocolVer)
+		if err != nil {
+			return
+		}
+		sum := sha256.Sum256(status)
+		hashes = append(hashes, hex.EncodeToString(sum[:]))
+	}
+	results.CatchAllHost = fake
+	results.CatchAllHashes = hashes
+	results.CatchAll = hashes[0] == hashes[1]
+}
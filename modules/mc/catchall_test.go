@@ -0,0 +1,41 @@
+package mc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestCatchAll(t *testing.T) {
+	tests := map[string]struct {
+		answer   func(host string, protocol int) []byte
+		catchAll bool
+	}{
+		"catch-all": {
+			answer:   func(string, int) []byte { return []byte(`{"description":"same for all"}`) },
+			catchAll: true,
+		},
+		"virtual hosts": {
+			answer: func(host string, _ int) []byte {
+				if strings.HasSuffix(host, ".invalid") {
+					return []byte(`{"description":"unknown host"}`)
+				}
+				return []byte(`{"description":"` + host + `"}`)
+			},
+		},
+	}
+	for name, test := range tests {
+		target := serveHandshakes(t, test.answer)
+		scanner := newTestScanner(testHandshake(765))
+		scanner.config.CatchAll = true
+		status, res, err := scanner.Scan(target)
+		if err != nil || status != zgrab2.SCAN_SUCCESS {
+			t.Fatalf("%s: received unexpected status: %s (%v)", name, status, err)
+		}
+		results := res.(*Results)
+		if results.CatchAll != test.catchAll || len(results.CatchAllHashes) != 2 || !strings.HasSuffix(results.CatchAllHost, ".invalid") {
+			t.Errorf("%s: got catch_all %v, host %q, hashes %q", name, results.CatchAll, results.CatchAllHost, results.CatchAllHashes)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package mc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// motdTemplate returns a cleaned MOTD with the hosts and addresses it
+// advertises replaced by a placeholder, so that servers of one campaign
+// that each name their own address share a template.
+func motdTemplate(motd string) string {
+	return strings.ToLower(strings.TrimSpace(motdHostPattern.ReplaceAllString(motd, "<host>")))
+}
+
+// clusterKeys returns the traits --cluster links servers on: the favicon
+// hash, and the MOTD template together with the version and player limit.
+// Default and empty MOTDs are left out, since unrelated servers share them.
+func clusterKeys(results *Results) []string {
+	var keys []string
+	if results.Favicon != nil {
+		keys = append(keys, "favicon\x00"+results.Favicon.SHA256)
+	}
+	if !results.DefaultMOTD {
+		status := results.Status
+		keys = append(keys, strings.Join([]string{
+			"motd", motdTemplate(results.MOTDClean), status.Version.Name,
+			strconv.Itoa(status.Version.Protocol), strconv.Itoa(status.Players.Max),
+		}, "\x00"))
+	}
+	return keys
+}
+
+// clusterSet is an in-memory union-find over the traits of the servers seen
+// so far in the scan. Servers sharing any trait, directly or through other
+// servers, are in one cluster. It is shared by all of the scan's senders.
+type clusterSet struct {
+	mu sync.Mutex
+	// parent links each trait towards the root of its cluster; a root is
+	// its own parent. order records when each trait was first seen, and
+	// servers counts the servers in each root's cluster.
+	parent  map[string]string
+	order   map[string]int
+	servers map[string]int
+}
+
+func newClusterSet() *clusterSet {
+	return &clusterSet{parent: make(map[string]string), order: make(map[string]int), servers: make(map[string]int)}
+}
+
+func (c *clusterSet) find(key string) string {
+	if _, ok := c.parent[key]; !ok {
+		c.parent[key] = key
+		c.order[key] = len(c.order)
+	}
+	for c.parent[key] != key {
+		// Path halving.
+		c.parent[key] = c.parent[c.parent[key]]
+		key = c.parent[key]
+	}
+	return key
+}
+
+// union merges the clusters of a and b, keeping the older root, so that a
+// cluster's ID only changes when it is merged into an older one.
+func (c *clusterSet) union(a, b string) string {
+	a, b = c.find(a), c.find(b)
+	if a == b {
+		return a
+	}
+	if c.order[b] < c.order[a] {
+		a, b = b, a
+	}
+	c.parent[b] = a
+	c.servers[a] += c.servers[b]
+	delete(c.servers, b)
+	return a
+}
+
+// add records a server with the given traits, returning its cluster's ID
+// and the number of servers in the cluster so far, the server included.
+func (c *clusterSet) add(keys []string) (string, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	root := c.find(keys[0])
+	for _, key := range keys[1:] {
+		root = c.union(root, key)
+	}
+	c.servers[root]++
+	sum := sha256.Sum256([]byte(root))
+	return hex.EncodeToString(sum[:8]), c.servers[root]
+}
+
+// cluster assigns the server in results to a --cluster cluster.
+func (s *Scanner) cluster(results *Results) {
+	if keys := clusterKeys(results); len(keys) > 0 {
+		results.ClusterID, results.ClusterSize = s.clusters.add(keys)
+	}
+}
@@ -0,0 +1,81 @@
+package mc
+
+import (
+	"testing"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestMOTDTemplate(t *testing.T) {
+	for motd, want := range map[string]string{
+		"Join us at play.example.com!":   "join us at <host>!",
+		"  Hub 203.0.113.7:25565  ":      "hub <host>",
+		"Now on 1.20.4":                  "now on 1.20.4",
+		"Vote at MC-Example.net or here": "vote at <host> or here",
+	} {
+		if got := motdTemplate(motd); got != want {
+			t.Errorf("%q: got %q, wanted %q", motd, got, want)
+		}
+	}
+}
+
+func TestCluster(t *testing.T) {
+	server := func(motd, favicon string, max int) *Results {
+		results := &Results{MOTDClean: motd, Status: &StatusResponse{}}
+		results.Status.Version.Name, results.Status.Version.Protocol = "Paper 1.20.4", 765
+		results.Status.Players.Max = max
+		if favicon != "" {
+			results.Favicon = &Favicon{SHA256: favicon}
+		}
+		_, results.DefaultMOTD = matchDefaultMOTD(motd, builtinDefaultMOTDs)
+		return results
+	}
+	scanner := newTestScanner(nil)
+	scanner.clusters = newClusterSet()
+	servers := []*Results{
+		server("Join play1.example.com", "f1", 20),
+		// The same template, naming another host.
+		server("Join play2.example.com", "f2", 20),
+		// Linked to the first by its favicon.
+		server("Survival", "f1", 100),
+		// Linked to the third by its MOTD and limit, and so to the others.
+		server("Survival", "", 100),
+		server("Something else", "f3", 20),
+		// A default MOTD and no favicon: nothing to link on.
+		server("A Minecraft Server", "", 20),
+	}
+	for _, results := range servers {
+		scanner.cluster(results)
+	}
+	for i, want := range []int{1, 2, 3, 4} {
+		if got := servers[i].ClusterSize; got != want || servers[i].ClusterID != servers[0].ClusterID {
+			t.Errorf("server %d: got cluster %s of %d, wanted %s of %d", i, servers[i].ClusterID, got, servers[0].ClusterID, want)
+		}
+	}
+	if other := servers[4]; other.ClusterSize != 1 || other.ClusterID == servers[0].ClusterID {
+		t.Errorf("unrelated server: got cluster %s of %d", other.ClusterID, other.ClusterSize)
+	}
+	if vanilla := servers[5]; vanilla.ClusterID != "" || vanilla.ClusterSize != 0 {
+		t.Errorf("default MOTD: got cluster %s of %d", vanilla.ClusterID, vanilla.ClusterSize)
+	}
+}
+
+func TestScanCluster(t *testing.T) {
+	scanner := newTestScanner(testHandshake(765))
+	scanner.clusters = newClusterSet()
+	var ids []string
+	for _, host := range []string{"one.example.net", "two.example.net"} {
+		status, res, err := scanner.Scan(serveStatus(t, []byte(`{"version":{"name":"1.20.4","protocol":765},"players":{"max":20,"online":1},"description":"Play at `+host+`"}`)))
+		if err != nil || status != zgrab2.SCAN_SUCCESS {
+			t.Fatalf("received unexpected status: %s (%v)", status, err)
+		}
+		results := res.(*Results)
+		if results.ClusterSize != len(ids)+1 {
+			t.Errorf("%s: got cluster_size %d, wanted %d", host, results.ClusterSize, len(ids)+1)
+		}
+		ids = append(ids, results.ClusterID)
+	}
+	if ids[0] == "" || ids[0] != ids[1] {
+		t.Errorf("got cluster IDs %q, wanted one shared ID", ids)
+	}
+}
@@ -0,0 +1,101 @@
+package mc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/varint"
+)
+
+const (
+	// setCompressionID is the ID of the Set Compression packet. It belongs
+	// to the login state, but a few servers send it during status too.
+	setCompressionID = 0x03
+
+	// maxDecompressed caps the declared uncompressed size of a packet,
+	// matching the protocol's own limit.
+	maxDecompressed = 1 << 21
+)
+
+// isSetCompression reports whether packet, read where the status response
+// was expected, is a Set Compression packet instead.
+func isSetCompression(packet []byte) bool {
+	return len(packet) >= 2 && packet[0] == setCompressionID
+}
+
+func compressionError(msg string) error {
+	return zgrab2.NewScanError(zgrab2.SCAN_PROTOCOL_ERROR, errors.New(msg))
+}
+
+// readCompressedPacket reads a packet in compressed framing: the packet
+// length, then the uncompressed length (0 if the packet was sent as is),
+// then the possibly zlib-compressed packet ID and data. Both the packet as
+// sent and the decompressed packet are limited to maxLength bytes, and the
+// latter also to maxDecompressed. The ReadCount is for the packet as sent,
+// before decompression.
+func readCompressedPacket(r io.Reader, maxLength int) ([]byte, *ReadCount, error) {
+	length, err := readVarInt(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if length < 1 || length > maxLength {
+		return nil, nil, compressionError("bad compressed packet length")
+	}
+	body, count, err := readPacket(r, length)
+	if err != nil || count.Read < count.Declared {
+		return body, count, err
+	}
+	br := bytes.NewReader(body)
+	dataLength, err := readVarInt(br)
+	if err != nil {
+		return body, count, compressionError("bad uncompressed length")
+	}
+	rest := body[len(body)-br.Len():]
+	if dataLength == 0 {
+		return rest, count, nil
+	}
+	if dataLength < 0 || dataLength > maxLength || dataLength > maxDecompressed {
+		return body, count, compressionError("uncompressed length out of range")
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(rest))
+	if err != nil {
+		return body, count, compressionError("bad zlib stream: " + err.Error())
+	}
+	data, err := io.ReadAll(io.LimitReader(zr, int64(dataLength)+1))
+	if err != nil {
+		return body, count, compressionError("bad zlib stream: " + err.Error())
+	}
+	if len(data) != dataLength {
+		return body, count, compressionError("uncompressed length mismatch")
+	}
+	return data, count, nil
+}
+
+// readFramedPacket reads a packet of up to maxLength bytes in the usual
+// uncompressed framing.
+func readFramedPacket(r io.Reader, maxLength int) ([]byte, *ReadCount, error) {
+	length, err := readVarInt(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if length < 1 || length > maxLength {
+		return nil, nil, compressionError("bad packet length")
+	}
+	return readPacket(r, length)
+}
+
+// compressedFrame re-frames a length-prefixed probe for a connection with
+// compression on, sending it uncompressed.
+func compressedFrame(probe []byte) []byte {
+	r := bytes.NewReader(probe)
+	if _, err := readVarInt(r); err != nil {
+		return probe
+	}
+	packet := probe[len(probe)-r.Len():]
+	frame := varint.Append(nil, len(packet)+1)
+	frame = append(frame, 0x00)
+	return append(frame, packet...)
+}
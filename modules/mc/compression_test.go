@@ -0,0 +1,140 @@
+package mc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/varint"
+)
+
+// TestScanStatusCompression checks a server that sends Set Compression ahead
+// of a zlib-compressed status response, and expects the ping in compressed
+// framing.
+func TestScanStatusCompression(t *testing.T) {
+	status := []byte(`{"version":{"name":"1.20.4","protocol":765},"description":"compressed"}`)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		for i := 0; i < 2; i++ {
+			if _, err := readTestPacket(conn); err != nil {
+				return
+			}
+		}
+		conn.Write(framePacket(setCompressionID, varint.Append(nil, 16)))
+
+		packet := append([]byte{0x00}, varint.Append(nil, len(status))...)
+		packet = append(packet, status...)
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(packet)
+		zw.Close()
+		body := append(varint.Append(nil, len(packet)), compressed.Bytes()...)
+		conn.Write(append(varint.Append(nil, len(body)), body...))
+
+		ping, err := readTestPacket(conn)
+		if err != nil || len(ping) == 0 || ping[0] != 0x00 {
+			return
+		}
+		// echo the ping, still in compressed framing, as the pong
+		conn.Write(append(varint.Append(nil, len(ping)), ping...))
+	}()
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+
+	scanner := newTestScanner(testHandshake(765))
+	scanner.config.RawBanner = true
+	scanStatus, res, err := scanner.Scan(target)
+	if err != nil || scanStatus != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("got %s, %v", scanStatus, err)
+	}
+	results := res.(*Results)
+	if !results.StatusCompression || results.CompressionThreshold != 16 {
+		t.Errorf("got status_compression=%v threshold=%d", results.StatusCompression, results.CompressionThreshold)
+	}
+	banner, _ := hex.DecodeString(results.Banner1)
+	if !bytes.HasSuffix(banner, status) {
+		t.Errorf("banner1 %q does not end with the status", banner)
+	}
+	if want := hex.EncodeToString(testPing[1:]); results.Banner2 != want {
+		t.Errorf("got banner2 %s, wanted %s", results.Banner2, want)
+	}
+}
+
+// TestScanCompressionDisabled checks that a negative Set Compression
+// threshold leaves the rest of the exchange in uncompressed framing.
+func TestScanCompressionDisabled(t *testing.T) {
+	status := []byte(`{"version":{"name":"1.20.4","protocol":765},"description":"plain"}`)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		for i := 0; i < 2; i++ {
+			if _, err := readTestPacket(conn); err != nil {
+				return
+			}
+		}
+		conn.Write(framePacket(setCompressionID, varint.Append(nil, -1)))
+		payload := append(varint.Append(nil, len(status)), status...)
+		conn.Write(framePacket(0x00, payload))
+		ping, err := readTestPacket(conn)
+		if err != nil {
+			return
+		}
+		conn.Write(append(varint.Append(nil, len(ping)), ping...))
+	}()
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+
+	scanStatus, res, err := newTestScanner(testHandshake(765)).Scan(target)
+	if err != nil || scanStatus != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("got %s, %v", scanStatus, err)
+	}
+	results := res.(*Results)
+	if results.StatusCompression || results.CompressionThreshold != -1 {
+		t.Errorf("got status_compression=%v threshold=%d", results.StatusCompression, results.CompressionThreshold)
+	}
+	if results.MOTD != "plain" {
+		t.Errorf("got motd %q", results.MOTD)
+	}
+}
+
+// TestReadCompressedPacketLimit checks that the limit applies to the
+// decompressed packet as well as to the packet as sent.
+func TestReadCompressedPacketLimit(t *testing.T) {
+	packet := bytes.Repeat([]byte{'a'}, 4096)
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(packet)
+	zw.Close()
+	body := append(varint.Append(nil, len(packet)), compressed.Bytes()...)
+	frame := append(varint.Append(nil, len(body)), body...)
+
+	if data, _, err := readCompressedPacket(bytes.NewReader(frame), 4096); err != nil || !bytes.Equal(data, packet) {
+		t.Errorf("within the limit: got %d bytes, %v", len(data), err)
+	}
+	if _, _, err := readCompressedPacket(bytes.NewReader(frame), 4095); err == nil {
+		t.Error("accepted a packet that decompresses beyond the limit")
+	}
+}
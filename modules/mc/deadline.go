@@ -0,0 +1,30 @@
+package mc
+
+import (
+	"net"
+	"time"
+)
+
+// deadlineReader reads from conn under a deadline that covers a whole read
+// phase, such as reading the status response, however many reads it takes.
+// A TimeoutConnection only honours an explicit deadline for a single read,
+// falling back to its own per-read timeout after that, so the deadline is
+// set again before every read.
+type deadlineReader struct {
+	conn     net.Conn
+	deadline time.Time
+}
+
+// startPhase gives the reads from now on timeout to complete.
+func (r *deadlineReader) startPhase(timeout time.Duration) {
+	r.deadline = time.Now().Add(timeout)
+}
+
+func (r *deadlineReader) Read(b []byte) (int, error) {
+	if !r.deadline.IsZero() {
+		if err := r.conn.SetReadDeadline(r.deadline); err != nil {
+			return 0, err
+		}
+	}
+	return r.conn.Read(b)
+}
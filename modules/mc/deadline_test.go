@@ -0,0 +1,32 @@
+package mc
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestDeadlineReaderSilentServer(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	phase := &deadlineReader{conn: client}
+	phase.startPhase(50 * time.Millisecond)
+	done := make(chan error, 1)
+	go func() {
+		_, err := readVarInt(bufio.NewReader(phase))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !zgrab2.IsTimeoutError(err) {
+			t.Errorf("got %v, wanted a timeout", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("readVarInt blocked past the phase deadline")
+	}
+}
@@ -0,0 +1,55 @@
+package mc
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// builtinDefaultMOTDs are the MOTDs servers ship with, used unless
+// --default-motds is given: vanilla and its Spigot and Paper forks,
+// BungeeCord, Velocity and the Bedrock Dedicated Server.
+var builtinDefaultMOTDs = []string{
+	"A Minecraft Server",
+	"Another Bungee server",
+	"A Velocity Server",
+	"Dedicated Server",
+}
+
+// loadMOTDList reads a file of MOTDs or MOTD keywords, such as
+// --default-motds, one per line with formatting codes already stripped, or
+// returns builtin if no file is given. Blank lines are ignored.
+func loadMOTDList(path string, builtin []string) ([]string, error) {
+	if path == "" {
+		return builtin, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var motds []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			motds = append(motds, line)
+		}
+	}
+	return motds, scanner.Err()
+}
+
+// matchDefaultMOTD returns the default MOTD that motd, with formatting codes
+// already stripped, is, and whether it is one. An empty MOTD counts as a
+// default, with an empty match.
+func matchDefaultMOTD(motd string, defaults []string) (string, bool) {
+	motd = strings.TrimSpace(motd)
+	if motd == "" {
+		return "", true
+	}
+	for _, d := range defaults {
+		if motd == d {
+			return d, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,44 @@
+package mc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatchDefaultMOTD(t *testing.T) {
+	tests := map[string]struct {
+		match     string
+		isDefault bool
+	}{
+		"A Minecraft Server":    {"A Minecraft Server", true},
+		" A Velocity Server ":   {"A Velocity Server", true},
+		"":                      {"", true},
+		"A Minecraft Server!!":  {"", false},
+		"My Survival Server":    {"", false},
+		"Another Bungee server": {"Another Bungee server", true},
+	}
+	for motd, test := range tests {
+		if match, ok := matchDefaultMOTD(motd, builtinDefaultMOTDs); match != test.match || ok != test.isDefault {
+			t.Errorf("matchDefaultMOTD(%q) = %q, %v", motd, match, ok)
+		}
+	}
+}
+
+func TestLoadMOTDList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "motds")
+	if err := os.WriteFile(path, []byte("My Default\n\n  Other Default \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	motds, err := loadMOTDList(path, builtinDefaultMOTDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"My Default", "Other Default"}; !reflect.DeepEqual(motds, want) {
+		t.Errorf("got %q, wanted %q", motds, want)
+	}
+	if motds, err := loadMOTDList("", builtinStartingKeywords); err != nil || !reflect.DeepEqual(motds, builtinStartingKeywords) {
+		t.Errorf("no file: got %q (%v)", motds, err)
+	}
+}
@@ -0,0 +1,157 @@
+package mc
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// nonRoutableRanges are skipped unless --allow-private is given: private,
+// loopback, link-local (including cloud metadata endpoints such as
+// 169.254.169.254), documentation, benchmarking, multicast and reserved
+// space.
+var nonRoutableRanges = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+	"2001:db8::/32",
+}
+
+// rangeSet is a set of CIDR blocks stored as a binary trie over the address
+// bits, so a lookup costs at most one step per bit regardless of how many
+// ranges are loaded.
+type rangeSet struct {
+	v4 trieNode
+	v6 trieNode
+}
+
+type trieNode struct {
+	children [2]*trieNode
+	// terminal marks the end of an inserted prefix; every address below it
+	// is in the set.
+	terminal bool
+}
+
+// Add inserts the block into the set.
+func (r *rangeSet) Add(block *net.IPNet) {
+	ones, _ := block.Mask.Size()
+	node := &r.v6
+	ip := block.IP.To16()
+	if ip4 := block.IP.To4(); ip4 != nil && len(block.Mask) == net.IPv4len {
+		node = &r.v4
+		ip = ip4
+	}
+	for i := 0; i < ones; i++ {
+		if node.terminal {
+			return
+		}
+		bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+		if node.children[bit] == nil {
+			node.children[bit] = new(trieNode)
+		}
+		node = node.children[bit]
+	}
+	node.terminal = true
+	node.children = [2]*trieNode{}
+}
+
+// Contains reports whether ip falls inside any block in the set.
+func (r *rangeSet) Contains(ip net.IP) bool {
+	node := &r.v6
+	if ip4 := ip.To4(); ip4 != nil {
+		node = &r.v4
+		ip = ip4
+	} else if ip = ip.To16(); ip == nil {
+		return false
+	}
+	for i := 0; i < len(ip)*8; i++ {
+		if node.terminal {
+			return true
+		}
+		node = node.children[(ip[i/8]>>(7-uint(i%8)))&1]
+		if node == nil {
+			return false
+		}
+	}
+	return node.terminal
+}
+
+// AddString parses an IP address or CIDR block and adds it to the set.
+func (r *rangeSet) AddString(s string) error {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return fmt.Errorf("can't parse %q as an IP address or CIDR block", s)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			r.Add(&net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)})
+		} else {
+			r.Add(&net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+		}
+		return nil
+	}
+	_, block, err := net.ParseCIDR(s)
+	if err != nil {
+		return err
+	}
+	r.Add(block)
+	return nil
+}
+
+// loadRangeFile adds the IP addresses and CIDR blocks listed in the file,
+// one per line, to the set. Blank lines and lines starting with # are
+// ignored.
+func (r *rangeSet) loadRangeFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := r.AddString(line); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineno, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// newExcludedRanges builds the set of ranges the scanner must not touch.
+func newExcludedRanges(allowPrivate bool, path string) (*rangeSet, error) {
+	r := new(rangeSet)
+	if !allowPrivate {
+		for _, block := range nonRoutableRanges {
+			if err := r.AddString(block); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if path != "" {
+		if err := r.loadRangeFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
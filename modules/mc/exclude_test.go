@@ -0,0 +1,105 @@
+package mc
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestRangeSet(t *testing.T) {
+	r, err := newExcludedRanges(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range []string{"198.51.100.7", "2001:4860::/32", "8.8.0.0/16"} {
+		if err := r.AddString(block); err != nil {
+			t.Fatalf("AddString(%q): %v", block, err)
+		}
+	}
+	tests := map[string]bool{
+		"10.1.2.3":             true,
+		"127.0.0.1":            true,
+		"169.254.169.254":      true,
+		"172.31.255.255":       true,
+		"172.32.0.1":           false,
+		"8.8.8.8":              true,
+		"8.9.8.8":              false,
+		"1.1.1.1":              false,
+		"::1":                  true,
+		"fe80::1":              true,
+		"2001:4860:4860::8888": true,
+		"2606:4700::1111":      false,
+		"::ffff:10.0.0.1":      true,
+	}
+	for ip, want := range tests {
+		if got := r.Contains(net.ParseIP(ip)); got != want {
+			t.Errorf("Contains(%s) = %v, wanted %v", ip, got, want)
+		}
+	}
+
+	allowed, err := newExcludedRanges(true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed.Contains(net.ParseIP("10.1.2.3")) {
+		t.Errorf("--allow-private still excludes 10.1.2.3")
+	}
+	if err := allowed.AddString("not-an-ip"); err == nil {
+		t.Errorf("AddString accepted an invalid range")
+	}
+}
+
+// Domain targets, and the servers their SRV records name, must be checked
+// against the exclusions before they are dialled, not after.
+func TestScanExcludedDomain(t *testing.T) {
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := uint(ln.Addr().(*net.TCPAddr).Port)
+
+	dns, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dns.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := dns.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			dns.WriteToUDP(answerSRV(buf[:n], "play.mc.example", uint16(port), net.IPv4(127, 0, 0, 1).To4()), addr)
+		}
+	}()
+
+	for name, srv := range map[string]bool{"domain": false, "srv": true} {
+		t.Run(name, func(t *testing.T) {
+			scanner := newTestScanner(testHandshake(765))
+			scanner.config.SRV = srv
+			if scanner.excluded, err = newExcludedRanges(false, ""); err != nil {
+				t.Fatal(err)
+			}
+			if scanner.resolver, err = newResolver(dns.LocalAddr().String()); err != nil {
+				t.Fatal(err)
+			}
+			status, res, err := scanner.Scan(zgrab2.ScanTarget{Domain: "mc.example", Port: &port})
+			if status != zgrab2.SCAN_SKIPPED || !errors.Is(err, ErrExcluded) {
+				t.Fatalf("got %s (%v), wanted skipped", status, err)
+			}
+			if results := res.(*Results); results.Skipped != skippedExcluded {
+				t.Errorf("got skipped %q", results.Skipped)
+			}
+			ln.SetDeadline(time.Now().Add(100 * time.Millisecond))
+			if conn, err := ln.Accept(); err == nil {
+				conn.Close()
+				t.Errorf("the excluded address was connected to")
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package mc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// faviconPrefix is how the status response's favicon data URI starts.
+const faviconPrefix = "data:image/png;base64,"
+
+// pngMagic is the PNG file signature.
+var pngMagic = []byte("\x89PNG\r\n\x1a\n")
+
+// Favicon is the decoded server icon.
+type Favicon struct {
+	// Data is the PNG itself (base64-encoded in JSON).
+	Data   []byte `json:"data"`
+	Length int    `json:"length"`
+	SHA256 string `json:"sha256"`
+}
+
+// decodeFavicon extracts the favicon from a status document. It returns nil
+// if there is none or it is not a base64 PNG data URI.
+func decodeFavicon(doc []byte) *Favicon {
+	var status struct {
+		Favicon string `json:"favicon"`
+	}
+	if err := json.Unmarshal(doc, &status); err != nil {
+		return nil
+	}
+	if !strings.HasPrefix(status.Favicon, faviconPrefix) {
+		return nil
+	}
+	// Vanilla servers wrap the base64 at 76 columns with "\n".
+	encoded := strings.NewReplacer("\n", "", "\r", "").Replace(status.Favicon[len(faviconPrefix):])
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || !bytes.HasPrefix(data, pngMagic) {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	return &Favicon{Data: data, Length: len(data), SHA256: hex.EncodeToString(sum[:])}
+}
+
+// faviconLength returns the length of the favicon value in a status
+// document as sent, quotes and escapes included, or 0 if there is none.
+func faviconLength(doc []byte) int {
+	var status struct {
+		Favicon json.RawMessage `json:"favicon"`
+	}
+	if err := json.Unmarshal(doc, &status); err != nil {
+		return 0
+	}
+	return len(status.Favicon)
+}
@@ -0,0 +1,33 @@
+package mc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+func TestDecodeFavicon(t *testing.T) {
+	png := append(append([]byte{}, pngMagic...), "\x00\x00\x00\x0dIHDR"...)
+	encoded := base64.StdEncoding.EncodeToString(png)
+	sum := sha256.Sum256(png)
+
+	favicon := decodeFavicon([]byte(`{"favicon":"` + faviconPrefix + encoded[:8] + `\n` + encoded[8:] + `"}`))
+	if favicon == nil {
+		t.Fatal("valid favicon was not decoded")
+	}
+	if favicon.Length != len(png) || favicon.SHA256 != hex.EncodeToString(sum[:]) || string(favicon.Data) != string(png) {
+		t.Errorf("unexpected favicon: %+v", favicon)
+	}
+
+	for name, doc := range map[string]string{
+		"absent":   `{"description":"hi"}`,
+		"not png":  `{"favicon":"` + faviconPrefix + base64.StdEncoding.EncodeToString([]byte("GIF89a")) + `"}`,
+		"bad b64":  `{"favicon":"` + faviconPrefix + `!!!"}`,
+		"jpeg uri": `{"favicon":"data:image/jpeg;base64,` + encoded + `"}`,
+	} {
+		if favicon := decodeFavicon([]byte(doc)); favicon != nil {
+			t.Errorf("%s: got %+v, wanted nil", name, favicon)
+		}
+	}
+}
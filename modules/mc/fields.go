@@ -0,0 +1,64 @@
+package mc
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// resultFields returns the JSON names of the Results fields.
+func resultFields() map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(Results{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// parseOutputFields parses the --output-fields whitelist. Every name must be
+// a Results field, so that typos are caught before scanning starts.
+func parseOutputFields(list string) (map[string]bool, error) {
+	if list == "" {
+		return nil, nil
+	}
+	known := resultFields()
+	fields := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		fields[name] = true
+	}
+	return fields, nil
+}
+
+// filterResults drops every field of results not in fields. It works on the
+// serialized form, so omitted fields stay omitted.
+func filterResults(results *Results, fields map[string]bool) interface{} {
+	if results == nil || fields == nil {
+		return results
+	}
+	encoded, err := json.Marshal(results)
+	if err != nil {
+		return results
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &all); err != nil {
+		return results
+	}
+	for name := range all {
+		if !fields[name] {
+			delete(all, name)
+		}
+	}
+	return all
+}
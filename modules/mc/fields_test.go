@@ -0,0 +1,24 @@
+package mc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOutputFields(t *testing.T) {
+	if _, err := parseOutputFields("banner1,bannr2"); err == nil {
+		t.Errorf("expected an error for an unknown field")
+	}
+	fields, err := parseOutputFields("banner1, legacy_only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := &Results{Banner1: "00", Banner2: "01", TimeoutPhase: phaseRead}
+	encoded, err := json.Marshal(filterResults(results, fields))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"banner1":"00"}`; string(encoded) != want {
+		t.Errorf("got %s, wanted %s", encoded, want)
+	}
+}
@@ -0,0 +1,94 @@
+package mc
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+// loadHostList reads the --enumerate-forced-hosts file: one hostname per
+// line, with blank lines and lines starting with '#' ignored.
+func loadHostList(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var hosts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			hosts = append(hosts, line)
+		}
+	}
+	return hosts, scanner.Err()
+}
+
+// backendFingerprint identifies the server behind a status response by what
+// a server keeps from one request to the next: its version, player limit,
+// MOTD and favicon. The online count and sample are left out, since they
+// change between fetches. It is the hex SHA-256 of those fields.
+func backendFingerprint(status *StatusResponse, favicon *Favicon) string {
+	var faviconHash string
+	if favicon != nil {
+		faviconHash = favicon.SHA256
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d\x00%s\x00%s",
+		status.Version.Name, status.Version.Protocol, status.Players.Max, flattenChat(status.Description), faviconHash)))
+	return hex.EncodeToString(sum[:])
+}
+
+// probeForcedHosts maps a proxy's forced hosts. BungeeCord and Velocity
+// route a handshake addressed to a forced host to its backend, and any
+// other to the default server, so the status is fetched once addressed to
+// a made-up name, for the default, and then once for each
+// --enumerate-forced-hosts candidate, each on a connection of its own and
+// --forced-host-delay apart, since they all go to the one host. Candidates
+// answered by a backend other than the default are recorded in
+// ForcedRouted. Nothing is recorded if the default cannot be fetched, and
+// candidates whose fetch fails are left out.
+func (s *Scanner) probeForcedHosts(target *zgrab2.ScanTarget, results *Results) {
+	fetch := func(host string) (string, bool) {
+		if s.config.FML {
+			host += fmlMarker(s.config.ProtocolVer)
+		}
+		packet, err := s.fetchStatus(target, results, host, s.config.ProtocolVer)
+		if err != nil {
+			return "", false
+		}
+		status := parseStatus(packet)
+		if status == nil {
+			return "", false
+		}
+		return backendFingerprint(status, decodeFavicon(statusJSON(packet))), true
+	}
+	fallback, ok := fetch(catchAllHost())
+	if !ok {
+		return
+	}
+	results.ForcedDefault = fallback
+	results.ForcedHosts = make(map[string]string)
+	backends := map[string]bool{fallback: true}
+	for _, host := range s.forcedHosts {
+		time.Sleep(s.config.ForcedDelay)
+		fingerprint, ok := fetch(host)
+		if !ok {
+			continue
+		}
+		results.ForcedHosts[host] = fingerprint
+		if fingerprint != fallback {
+			results.ForcedRouted = append(results.ForcedRouted, host)
+		}
+		backends[fingerprint] = true
+	}
+	results.ForcedBackends = len(backends)
+}
@@ -0,0 +1,66 @@
+package mc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestForcedHosts(t *testing.T) {
+	var fetches int32
+	target := serveHandshakes(t, func(host string, _ int) []byte {
+		// The online count changes from one fetch to the next.
+		online := strconv.Itoa(int(atomic.AddInt32(&fetches, 1)))
+		switch host {
+		case "lobby.example":
+			return []byte(`{"version":{"name":"Paper 1.20.4","protocol":765},"players":{"max":100,"online":` + online + `},"description":"Lobby"}`)
+		case "survival.example":
+			return []byte(`{"version":{"name":"Paper 1.20.4","protocol":765},"players":{"max":50,"online":` + online + `},"description":"Survival"}`)
+		case "down.example":
+			return nil
+		}
+		return []byte(`{"version":{"name":"Velocity 3.3.0","protocol":765},"players":{"max":500,"online":` + online + `},"description":"Network"}`)
+	})
+	list := filepath.Join(t.TempDir(), "hosts")
+	candidates := "# candidates\nlobby.example\n\nsurvival.example\nwww.example\ndown.example\n"
+	if err := os.WriteFile(list, []byte(candidates), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := newTestScanner(testHandshake(765))
+	scanner.config.ForcedHosts = list
+	var err error
+	if scanner.forcedHosts, err = loadHostList(list); err != nil {
+		t.Fatal(err)
+	}
+	status, res, err := scanner.Scan(target)
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("received unexpected status: %s (%v)", status, err)
+	}
+	results := res.(*Results)
+	if len(results.ForcedHosts) != 3 || results.ForcedHosts["down.example"] != "" {
+		t.Errorf("got forced_hosts %v, wanted the three candidates answered", results.ForcedHosts)
+	}
+	if results.ForcedHosts["www.example"] != results.ForcedDefault || results.ForcedDefault == "" {
+		t.Errorf("www.example: got %q, wanted the default %q", results.ForcedHosts["www.example"], results.ForcedDefault)
+	}
+	if want := []string{"lobby.example", "survival.example"}; !reflect.DeepEqual(results.ForcedRouted, want) {
+		t.Errorf("got forced_routed %q, wanted %q", results.ForcedRouted, want)
+	}
+	if results.ForcedBackends != 3 {
+		t.Errorf("got forced_backends %d, wanted 3", results.ForcedBackends)
+	}
+}
+
+func TestValidateForcedHosts(t *testing.T) {
+	f := &Flags{Probe1: "\\n", Probe2: "\\n", ReadTimeout: time.Second, MaxBannerSize: 32800, PingCount: 1, ForcedHosts: "hosts", Bedrock: true}
+	if err := f.Validate(nil); err == nil {
+		t.Error("accepted --enumerate-forced-hosts with --bedrock")
+	}
+}
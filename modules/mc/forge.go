@@ -0,0 +1,187 @@
+package mc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"unicode/utf16"
+)
+
+// ModInfo is a mod listed by a Forge server.
+type ModInfo struct {
+	ID string `json:"id"`
+	// Version is empty for mods that are marked as server-side only.
+	Version string `json:"version,omitempty"`
+}
+
+// ForgeChannel is a network channel listed by a Forge server.
+type ForgeChannel struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Required bool   `json:"required"`
+}
+
+// forgeInfo is what a status document lists of a Forge server's mods.
+type forgeInfo struct {
+	mods      []ModInfo
+	channels  []ForgeChannel
+	truncated bool
+}
+
+// decodeForge extracts the mod list from a status document: the modinfo
+// object of 1.7 to 1.12, or the forgeData object of 1.13 onwards, which from
+// 1.18 packs the mods and channels into a binary string in its d field. It
+// returns nil for documents without either, or with a d field that does not
+// decode.
+func decodeForge(doc []byte) *forgeInfo {
+	var status struct {
+		ModInfo *struct {
+			ModList []struct {
+				ModID   string `json:"modid"`
+				Version string `json:"version"`
+			} `json:"modList"`
+		} `json:"modinfo"`
+		ForgeData *struct {
+			Channels []struct {
+				Res      string `json:"res"`
+				Version  string `json:"version"`
+				Required bool   `json:"required"`
+			} `json:"channels"`
+			Mods []struct {
+				ModID     string `json:"modId"`
+				ModMarker string `json:"modmarker"`
+			} `json:"mods"`
+			Truncated bool   `json:"truncated"`
+			D         string `json:"d"`
+		} `json:"forgeData"`
+	}
+	if err := json.Unmarshal(doc, &status); err != nil {
+		return nil
+	}
+	info := new(forgeInfo)
+	switch {
+	case status.ForgeData != nil && status.ForgeData.D != "":
+		packed, err := unpackForgeData(status.ForgeData.D)
+		if err != nil {
+			return nil
+		}
+		if info, err = parseForgeData(packed); err != nil {
+			return nil
+		}
+	case status.ForgeData != nil:
+		for _, mod := range status.ForgeData.Mods {
+			info.mods = append(info.mods, ModInfo{ID: mod.ModID, Version: mod.ModMarker})
+		}
+		for _, channel := range status.ForgeData.Channels {
+			info.channels = append(info.channels, ForgeChannel{Name: channel.Res, Version: channel.Version, Required: channel.Required})
+		}
+		info.truncated = status.ForgeData.Truncated
+	case status.ModInfo != nil:
+		for _, mod := range status.ModInfo.ModList {
+			info.mods = append(info.mods, ModInfo{ID: mod.ModID, Version: mod.Version})
+		}
+	default:
+		return nil
+	}
+	return info
+}
+
+// unpackForgeData reverses the packing of the forgeData d string, which
+// carries 15 bits in each UTF-16 code unit, after two units giving the
+// length in bytes.
+func unpackForgeData(d string) ([]byte, error) {
+	units := utf16.Encode([]rune(d))
+	if len(units) < 2 {
+		return nil, errors.New("forgeData: d too short")
+	}
+	size := int(units[0]&0x7FFF) | int(units[1]&0x7FFF)<<15
+	// Each unit carries less than two bytes.
+	if size > 2*(len(units)-2) {
+		return nil, errors.New("forgeData: d shorter than its length")
+	}
+	out := make([]byte, 0, size+2)
+	var buffer uint32
+	bits := 0
+	for _, unit := range units[2:] {
+		for bits >= 8 {
+			out = append(out, byte(buffer))
+			buffer >>= 8
+			bits -= 8
+		}
+		buffer |= uint32(unit&0x7FFF) << bits
+		bits += 15
+	}
+	for len(out) < size {
+		out = append(out, byte(buffer))
+		buffer >>= 8
+	}
+	return out[:size], nil
+}
+
+// parseForgeData parses the unpacked d string: a truncated flag, the mods
+// with their channels, then the channels that belong to no mod.
+func parseForgeData(b []byte) (*forgeInfo, error) {
+	r := bytes.NewReader(b)
+	info := new(forgeInfo)
+	truncated, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	info.truncated = truncated != 0
+	var modCount uint16
+	if err := binary.Read(r, binary.BigEndian, &modCount); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(modCount); i++ {
+		flags, err := readVarInt(r)
+		if err != nil {
+			return nil, err
+		}
+		var mod ModInfo
+		if mod.ID, err = readString(r); err != nil {
+			return nil, err
+		}
+		// The low bit marks a server-side only mod, sent without a version.
+		if flags&1 == 0 {
+			if mod.Version, err = readString(r); err != nil {
+				return nil, err
+			}
+		}
+		info.mods = append(info.mods, mod)
+		for j := 0; j < int(uint32(flags)>>1); j++ {
+			channel, err := readForgeChannel(r)
+			if err != nil {
+				return nil, err
+			}
+			channel.Name = mod.ID + ":" + channel.Name
+			info.channels = append(info.channels, channel)
+		}
+	}
+	count, err := readVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < count; i++ {
+		channel, err := readForgeChannel(r)
+		if err != nil {
+			return nil, err
+		}
+		info.channels = append(info.channels, channel)
+	}
+	return info, nil
+}
+
+func readForgeChannel(r *bytes.Reader) (ForgeChannel, error) {
+	var channel ForgeChannel
+	var err error
+	if channel.Name, err = readString(r); err != nil {
+		return channel, err
+	}
+	if channel.Version, err = readString(r); err != nil {
+		return channel, err
+	}
+	required, err := r.ReadByte()
+	channel.Required = required != 0
+	return channel, err
+}
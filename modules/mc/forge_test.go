@@ -0,0 +1,89 @@
+package mc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/zmap/zgrab2/lib/varint"
+)
+
+// packForgeData packs b the way Forge does for the forgeData d string.
+func packForgeData(b []byte) string {
+	units := []uint16{uint16(len(b) & 0x7FFF), uint16(len(b) >> 15 & 0x7FFF)}
+	var buffer uint32
+	bits := 0
+	for _, c := range b {
+		if bits >= 15 {
+			units = append(units, uint16(buffer&0x7FFF))
+			buffer >>= 15
+			bits -= 15
+		}
+		buffer |= uint32(c) << bits
+		bits += 8
+	}
+	for ; bits > 0; bits -= 15 {
+		units = append(units, uint16(buffer&0x7FFF))
+		buffer >>= 15
+	}
+	return string(utf16.Decode(units))
+}
+
+func TestDecodeForge(t *testing.T) {
+	// forge 47.2.0 with one channel, a server-side only mod, and a channel
+	// belonging to no mod
+	packed := []byte{0x01}
+	packed = binary.BigEndian.AppendUint16(packed, 2)
+	packed = varint.Append(packed, 1<<1)
+	packed = appendString(packed, "forge")
+	packed = appendString(packed, "47.2.0")
+	packed = appendString(packed, "tier_sorting")
+	packed = appendString(packed, "1.0")
+	packed = append(packed, 0x01)
+	packed = varint.Append(packed, 1)
+	packed = appendString(packed, "spark")
+	packed = varint.Append(packed, 1)
+	packed = appendString(packed, "minecraft:register")
+	packed = appendString(packed, "FML3")
+	packed = append(packed, 0x00)
+	d, _ := json.Marshal(packForgeData(packed))
+
+	tests := map[string]struct {
+		doc  string
+		want *forgeInfo
+	}{
+		"modinfo": {
+			doc:  `{"modinfo":{"type":"FML","modList":[{"modid":"minecraft","version":"1.12.2"},{"modid":"forge","version":"14.23.5.2859"}]}}`,
+			want: &forgeInfo{mods: []ModInfo{{ID: "minecraft", Version: "1.12.2"}, {ID: "forge", Version: "14.23.5.2859"}}},
+		},
+		"forgeData": {
+			doc: `{"forgeData":{"channels":[{"res":"fml:handshake","version":"1.2.3.4","required":true}],"mods":[{"modId":"forge","modmarker":"36.2.39"}],"fmlNetworkVersion":2}}`,
+			want: &forgeInfo{
+				mods:     []ModInfo{{ID: "forge", Version: "36.2.39"}},
+				channels: []ForgeChannel{{Name: "fml:handshake", Version: "1.2.3.4", Required: true}},
+			},
+		},
+		"packed forgeData": {
+			doc: `{"forgeData":{"channels":[],"mods":[],"truncated":false,"fmlNetworkVersion":3,"d":` + string(d) + `}}`,
+			want: &forgeInfo{
+				mods: []ModInfo{{ID: "forge", Version: "47.2.0"}, {ID: "spark"}},
+				channels: []ForgeChannel{
+					{Name: "forge:tier_sorting", Version: "1.0", Required: true},
+					{Name: "minecraft:register", Version: "FML3"},
+				},
+				truncated: true,
+			},
+		},
+		"vanilla":   {doc: `{"version":{"name":"1.20.4","protocol":765}}`},
+		"corrupt d": {doc: `{"forgeData":{"d":"\u0010\u0000ab"}}`},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := decodeForge([]byte(test.doc)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("decodeForge() = %+v, wanted %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package mc
+
+import (
+	"bytes"
+	"encoding/hex"
+)
+
+// Framing breaks the status response packet down into its layers: the
+// packet ID, the declared length of the JSON string, and whatever follows
+// the string, which a well-formed response does not have.
+type Framing struct {
+	PacketID     int `json:"packet_id"`
+	StringLength int `json:"string_length"`
+
+	// JSONBytes is how much of the declared string was present; it falls
+	// short of StringLength if the packet is too short for its contents.
+	JSONBytes int `json:"json_bytes"`
+
+	// Trailing holds, in hex, any bytes after the declared string.
+	Trailing      string `json:"trailing,omitempty"`
+	TrailingBytes int    `json:"trailing_bytes,omitempty"`
+
+	// Error describes where decoding the framing failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// decodeFraming decodes the layers of a status packet body (everything
+// after the packet length).
+func decodeFraming(packet []byte) *Framing {
+	framing := new(Framing)
+	r := bytes.NewReader(packet)
+	var err error
+	if framing.PacketID, err = readVarInt(r); err != nil {
+		framing.Error = "packet ID: " + err.Error()
+		return framing
+	}
+	if framing.StringLength, err = readVarInt(r); err != nil {
+		framing.Error = "string length: " + err.Error()
+		return framing
+	}
+	rest := packet[len(packet)-r.Len():]
+	if framing.StringLength < 0 || framing.StringLength > len(rest) {
+		framing.JSONBytes = len(rest)
+		return framing
+	}
+	framing.JSONBytes = framing.StringLength
+	if trailing := rest[framing.StringLength:]; len(trailing) > 0 {
+		framing.Trailing = hex.EncodeToString(trailing)
+		framing.TrailingBytes = len(trailing)
+	}
+	return framing
+}
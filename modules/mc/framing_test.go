@@ -0,0 +1,43 @@
+package mc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zmap/zgrab2/lib/varint"
+)
+
+func TestDecodeFraming(t *testing.T) {
+	status := []byte(`{"version":{"name":"1.20.4","protocol":765}}`)
+	packet := func(declared int, trailing ...byte) []byte {
+		p := append([]byte{0x00}, varint.Append(nil, declared)...)
+		p = append(p, status...)
+		return append(p, trailing...)
+	}
+	tests := map[string]struct {
+		packet []byte
+		want   Framing
+	}{
+		"well-formed": {
+			packet: packet(len(status)),
+			want:   Framing{StringLength: len(status), JSONBytes: len(status)},
+		},
+		"padded": {
+			packet: packet(len(status), 0x00, 0x00),
+			want:   Framing{StringLength: len(status), JSONBytes: len(status), Trailing: "0000", TrailingBytes: 2},
+		},
+		"short": {
+			packet: packet(len(status) + 10),
+			want:   Framing{StringLength: len(status) + 10, JSONBytes: len(status)},
+		},
+		"truncated length": {
+			packet: []byte{0x00, 0x80},
+			want:   Framing{Error: "string length: EOF"},
+		},
+	}
+	for name, test := range tests {
+		if got := decodeFraming(test.packet); !reflect.DeepEqual(*got, test.want) {
+			t.Errorf("%s: got %+v, wanted %+v", name, *got, test.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package mc
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/zmap/zgrab2/lib/varint"
+)
+
+const (
+	// handshakeID and statusRequestID are the IDs of the handshake packet
+	// and of the status request that follows it.
+	handshakeID     = 0x00
+	statusRequestID = 0x00
+
+	// nextStateStatus is the handshake's next state for a status request.
+	nextStateStatus = 1
+)
+
+// Forge protocol versions that changed the handshake marker.
+const (
+	protocolFML2 = 393 // 1.13
+	protocolFML3 = 757 // 1.18
+)
+
+// fmlMarker returns the marker a Forge client of protoVer appends to the
+// handshake's server address. The conventional -1 gets the latest one.
+func fmlMarker(protoVer int) string {
+	switch {
+	case protoVer >= 0 && protoVer < protocolFML2:
+		return "\x00FML\x00"
+	case protoVer >= 0 && protoVer < protocolFML3:
+		return "\x00FML2\x00"
+	}
+	return "\x00FML3\x00"
+}
+
+// buildHandshake returns the handshake for a status request to host:port
+// with the given protocol version, followed by the status request itself.
+func buildHandshake(host string, port uint16, protoVer int) []byte {
+	payload := varint.Append(nil, protoVer)
+	payload = appendString(payload, host)
+	payload = binary.BigEndian.AppendUint16(payload, port)
+	payload = varint.Append(payload, nextStateStatus)
+	probe := buildPacket(handshakeID, payload)
+	return append(probe, buildPacket(statusRequestID, nil)...)
+}
+
+// buildPing returns a ping request carrying payload, which the server echoes
+// back in the pong.
+func buildPing(payload int64) []byte {
+	return buildPacket(pingID, binary.BigEndian.AppendUint64(nil, uint64(payload)))
+}
+
+// handshakeProtocol returns the protocol version of the handshake probe
+// starts with, whether it was built or given as --probe1, and whether there
+// is one.
+func handshakeProtocol(probe []byte) (int, bool) {
+	r := bytes.NewReader(probe)
+	if length, err := readVarInt(r); err != nil || length < 2 || length > r.Len() {
+		return 0, false
+	}
+	if id, err := readVarInt(r); err != nil || id != handshakeID {
+		return 0, false
+	}
+	protoVer, err := readVarInt(r)
+	if err != nil {
+		return 0, false
+	}
+	return protoVer, true
+}
+
+// reportedProtocol returns the protocol version the server reported in
+// whichever status it sent, if any.
+func reportedProtocol(results *Results) *int {
+	var protoVer int
+	switch {
+	case results.Status != nil:
+		protoVer = results.Status.Version.Protocol
+	case results.Bedrock != nil:
+		protoVer = results.Bedrock.ProtocolVersion
+	case results.Legacy != nil && results.Legacy.ProtocolVersion != 0:
+		// Only 1.4 and later send one.
+		protoVer = results.Legacy.ProtocolVersion
+	default:
+		return nil
+	}
+	return &protoVer
+}
@@ -0,0 +1,90 @@
+package mc
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestBuildHandshake(t *testing.T) {
+	if got, want := buildHandshake("localhost", 25565, 765), testHandshake(765); !bytes.Equal(got, want) {
+		t.Errorf("got %x, wanted %x", got, want)
+	}
+
+	// Protocol -1 (used when probing for the version) is a five-byte VarInt.
+	got := buildHandshake("mc.example.com", 25566, -1)
+	want := []byte{
+		0x18, 0x00, // length, handshake
+		0xff, 0xff, 0xff, 0xff, 0x0f, // protocol -1
+		0x0e, 'm', 'c', '.', 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm',
+		0x63, 0xde, // port 25566
+		0x01,       // next state: status
+		0x01, 0x00, // status request
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %x, wanted %x", got, want)
+	}
+}
+
+func TestBuildPing(t *testing.T) {
+	want := []byte{0x09, 0x01, 0, 0, 0, 0, 0, 0, 0x30, 0x39}
+	if got := buildPing(12345); !bytes.Equal(got, want) {
+		t.Errorf("buildPing(12345) = %x, wanted %x", got, want)
+	}
+}
+
+func TestHandshakeAddr(t *testing.T) {
+	port := uint(25565)
+	target := &zgrab2.ScanTarget{IP: net.ParseIP("192.0.2.1"), Domain: "mc.example.com", Port: &port}
+	tests := map[string]struct {
+		host     string
+		fml      bool
+		protoVer int
+		want     string
+	}{
+		"target":   {protoVer: -1, want: "mc.example.com"},
+		"override": {host: "lobby.example.com", protoVer: -1, want: "lobby.example.com"},
+		"fml 1.12": {fml: true, protoVer: 340, want: "mc.example.com\x00FML\x00"},
+		"fml 1.16": {fml: true, protoVer: 754, want: "mc.example.com\x00FML2\x00"},
+		"fml -1":   {host: "lobby", fml: true, protoVer: -1, want: "lobby\x00FML3\x00"},
+		"fml 1.20": {fml: true, protoVer: 765, want: "mc.example.com\x00FML3\x00"},
+	}
+	for name, test := range tests {
+		scanner := newTestScanner(nil)
+		scanner.config.HandshakeHost = test.host
+		scanner.config.FML = test.fml
+		scanner.config.ProtocolVer = test.protoVer
+		results := new(Results)
+		host, gotPort := scanner.handshakeAddr(target, results)
+		if host != test.want || results.HandshakeHost != test.want || gotPort != 25565 {
+			t.Errorf("%s: got %q (recorded %q) port %d, wanted %q", name, host, results.HandshakeHost, gotPort, test.want)
+		}
+	}
+}
+
+func TestRequestedProtocol(t *testing.T) {
+	if protoVer, ok := handshakeProtocol(buildHandshake("localhost", 25565, -1)); !ok || protoVer != -1 {
+		t.Errorf("handshakeProtocol() = %d, %v; wanted -1", protoVer, ok)
+	}
+	for _, probe := range [][]byte{[]byte("\n"), legacyPing, nil} {
+		if _, ok := handshakeProtocol(probe); ok {
+			t.Errorf("handshakeProtocol(%x) found a handshake", probe)
+		}
+	}
+
+	// The server answers with its own version, not the one sent.
+	target := serveHandshakes(t, func(string, int) []byte {
+		return []byte(`{"version":{"name":"1.20.4","protocol":765}}`)
+	})
+	scanner := newTestScanner(testHandshake(47))
+	status, res, err := scanner.Scan(target)
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("received unexpected status: %s (%v)", status, err)
+	}
+	results := res.(*Results)
+	if results.RequestedProtocol == nil || *results.RequestedProtocol != 47 || results.ProtocolVersion == nil || *results.ProtocolVersion != 765 {
+		t.Errorf("got requested %v, reported %v", results.RequestedProtocol, results.ProtocolVersion)
+	}
+}
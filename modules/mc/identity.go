@@ -0,0 +1,49 @@
+package mc
+
+import "strings"
+
+// ClientIdentity is how the scanner presents itself to servers: the
+// protocol version of its handshake, the brand it announces with
+// --client-brand, the name it logs in with, and the markers it adds, such as
+// the Forge handshake marker with --fml. It is recorded once in the scan
+// summary.
+type ClientIdentity struct {
+	ProtocolVersion *int     `json:"protocol_version,omitempty"`
+	Brand           string   `json:"brand,omitempty"`
+	LoginName       string   `json:"login_name,omitempty"`
+	HandshakeHost   string   `json:"handshake_host,omitempty"`
+	Markers         []string `json:"markers,omitempty"`
+}
+
+// ClientIdentity returns the scanner's ClientIdentity, or nil if it sends
+// no handshake, as with --bedrock, --query, --legacy and --liveness.
+func (s *Scanner) ClientIdentity() interface{} {
+	if s.config.Bedrock || s.config.Query || s.config.Legacy || s.config.Liveness {
+		return nil
+	}
+	identity := &ClientIdentity{HandshakeHost: s.config.HandshakeHost}
+	switch {
+	case s.config.LoginProbe:
+		protoVer := s.config.ProtocolVer
+		if protoVer < 0 {
+			protoVer = loginProtocolVersion
+		}
+		identity.ProtocolVersion = &protoVer
+		identity.Brand = s.config.ClientBrand
+		identity.LoginName = loginName
+	case s.config.BuildHandshake:
+		protoVer := s.config.ProtocolVer
+		identity.ProtocolVersion = &protoVer
+	default:
+		if protoVer, ok := handshakeProtocol(s.probe1); ok {
+			identity.ProtocolVersion = &protoVer
+		}
+	}
+	if s.config.FML {
+		identity.Markers = append(identity.Markers, strings.Trim(fmlMarker(s.config.ProtocolVer), "\x00"))
+	}
+	if s.config.ProxyProtocol {
+		identity.Markers = append(identity.Markers, "PROXY")
+	}
+	return identity
+}
@@ -0,0 +1,34 @@
+package mc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClientIdentity(t *testing.T) {
+	status, login := 765, loginProtocolVersion
+	tests := map[string]struct {
+		flags Flags
+		probe []byte
+		want  interface{}
+	}{
+		"raw probe": {
+			probe: testHandshake(765),
+			want:  &ClientIdentity{ProtocolVersion: &status},
+		},
+		"login": {
+			flags: Flags{LoginProbe: true, ProtocolVer: -1, ClientBrand: "fabric", FML: true, HandshakeHost: "play.example.com"},
+			want:  &ClientIdentity{ProtocolVersion: &login, Brand: "fabric", LoginName: loginName, HandshakeHost: "play.example.com", Markers: []string{"FML3"}},
+		},
+		"bedrock": {
+			flags: Flags{Bedrock: true},
+			want:  nil,
+		},
+	}
+	for name, test := range tests {
+		scanner := &Scanner{config: &test.flags, probe1: test.probe}
+		if got := scanner.ClientIdentity(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, wanted %+v", name, got, test.want)
+		}
+	}
+}
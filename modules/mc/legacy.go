@@ -0,0 +1,112 @@
+package mc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// legacyKickID is the packet ID of the pre-1.7 Disconnect/Kick packet.
+const legacyKickID = 0xFF
+
+// isLegacyKick peeks at the start of a response to tell a legacy kick packet
+// apart from a modern varint-framed packet whose length happens to start
+// with 0xFF.
+//
+// A legacy kick is 0xFF, a big-endian uint16 message length in UTF-16 code
+// units (at most 256 in vanilla servers), then the UTF-16BE message, whose
+// first code unit is almost always below 0x100 (e.g. '§' or ASCII). A modern
+// packet starting with 0xFF has a canonical varint length, so the second
+// byte is never 0x00, and for 0x01 (a 255-byte packet) the fourth byte is
+// the first byte of the non-empty status string length.
+func isLegacyKick(r *bufio.Reader) bool {
+	head, err := r.Peek(4)
+	if err != nil {
+		return false
+	}
+	return head[0] == legacyKickID && head[1] <= 0x01 && head[3] == 0x00
+}
+
+// readLegacyKick reads a legacy kick packet and returns the decoded message.
+func readLegacyKick(r io.Reader) (string, error) {
+	var header [3]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return "", err
+	}
+	buf := make([]byte, 2*int(binary.BigEndian.Uint16(header[1:])))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return decodeUTF16BE(buf), nil
+}
+
+// decodeUTF16BE decodes big-endian UTF-16. A trailing odd byte is ignored.
+func decodeUTF16BE(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// legacyPing is the 1.4-1.6 Server List Ping: the packet ID 0xFE and a
+// payload byte of 1, which asks for the §1-prefixed status in the kick.
+// Servers from 1.3 and earlier ignore the payload byte.
+var legacyPing = []byte{0xFE, 0x01}
+
+// LegacyStatus is the server status carried in the kick packet sent in
+// reply to a legacy ping.
+type LegacyStatus struct {
+	// ProtocolVersion and ServerVersion are only sent by 1.4 and later.
+	ProtocolVersion int    `json:"protocol_version,omitempty"`
+	ServerVersion   string `json:"server_version,omitempty"`
+	MOTD            string `json:"motd"`
+	OnlinePlayers   int    `json:"online_players"`
+	MaxPlayers      int    `json:"max_players"`
+}
+
+// parseLegacyStatus parses a legacy kick message as a status: either
+// "§1\x00protocol\x00version\x00motd\x00online\x00max" from 1.4 onwards, or
+// "motd§online§max" from earlier servers. Other messages give nil.
+func parseLegacyStatus(msg string) *LegacyStatus {
+	if strings.HasPrefix(msg, "§1\x00") {
+		fields := strings.Split(msg, "\x00")
+		if len(fields) != 6 {
+			return nil
+		}
+		status := &LegacyStatus{ServerVersion: fields[2], MOTD: fields[3]}
+		var err error
+		if status.ProtocolVersion, err = strconv.Atoi(fields[1]); err != nil {
+			return nil
+		}
+		if status.OnlinePlayers, err = strconv.Atoi(fields[4]); err != nil {
+			return nil
+		}
+		if status.MaxPlayers, err = strconv.Atoi(fields[5]); err != nil {
+			return nil
+		}
+		return status
+	}
+	// The MOTD itself may contain § formatting codes, so split from the
+	// right.
+	maxAt := strings.LastIndex(msg, "§")
+	if maxAt < 0 {
+		return nil
+	}
+	onlineAt := strings.LastIndex(msg[:maxAt], "§")
+	if onlineAt < 0 {
+		return nil
+	}
+	status := &LegacyStatus{MOTD: msg[:onlineAt]}
+	var err error
+	if status.OnlinePlayers, err = strconv.Atoi(msg[onlineAt+len("§") : maxAt]); err != nil {
+		return nil
+	}
+	if status.MaxPlayers, err = strconv.Atoi(msg[maxAt+len("§"):]); err != nil {
+		return nil
+	}
+	return status
+}
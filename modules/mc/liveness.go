@@ -0,0 +1,25 @@
+package mc
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/zmap/zgrab2"
+)
+
+// Port states recorded in Results.Liveness by --liveness.
+const (
+	livenessOpen     = "open"
+	livenessClosed   = "closed"
+	livenessFiltered = "filtered"
+)
+
+// livenessFailure classifies a failed connect for --liveness: an active
+// refusal means the port is closed, while a timeout or an unreachable
+// host or network means something is dropping the traffic.
+func livenessFailure(err error) (zgrab2.ScanStatus, interface{}, error) {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return zgrab2.SCAN_CONNECTION_REFUSED, &Results{Liveness: livenessClosed}, err
+	}
+	return zgrab2.TryGetScanStatus(err), &Results{Liveness: livenessFiltered}, err
+}
@@ -0,0 +1,202 @@
+package mc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/varint"
+)
+
+const (
+	// nextStateLogin is the handshake's next state for a login.
+	nextStateLogin = 2
+
+	// loginStartID is the ID of the Login Start packet.
+	loginStartID = 0x00
+
+	// loginName is the player name --login-probe logs in with.
+	loginName = "zgrab2"
+
+	// loginProtocolVersion (1.20.4) is sent by --login-probe in place of a
+	// --protocol-version of -1, with which servers refuse to log in.
+	loginProtocolVersion = 765
+)
+
+// Clientbound login packet IDs.
+const (
+	loginDisconnectID    = 0x00
+	loginEncryptionID    = 0x01
+	loginSuccessID       = 0x02
+	loginCompressionID   = 0x03
+	loginPluginRequestID = 0x04
+)
+
+// Protocol versions that changed the login packets.
+const (
+	protocolSigData      = 759 // 1.19 added signature data to Login Start
+	protocolOptionalUUID = 760 // 1.19.1 added an optional UUID
+	protocolNoSigData    = 761 // 1.19.3 dropped the signature data
+	protocolRequiredUUID = 764 // 1.20.2 made the UUID required
+	protocolShouldAuth   = 766 // 1.20.5 added should_authenticate to Encryption Request
+)
+
+// Results.LoginResponse values.
+const (
+	loginResponseDisconnect  = "disconnect"
+	loginResponseEncryption  = "encryption_request"
+	loginResponseSuccess     = "login_success"
+	loginResponseCompression = "set_compression"
+	loginResponsePlugin      = "plugin_request"
+)
+
+// buildLogin returns the handshake for a login to host:port with the given
+// protocol version, followed by a Login Start in that version's layout.
+func buildLogin(host string, port uint16, protoVer int) []byte {
+	payload := varint.Append(nil, protoVer)
+	payload = appendString(payload, host)
+	payload = binary.BigEndian.AppendUint16(payload, port)
+	payload = varint.Append(payload, nextStateLogin)
+	probe := buildPacket(handshakeID, payload)
+
+	start := appendString(nil, loginName)
+	switch {
+	case protoVer >= protocolRequiredUUID:
+		// An offline-mode style all-zero UUID.
+		start = append(start, make([]byte, 16)...)
+	case protoVer >= protocolNoSigData:
+		start = append(start, 0) // no UUID
+	case protoVer >= protocolOptionalUUID:
+		start = append(start, 0, 0) // no signature data, no UUID
+	case protoVer >= protocolSigData:
+		start = append(start, 0) // no signature data
+	}
+	return append(probe, buildPacket(loginStartID, start)...)
+}
+
+// loginResult is what the first login packet tells of the server.
+type loginResult struct {
+	response     string
+	onlineMode   bool
+	publicKeyLen int
+	disconnect   string
+}
+
+// parseLoginResponse parses the first packet a server sends in reply to
+// Login Start.
+func parseLoginResponse(packet []byte, protoVer int) (*loginResult, error) {
+	if len(packet) == 0 {
+		return nil, errors.New("empty login response")
+	}
+	r := bytes.NewReader(packet[1:])
+	switch packet[0] {
+	case loginDisconnectID:
+		reason, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		return &loginResult{response: loginResponseDisconnect, disconnect: flattenChat(json.RawMessage(reason))}, nil
+	case loginEncryptionID:
+		// Server ID, public key, verify token and, from 1.20.5,
+		// should_authenticate.
+		if _, err := readString(r); err != nil {
+			return nil, err
+		}
+		key, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := readString(r); err != nil {
+			return nil, err
+		}
+		result := &loginResult{response: loginResponseEncryption, onlineMode: true, publicKeyLen: len(key)}
+		if protoVer >= protocolShouldAuth {
+			if auth, err := r.ReadByte(); err == nil {
+				result.onlineMode = auth != 0
+			}
+		}
+		return result, nil
+	case loginSuccessID:
+		return &loginResult{response: loginResponseSuccess}, nil
+	case loginCompressionID:
+		return &loginResult{response: loginResponseCompression}, nil
+	case loginPluginRequestID:
+		return &loginResult{response: loginResponsePlugin}, nil
+	}
+	return nil, errors.New("unknown login response packet")
+}
+
+// scanLogin sends a login handshake and Login Start on conn and records
+// whether the server asked for encryption, which only online-mode servers
+// do. It never answers an Encryption Request, so it never authenticates.
+func (s *Scanner) scanLogin(conn net.Conn, target *zgrab2.ScanTarget, results *Results) (zgrab2.ScanStatus, interface{}, error) {
+	host, port := s.handshakeAddr(target, results)
+	protoVer := s.config.ProtocolVer
+	if protoVer < 0 {
+		protoVer = loginProtocolVersion
+	}
+	results.RequestedProtocol = &protoVer
+	if _, err := conn.Write(buildLogin(host, port, protoVer)); err != nil {
+		if zgrab2.IsTimeoutError(err) {
+			results.TimeoutPhase = phaseWrite
+		}
+		return zgrab2.TryGetScanStatus(err), results, err
+	}
+
+	phase := &deadlineReader{conn: conn}
+	reader := bufio.NewReader(phase)
+	phase.startPhase(s.config.ReadTimeout)
+	fail := func(err error) (zgrab2.ScanStatus, interface{}, error) {
+		if zgrab2.IsTimeoutError(err) {
+			results.TimeoutPhase = phaseRead
+		}
+		return zgrab2.TryGetScanStatus(err), results, err
+	}
+	// Whatever has arrived first, kept to guess at the service if this
+	// does not turn out to be a login response.
+	if _, err := reader.Peek(1); err != nil {
+		return fail(err)
+	}
+	head, _ := reader.Peek(reader.Buffered())
+	results.head = append([]byte(nil), head...)
+	length, err := readVarInt(reader)
+	if err != nil {
+		return fail(err)
+	}
+	if length > s.config.MaxBannerSize {
+		return zgrab2.SCAN_PROTOCOL_ERROR, results, errors.New("banner too long")
+	}
+	if length < 1 {
+		return zgrab2.SCAN_PROTOCOL_ERROR, results, errors.New("zero/negative banner length")
+	}
+	data, count, err := readPacket(reader, length)
+	if s.config.RawBanner {
+		results.Banner1 = s.encodeBanner(data)
+	}
+	if s.config.Decode {
+		results.Banner1Text = decodeText(data)
+	}
+	results.Banner1Bytes = count
+	results.responses = append(results.responses, data)
+	if err != nil {
+		return fail(err)
+	}
+	login, err := parseLoginResponse(data, protoVer)
+	if err != nil {
+		return zgrab2.SCAN_PROTOCOL_ERROR, results, err
+	}
+	results.head = nil
+	results.LoginResponse = login.response
+	results.OnlineMode = login.onlineMode
+	results.PublicKeyLength = login.publicKeyLen
+	results.LoginDisconnect = login.disconnect
+	s.matchTrap(data, results)
+	if s.config.ClientBrand != "" {
+		s.sendBrand(conn, phase, reader, data, protoVer, results)
+	}
+	return s.succeed(target, results)
+}
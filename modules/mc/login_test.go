@@ -0,0 +1,116 @@
+package mc
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/varint"
+)
+
+// serveLogin answers a login handshake and Login Start with reply, after
+// checking that the handshake asks for the login state.
+func serveLogin(t *testing.T, reply []byte) zgrab2.ScanTarget {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		handshake, err := readTestPacket(conn)
+		if err != nil || handshake[len(handshake)-1] != nextStateLogin {
+			return
+		}
+		start, err := readTestPacket(conn)
+		if err != nil || !bytes.HasPrefix(start, appendString([]byte{loginStartID}, loginName)) {
+			return
+		}
+		conn.Write(reply)
+	}()
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	return zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+}
+
+func TestScanLogin(t *testing.T) {
+	key := make([]byte, 162)
+	encryption := appendString(nil, "")
+	encryption = appendString(encryption, string(key))
+	encryption = appendString(encryption, "abcd")
+	offline := append(append([]byte(nil), encryption...), 0) // should_authenticate false
+
+	tests := map[string]struct {
+		protoVer int
+		reply    []byte
+		want     Results
+	}{
+		"online": {
+			protoVer: 765,
+			reply:    framePacket(loginEncryptionID, encryption),
+			want:     Results{LoginResponse: loginResponseEncryption, OnlineMode: true, PublicKeyLength: 162},
+		},
+		"encrypted offline": {
+			protoVer: 766,
+			reply:    framePacket(loginEncryptionID, offline),
+			want:     Results{LoginResponse: loginResponseEncryption, PublicKeyLength: 162},
+		},
+		"offline": {
+			protoVer: -1,
+			reply:    framePacket(loginCompressionID, varint.Append(nil, 256)),
+			want:     Results{LoginResponse: loginResponseCompression},
+		},
+		"disconnect": {
+			protoVer: 47,
+			reply:    framePacket(loginDisconnectID, appendString(nil, `{"text":"Outdated client!"}`)),
+			want:     Results{LoginResponse: loginResponseDisconnect, LoginDisconnect: "Outdated client!"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			scanner := newTestScanner(nil)
+			scanner.config.LoginProbe = true
+			scanner.config.ProtocolVer = test.protoVer
+			status, res, err := scanner.Scan(serveLogin(t, test.reply))
+			if err != nil || status != zgrab2.SCAN_SUCCESS {
+				t.Fatalf("got %s, %v", status, err)
+			}
+			results := res.(*Results)
+			if results.LoginResponse != test.want.LoginResponse || results.OnlineMode != test.want.OnlineMode ||
+				results.PublicKeyLength != test.want.PublicKeyLength || results.LoginDisconnect != test.want.LoginDisconnect {
+				t.Errorf("got %+v", results)
+			}
+			if results.ServiceGuess != "" {
+				t.Errorf("got service guess %q for a login response", results.ServiceGuess)
+			}
+		})
+	}
+}
+
+func TestBuildLoginStart(t *testing.T) {
+	name := appendString([]byte{loginStartID}, loginName)
+	tests := map[int][]byte{
+		47:  name,
+		759: append(append([]byte(nil), name...), 0),
+		760: append(append([]byte(nil), name...), 0, 0),
+		763: append(append([]byte(nil), name...), 0),
+		765: append(append([]byte(nil), name...), make([]byte, 16)...),
+	}
+	for protoVer, want := range tests {
+		r := bytes.NewReader(buildLogin("localhost", 25565, protoVer))
+		length, _ := readVarInt(r)
+		r.Seek(int64(length), io.SeekCurrent)
+		length, _ = readVarInt(r)
+		start := make([]byte, length)
+		if _, err := io.ReadFull(r, start); err != nil || !bytes.Equal(start, want) {
+			t.Errorf("protocol %d: got Login Start %x, wanted %x", protoVer, start, want)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package mc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A matcher is a compiled --match expression. Expressions compare result
+// fields, named by their JSON paths (e.g. banner1_bytes.read), with numbers,
+// quoted strings or true/false, using == != < <= > >= and contains, and
+// combine the comparisons with &&, || and !. A field on its own is true if it
+// is present and not false, zero or empty. Fields that are absent from a
+// result compare unequal to everything.
+type matcher func(fields map[string]interface{}) bool
+
+// compileMatcher parses expr, checking that every field it names is a
+// Results field.
+func compileMatcher(expr string) (matcher, error) {
+	tokens, err := lexMatch(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &matchParser{tokens: tokens, known: resultFields()}
+	m, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return m, nil
+}
+
+// match evaluates m against results.
+func (m matcher) match(results *Results) bool {
+	encoded, err := json.Marshal(results)
+	if err != nil {
+		return false
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return false
+	}
+	return m(fields)
+}
+
+type matchTokenKind int
+
+const (
+	tokenOp matchTokenKind = iota
+	tokenField
+	tokenNumber
+	tokenString
+)
+
+type matchToken struct {
+	kind matchTokenKind
+	text string
+}
+
+var matchOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"}
+
+func lexMatch(expr string) ([]matchToken, error) {
+	var tokens []matchToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+			continue
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, matchToken{tokenString, expr[i+1 : i+1+end]})
+			i += end + 2
+			continue
+		case c == '-' || c >= '0' && c <= '9':
+			end := i + 1
+			for end < len(expr) && (expr[end] == '.' || expr[end] >= '0' && expr[end] <= '9') {
+				end++
+			}
+			tokens = append(tokens, matchToken{tokenNumber, expr[i:end]})
+			i = end
+			continue
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := i + 1
+			for end < len(expr) && (expr[end] == '_' || expr[end] == '.' || unicode.IsLetter(rune(expr[end])) || unicode.IsDigit(rune(expr[end]))) {
+				end++
+			}
+			tokens = append(tokens, matchToken{tokenField, expr[i:end]})
+			i = end
+			continue
+		}
+		matched := false
+		for _, op := range matchOperators {
+			if strings.HasPrefix(expr[i:], op) {
+				tokens = append(tokens, matchToken{tokenOp, op})
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+type matchParser struct {
+	tokens []matchToken
+	pos    int
+	known  map[string]bool
+}
+
+func (p *matchParser) peek(kind matchTokenKind, text string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == kind && p.tokens[p.pos].text == text
+}
+
+func (p *matchParser) parseOr() (matcher, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek(tokenOp, "||") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(f map[string]interface{}) bool { return l(f) || right(f) }
+	}
+	return left, nil
+}
+
+func (p *matchParser) parseAnd() (matcher, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek(tokenOp, "&&") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(f map[string]interface{}) bool { return l(f) && right(f) }
+	}
+	return left, nil
+}
+
+func (p *matchParser) parseUnary() (matcher, error) {
+	if p.peek(tokenOp, "!") {
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(f map[string]interface{}) bool { return !inner(f) }, nil
+	}
+	if p.peek(tokenOp, "(") {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(tokenOp, ")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+// matchValue produces the value of an operand for a result.
+type matchValue func(fields map[string]interface{}) (interface{}, bool)
+
+func (p *matchParser) parseOperand() (matchValue, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	switch tok.kind {
+	case tokenNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", tok.text)
+		}
+		return func(map[string]interface{}) (interface{}, bool) { return n, true }, nil
+	case tokenString:
+		return func(map[string]interface{}) (interface{}, bool) { return tok.text, true }, nil
+	case tokenField:
+		switch tok.text {
+		case "true", "false":
+			b := tok.text == "true"
+			return func(map[string]interface{}) (interface{}, bool) { return b, true }, nil
+		}
+		path := strings.Split(tok.text, ".")
+		if !p.known[path[0]] {
+			return nil, fmt.Errorf("unknown field %q", path[0])
+		}
+		return func(f map[string]interface{}) (interface{}, bool) { return lookupField(f, path) }, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", tok.text)
+}
+
+func (p *matchParser) parseComparison() (matcher, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	var op string
+	if p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+		switch {
+		case tok.kind == tokenOp && strings.ContainsAny(tok.text, "=<>"):
+			op = tok.text
+		case tok.kind == tokenField && tok.text == "contains":
+			op = tok.text
+		}
+	}
+	if op == "" {
+		return func(f map[string]interface{}) bool {
+			v, ok := left(f)
+			return ok && truthy(v)
+		}, nil
+	}
+	p.pos++
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(f map[string]interface{}) bool {
+		l, lok := left(f)
+		r, rok := right(f)
+		return lok && rok && compare(op, l, r)
+	}, nil
+}
+
+func lookupField(fields map[string]interface{}, path []string) (interface{}, bool) {
+	var v interface{} = fields
+	for _, name := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[name]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case nil:
+		return false
+	}
+	return true
+}
+
+func compare(op string, l, r interface{}) bool {
+	if op == "contains" {
+		switch l := l.(type) {
+		case string:
+			s, ok := r.(string)
+			return ok && strings.Contains(l, s)
+		case []interface{}:
+			for _, item := range l {
+				if compare("==", item, r) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	switch l := l.(type) {
+	case float64:
+		if r, ok := r.(float64); ok {
+			return compareOrdered(op, l < r, l == r)
+		}
+	case string:
+		if r, ok := r.(string); ok {
+			return compareOrdered(op, l < r, l == r)
+		}
+	case bool:
+		if r, ok := r.(bool); ok {
+			switch op {
+			case "==":
+				return l == r
+			case "!=":
+				return l != r
+			}
+		}
+	}
+	return false
+}
+
+func compareOrdered(op string, less, equal bool) bool {
+	switch op {
+	case "==":
+		return equal
+	case "!=":
+		return !equal
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	case ">=":
+		return !less
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package mc
+
+import "testing"
+
+func TestMatcher(t *testing.T) {
+	results := &Results{
+		Banner1:           "00aa",
+		Banner1Bytes:      &ReadCount{Declared: 2000, Read: 1500},
+		LegacyKickMessage: "Server is full",
+		SocketWarnings:    []string{"SO_ERROR: connection reset"},
+	}
+	tests := map[string]bool{
+		`banner1_bytes.read > 1000`:                               true,
+		`banner1_bytes.read >= 1500 && banner1_bytes.read < 1501`: true,
+		`banner1_bytes.read == banner1_bytes.declared`:            false,
+		`banner1_bytes.read != banner1_bytes.declared`:            true,
+		`legacy_kick_message contains "full"`:                     true,
+		`socket_warnings contains "SO_ERROR: connection reset"`:   true,
+		`!legacy_only`:         true,
+		`legacy_only == false`: false, // omitted, so absent
+		`(legacy_only || truncated) || banner1 == '00aa'`: true,
+		`banner2_bytes.read < 1`:                          false,
+		`-1 < banner1_bytes.read`:                         true,
+	}
+	for expr, want := range tests {
+		m, err := compileMatcher(expr)
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			continue
+		}
+		if got := m.match(results); got != want {
+			t.Errorf("%s: got %v, wanted %v", expr, got, want)
+		}
+	}
+
+	for _, expr := range []string{
+		`players.online > 100`,
+		`banner1 ==`,
+		`(truncated`,
+		`banner1 == "open`,
+		`truncated truncated`,
+		`banner1 = "x"`,
+	} {
+		if _, err := compileMatcher(expr); err == nil {
+			t.Errorf("%s: expected a compile error", expr)
+		}
+	}
+}
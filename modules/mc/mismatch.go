@@ -0,0 +1,39 @@
+package mc
+
+import (
+	"regexp"
+
+	"github.com/zmap/zgrab2"
+)
+
+// mismatchProtocol is the protocol version --mismatch-probe sends. No
+// release uses it, so every server sees a client it does not support.
+const mismatchProtocol = 99999
+
+// versionPattern finds a release version, such as 1.20.4, in a version
+// name.
+var versionPattern = regexp.MustCompile(`\b1\.\d+(?:\.\d+)?\b`)
+
+// probeMismatch fetches the status again, on a connection of its own, with
+// a handshake for a protocol version the server cannot support. Servers
+// that check the version may answer with a warning such as "Outdated
+// server! I'm on 1.20.4" in place of the version name, which gives away
+// the version even when the name is otherwise spoofed. Nothing is recorded
+// if the fetch fails.
+func (s *Scanner) probeMismatch(target *zgrab2.ScanTarget, results *Results) {
+	host, _ := s.handshakeAddr(target, &Results{SRVHost: results.SRVHost, SRVPort: results.SRVPort})
+	packet, err := s.fetchStatus(target, results, host, mismatchProtocol)
+	if err != nil {
+		return
+	}
+	status := parseStatus(packet)
+	if status == nil {
+		return
+	}
+	name := status.Version.Name
+	results.MismatchVersionName = name
+	results.MismatchChanged = name != results.Status.Version.Name
+	if results.MismatchChanged {
+		results.MismatchVersion = versionPattern.FindString(stripFormatting(name))
+	}
+}
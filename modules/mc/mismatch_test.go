@@ -0,0 +1,26 @@
+package mc
+
+import (
+	"testing"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestMismatchProbe(t *testing.T) {
+	target := serveHandshakes(t, func(_ string, protocol int) []byte {
+		if protocol != 765 {
+			return []byte(`{"version":{"name":"§cOutdated client! I'm on 1.20.4","protocol":765}}`)
+		}
+		return []byte(`{"version":{"name":"Spoofed 9.9","protocol":765}}`)
+	})
+	scanner := newTestScanner(testHandshake(765))
+	scanner.config.MismatchProbe = true
+	status, res, err := scanner.Scan(target)
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("received unexpected status: %s (%v)", status, err)
+	}
+	results := res.(*Results)
+	if !results.MismatchChanged || results.MismatchVersion != "1.20.4" || results.MismatchVersionName != "§cOutdated client! I'm on 1.20.4" {
+		t.Errorf("got mismatch %v, version %q, name %q", results.MismatchChanged, results.MismatchVersion, results.MismatchVersionName)
+	}
+}
@@ -0,0 +1,23 @@
+package mc
+
+import "encoding/json"
+
+// Modpack is the pack a server advertises in the modpackData object of its
+// status document, as added by Technic and ATLauncher packs.
+type Modpack struct {
+	Name     string `json:"name,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Launcher string `json:"launcher,omitempty"`
+}
+
+// decodeModpack extracts the modpackData object from a status document,
+// returning nil if there is none or it is not an object.
+func decodeModpack(doc []byte) *Modpack {
+	var status struct {
+		ModpackData *Modpack `json:"modpackData"`
+	}
+	if err := json.Unmarshal(doc, &status); err != nil {
+		return nil
+	}
+	return status.ModpackData
+}
@@ -0,0 +1,25 @@
+package mc
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDecodeModpack(t *testing.T) {
+	doc, err := os.ReadFile("testdata/status-technic.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Modpack{Name: "Tekkit Legends", Version: "1.1.1", Launcher: "technic"}
+	if got := decodeModpack(doc); got == nil || *got != want {
+		t.Errorf("got %+v, wanted %+v", got, want)
+	}
+	for name, doc := range map[string]string{
+		"absent":     `{"version":{"name":"1.20.4","protocol":765}}`,
+		"not object": `{"modpackData":"Tekkit"}`,
+	} {
+		if got := decodeModpack([]byte(doc)); got != nil {
+			t.Errorf("%s: got %+v, wanted nil", name, got)
+		}
+	}
+}
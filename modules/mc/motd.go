@@ -0,0 +1,151 @@
+package mc
+
+import (
+	"encoding/json"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// chatComponent is the part of a chat component that carries text.
+type chatComponent struct {
+	Text  string            `json:"text"`
+	Extra []json.RawMessage `json:"extra"`
+}
+
+// flattenChat concatenates the text of a chat component: a plain string, a
+// component object with text and extra children, or an array of components.
+func flattenChat(raw json.RawMessage) string {
+	var b strings.Builder
+	appendChat(&b, raw, 0)
+	return b.String()
+}
+
+// maxChatDepth bounds recursion into nested extra arrays.
+const maxChatDepth = 32
+
+func appendChat(b *strings.Builder, raw json.RawMessage, depth int) {
+	if depth > maxChatDepth {
+		return
+	}
+	raw = json.RawMessage(strings.TrimSpace(string(raw)))
+	if len(raw) == 0 {
+		return
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			b.WriteString(s)
+		}
+	case '[':
+		var parts []json.RawMessage
+		if json.Unmarshal(raw, &parts) == nil {
+			for _, part := range parts {
+				appendChat(b, part, depth+1)
+			}
+		}
+	case '{':
+		var c chatComponent
+		if json.Unmarshal(raw, &c) == nil {
+			b.WriteString(c.Text)
+			for _, part := range c.Extra {
+				appendChat(b, part, depth+1)
+			}
+		}
+	}
+}
+
+// translateComponent is the part of a chat component that can name
+// translation keys, directly or in its arguments and children.
+type translateComponent struct {
+	Translate string            `json:"translate"`
+	With      []json.RawMessage `json:"with"`
+	Extra     []json.RawMessage `json:"extra"`
+}
+
+// translateKeys returns the distinct translation keys used anywhere in a
+// chat component, in the order they first appear.
+func translateKeys(raw json.RawMessage) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	var walk func(raw json.RawMessage, depth int)
+	walk = func(raw json.RawMessage, depth int) {
+		if depth > maxChatDepth {
+			return
+		}
+		raw = json.RawMessage(strings.TrimSpace(string(raw)))
+		if len(raw) == 0 {
+			return
+		}
+		switch raw[0] {
+		case '[':
+			var parts []json.RawMessage
+			if json.Unmarshal(raw, &parts) == nil {
+				for _, part := range parts {
+					walk(part, depth+1)
+				}
+			}
+		case '{':
+			var c translateComponent
+			if json.Unmarshal(raw, &c) != nil {
+				return
+			}
+			if c.Translate != "" && !seen[c.Translate] {
+				seen[c.Translate] = true
+				keys = append(keys, c.Translate)
+			}
+			for _, part := range append(c.With, c.Extra...) {
+				walk(part, depth+1)
+			}
+		}
+	}
+	walk(raw, 0)
+	return keys
+}
+
+// stripFormatting removes legacy section-sign formatting codes (§ followed
+// by a color or style character) from s.
+func stripFormatting(s string) string {
+	if !strings.ContainsRune(s, '§') {
+		return s
+	}
+	var b strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '§' {
+			i++
+			continue
+		}
+		b.WriteRune(runes[i])
+	}
+	return b.String()
+}
+
+// motdHostPattern matches an IPv4 address or a hostname with an alphabetic
+// top-level domain, either with an optional port. Version numbers such as
+// 1.20.4 match neither.
+var motdHostPattern = regexp.MustCompile(`(?i)\b(?:(?:\d{1,3}\.){3}\d{1,3}|(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63})(?::\d{1,5})?\b`)
+
+// motdHosts returns the distinct hosts advertised in a cleaned MOTD.
+func motdHosts(motd string) []string {
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, host := range motdHostPattern.FindAllString(motd, -1) {
+		host = strings.ToLower(host)
+		name := host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			name = h
+		}
+		// Hostnames end in letters, so this is an IPv4 match, which may
+		// have an octet out of range.
+		if last := name[len(name)-1]; '0' <= last && last <= '9' && net.ParseIP(name) == nil {
+			continue
+		}
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
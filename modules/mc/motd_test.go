@@ -0,0 +1,77 @@
+package mc
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFlattenChat(t *testing.T) {
+	tests := map[string]struct {
+		description string
+		motd, clean string
+	}{
+		"string": {
+			description: `"§aA §lMinecraft§r Server"`,
+			motd:        "§aA §lMinecraft§r Server",
+			clean:       "A Minecraft Server",
+		},
+		"component": {
+			description: `{"text":"Hello","extra":[{"text":", ","bold":true},{"text":"world","extra":["!"]}]}`,
+			motd:        "Hello, world!",
+			clean:       "Hello, world!",
+		},
+		"array": {
+			description: `[{"text":"§6Gold"}," and ",{"text":"plain"}]`,
+			motd:        "§6Gold and plain",
+			clean:       "Gold and plain",
+		},
+		"missing": {},
+	}
+	for name, test := range tests {
+		motd := flattenChat(json.RawMessage(test.description))
+		if motd != test.motd {
+			t.Errorf("%s: got motd %q, wanted %q", name, motd, test.motd)
+		}
+		if clean := stripFormatting(motd); clean != test.clean {
+			t.Errorf("%s: got clean motd %q, wanted %q", name, clean, test.clean)
+		}
+	}
+}
+
+func TestFlattenChatFixture(t *testing.T) {
+	doc, err := os.ReadFile("testdata/status-1.20.4.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status StatusResponse
+	if err := json.Unmarshal(doc, &status); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := flattenChat(status.Description), "Paper test server"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
+
+func TestTranslateKeys(t *testing.T) {
+	description := `{"translate":"motd.welcome","with":[{"translate":"server.name"},"x"],"extra":[{"text":" - "},[{"translate":"motd.welcome"},{"translate":"motd.players","extra":[{"translate":"ui.count"}]}]]}`
+	want := []string{"motd.welcome", "server.name", "motd.players", "ui.count"}
+	if got := translateKeys(json.RawMessage(description)); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	if got := translateKeys(json.RawMessage(`"§aA Minecraft Server"`)); got != nil {
+		t.Errorf("plain string: got %q", got)
+	}
+}
+
+func TestMOTDHosts(t *testing.T) {
+	motd := "Join Play.Example.com or 203.0.113.5:25566! Now on 1.20.4, see play.example.com, 999.1.2.3"
+	want := []string{"play.example.com", "203.0.113.5:25566"}
+	if got := motdHosts(motd); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("motdHosts() = %q, wanted %q", got, want)
+	}
+	if got := motdHosts("A Minecraft Server"); got != nil {
+		t.Errorf("motdHosts() = %q, wanted none", got)
+	}
+}
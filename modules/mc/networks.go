@@ -0,0 +1,102 @@
+package mc
+
+import (
+	"net"
+	"sort"
+	"sync"
+)
+
+// NetworkSummary counts, with --aggregate-prefix, the Minecraft servers
+// found in one network: how many answered with a status, the distinct
+// versions they reported, and their online players in total.
+type NetworkSummary struct {
+	Network  string   `json:"network"`
+	Servers  int      `json:"servers"`
+	Versions []string `json:"versions,omitempty"`
+	Players  int      `json:"players"`
+}
+
+// networkCount is the running count behind a NetworkSummary.
+type networkCount struct {
+	servers  int
+	versions map[string]bool
+	players  int
+}
+
+// networkSet aggregates the servers of the scan by network. It is shared by
+// all of the scan's senders.
+type networkSet struct {
+	mu       sync.Mutex
+	mask     net.IPMask
+	networks map[string]*networkCount
+}
+
+func newNetworkSet(prefix int) *networkSet {
+	return &networkSet{mask: net.CIDRMask(prefix, 8*net.IPv4len), networks: make(map[string]*networkCount)}
+}
+
+// add counts a server at ip, which reported version and players online.
+// Only IPv4 addresses are aggregated.
+func (n *networkSet) add(ip net.IP, version string, players int) {
+	ip = ip.To4()
+	if ip == nil {
+		return
+	}
+	network := (&net.IPNet{IP: ip.Mask(n.mask), Mask: n.mask}).String()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	count := n.networks[network]
+	if count == nil {
+		count = &networkCount{versions: make(map[string]bool)}
+		n.networks[network] = count
+	}
+	count.servers++
+	if version != "" {
+		count.versions[version] = true
+	}
+	count.players += players
+}
+
+// summaries returns the networks seen, those with the most servers first.
+func (n *networkSet) summaries() []NetworkSummary {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	summaries := make([]NetworkSummary, 0, len(n.networks))
+	for network, count := range n.networks {
+		summary := NetworkSummary{Network: network, Servers: count.servers, Players: count.players}
+		for version := range count.versions {
+			summary.Versions = append(summary.Versions, version)
+		}
+		sort.Strings(summary.Versions)
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Servers != summaries[j].Servers {
+			return summaries[i].Servers > summaries[j].Servers
+		}
+		return summaries[i].Network < summaries[j].Network
+	})
+	return summaries
+}
+
+// aggregate counts the server in results, at ip, towards its network if it
+// answered with a status.
+func (s *Scanner) aggregate(ip net.IP, results *Results) {
+	switch {
+	case results.Status != nil:
+		s.networks.add(ip, results.Status.Version.Name, results.Status.Players.Online)
+	case results.Bedrock != nil:
+		s.networks.add(ip, results.Bedrock.Version, results.Bedrock.OnlinePlayers)
+	case results.Query != nil:
+		s.networks.add(ip, results.Query.Version, results.Query.NumPlayers)
+	}
+}
+
+// ScanSummary returns the --aggregate-prefix summary of the networks seen in
+// the scan, for the scan summary, or nil without --aggregate-prefix.
+func (s *Scanner) ScanSummary() interface{} {
+	if s.networks == nil {
+		return nil
+	}
+	return s.networks.summaries()
+}
@@ -0,0 +1,45 @@
+package mc
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestNetworkSet(t *testing.T) {
+	networks := newNetworkSet(16)
+	networks.add(net.ParseIP("198.51.100.7"), "Paper 1.20.4", 12)
+	networks.add(net.ParseIP("198.51.3.9"), "1.8.8", 3)
+	networks.add(net.ParseIP("198.51.100.8"), "Paper 1.20.4", 0)
+	networks.add(net.ParseIP("203.0.113.1"), "", 5)
+	networks.add(net.ParseIP("2001:db8::1"), "1.21", 1)
+	want := []NetworkSummary{
+		{Network: "198.51.0.0/16", Servers: 3, Versions: []string{"1.8.8", "Paper 1.20.4"}, Players: 15},
+		{Network: "203.0.0.0/16", Servers: 1, Players: 5},
+	}
+	if got := networks.summaries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, wanted %+v", got, want)
+	}
+}
+
+func TestScanSummary(t *testing.T) {
+	scanner := newTestScanner(testHandshake(765))
+	if summary := scanner.ScanSummary(); summary != nil {
+		t.Errorf("without --aggregate-prefix: got %v", summary)
+	}
+	scanner.networks = newNetworkSet(24)
+	for _, status := range []string{
+		`{"version":{"name":"1.20.4","protocol":765},"players":{"max":20,"online":4},"description":"one"}`,
+		`{"version":{"name":"1.20.1","protocol":763},"players":{"max":20,"online":2},"description":"two"}`,
+	} {
+		if status, _, err := scanner.Scan(serveStatus(t, []byte(status))); err != nil || status != zgrab2.SCAN_SUCCESS {
+			t.Fatalf("received unexpected status: %s (%v)", status, err)
+		}
+	}
+	want := []NetworkSummary{{Network: "127.0.0.0/24", Servers: 2, Versions: []string{"1.20.1", "1.20.4"}, Players: 6}}
+	if got := scanner.ScanSummary(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, wanted %+v", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package mc
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/zmap/zgrab2/lib/varint"
+)
+
+// writeVarInt writes v to w as a VarInt.
+func writeVarInt(w io.Writer, v int) error {
+	_, err := varint.Write(w, v)
+	return err
+}
+
+// appendString appends s to b as a protocol string: its length in bytes as
+// a VarInt, then the UTF-8 bytes.
+func appendString(b []byte, s string) []byte {
+	return append(varint.Append(b, len(s)), s...)
+}
+
+// writeString writes s to w as a protocol string.
+func writeString(w io.Writer, s string) error {
+	_, err := w.Write(appendString(nil, s))
+	return err
+}
+
+// readString reads a protocol string, as written by appendString.
+func readString(r *bytes.Reader) (string, error) {
+	length, err := readVarInt(r)
+	if err != nil {
+		return "", err
+	}
+	if length < 0 || length > r.Len() {
+		return "", io.ErrUnexpectedEOF
+	}
+	b := make([]byte, length)
+	r.Read(b)
+	return string(b), nil
+}
+
+// buildPacket returns the packet with the given ID and payload, framed with
+// its VarInt length.
+func buildPacket(id int, payload []byte) []byte {
+	body := append(varint.Append(nil, id), payload...)
+	return append(varint.Append(nil, len(body)), body...)
+}
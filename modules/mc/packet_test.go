@@ -0,0 +1,66 @@
+package mc
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestWriteVarInt(t *testing.T) {
+	tests := map[int][]byte{
+		0:           {0x00},
+		1:           {0x01},
+		127:         {0x7f},
+		128:         {0x80, 0x01},
+		255:         {0xff, 0x01},
+		25565:       {0xdd, 0xc7, 0x01},
+		765:         {0xfd, 0x05},
+		2147483647:  {0xff, 0xff, 0xff, 0xff, 0x07},
+		-1:          {0xff, 0xff, 0xff, 0xff, 0x0f},
+		-2147483648: {0x80, 0x80, 0x80, 0x80, 0x08},
+	}
+	for v, want := range tests {
+		var buf bytes.Buffer
+		if err := writeVarInt(&buf, v); err != nil || !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("writeVarInt(%d) = %x, %v; wanted %x", v, buf.Bytes(), err, want)
+		}
+	}
+}
+
+func TestWriteString(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeString(&buf, "localhost"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte("\x09localhost"); !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got %x, wanted %x", buf.Bytes(), want)
+	}
+}
+
+func TestReadString(t *testing.T) {
+	r := bytes.NewReader(appendString(appendString(nil, "localhost"), ""))
+	for _, want := range []string{"localhost", ""} {
+		if got, err := readString(r); err != nil || got != want {
+			t.Errorf("got %q (%v), wanted %q", got, err, want)
+		}
+	}
+	// A length running past the end of the packet.
+	if _, err := readString(bytes.NewReader([]byte("\x09local"))); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated string: got %v", err)
+	}
+}
+
+func TestBuildPacket(t *testing.T) {
+	// The 1.20.4 handshake for localhost:25565, as sent by the vanilla
+	// client, and the status request after it.
+	handshake := []byte{
+		0x10, 0x00, 0xfd, 0x05, 0x09, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't', 0x63, 0xdd, 0x01,
+	}
+	payload := []byte{0xfd, 0x05, 0x09, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't', 0x63, 0xdd, 0x01}
+	if got := buildPacket(handshakeID, payload); !bytes.Equal(got, handshake) {
+		t.Errorf("handshake: got %x, wanted %x", got, handshake)
+	}
+	if got, want := buildPacket(statusRequestID, nil), []byte{0x01, 0x00}; !bytes.Equal(got, want) {
+		t.Errorf("status request: got %x, wanted %x", got, want)
+	}
+}
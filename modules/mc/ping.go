@@ -0,0 +1,97 @@
+package mc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"time"
+)
+
+// pingID is the ID of both the ping request and the pong response.
+const pingID = 0x01
+
+// pongPayload returns the long carried by a pong packet body.
+func pongPayload(packet []byte) (int64, bool) {
+	if len(packet) != 9 || packet[0] != pingID {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(packet[1:])), true
+}
+
+// pingPayload returns the long carried by a length-prefixed ping probe.
+func pingPayload(probe []byte) (int64, bool) {
+	r := bytes.NewReader(probe)
+	if _, err := readVarInt(r); err != nil {
+		return 0, false
+	}
+	return pongPayload(probe[len(probe)-r.Len():])
+}
+
+// LatencyStats summarises the ping round trip times of --ping-count, in
+// milliseconds.
+type LatencyStats struct {
+	// Rounds is the number of pongs received, which is less than
+	// --ping-count if the server closed the connection or stopped
+	// answering; vanilla servers close it after the first pong.
+	Rounds   int     `json:"rounds"`
+	MinMS    float64 `json:"min_ms"`
+	AvgMS    float64 `json:"avg_ms"`
+	MaxMS    float64 `json:"max_ms"`
+	StdDevMS float64 `json:"stddev_ms"`
+}
+
+// newLatencyStats summarises the round trip times in samples, which must
+// not be empty.
+func newLatencyStats(samples []float64) *LatencyStats {
+	stats := &LatencyStats{Rounds: len(samples), MinMS: samples[0], MaxMS: samples[0]}
+	var sum float64
+	for _, sample := range samples {
+		sum += sample
+		stats.MinMS = math.Min(stats.MinMS, sample)
+		stats.MaxMS = math.Max(stats.MaxMS, sample)
+	}
+	stats.AvgMS = sum / float64(len(samples))
+	var squares float64
+	for _, sample := range samples {
+		squares += (sample - stats.AvgMS) * (sample - stats.AvgMS)
+	}
+	stats.StdDevMS = math.Sqrt(squares / float64(len(samples)))
+	return stats
+}
+
+// pingRounds sends up to rounds more pings after the first, each with a
+// fresh payload, and returns the round trip times of those that were
+// answered with a matching pong. It stops at the first round that fails,
+// without failing the scan.
+func (s *Scanner) pingRounds(conn net.Conn, phase *deadlineReader, r *bufio.Reader, compressed bool, rounds int) []float64 {
+	var samples []float64
+	for i := 0; i < rounds; i++ {
+		payload := time.Now().UnixNano()
+		probe := buildPing(payload)
+		if compressed {
+			probe = compressedFrame(probe)
+		}
+		sent := time.Now()
+		if _, err := conn.Write(probe); err != nil {
+			break
+		}
+		phase.startPhase(s.config.ReadTimeout)
+		var pong []byte
+		var err error
+		if compressed {
+			pong, _, err = readCompressedPacket(r, s.config.MaxBannerSize)
+		} else {
+			pong, _, err = readFramedPacket(r, s.config.MaxBannerSize)
+		}
+		if err != nil {
+			break
+		}
+		if echoed, ok := pongPayload(pong); !ok || echoed != payload {
+			break
+		}
+		samples = append(samples, float64(time.Since(sent))/float64(time.Millisecond))
+	}
+	return samples
+}
@@ -0,0 +1,30 @@
+package mc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// readProbeFile reads the probes from path. With a separator, the file is
+// split at its first occurrence into probe1 and probe2; without one, the
+// whole file is probe1 and probe2 is empty.
+func readProbeFile(path, separator string) (probe1, probe2 []byte, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if separator == "" {
+		return data, nil, nil
+	}
+	sep, err := strconv.Unquote(`"` + separator + `"`)
+	if err != nil || len(sep) != 1 {
+		return nil, nil, fmt.Errorf("--probe-separator must be a single byte, given %q", separator)
+	}
+	i := bytes.IndexByte(data, sep[0])
+	if i < 0 {
+		return nil, nil, fmt.Errorf("%s does not contain the probe separator", path)
+	}
+	return data[:i], data[i+1:], nil
+}
@@ -0,0 +1,34 @@
+package mc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadProbeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "probes")
+	contents := append(append(append([]byte{}, testHandshake(765)...), 0xff), testPing...)
+	if err := os.WriteFile(path, contents, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	probe1, probe2, err := readProbeFile(path, `\xff`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(probe1) != string(testHandshake(765)) || string(probe2) != string(testPing) {
+		t.Errorf("got probes %x / %x", probe1, probe2)
+	}
+
+	probe1, probe2, err = readProbeFile(path, "")
+	if err != nil || string(probe1) != string(contents) || probe2 != nil {
+		t.Errorf("without a separator: got %x / %x, %v", probe1, probe2, err)
+	}
+
+	for _, sep := range []string{`\xfe`, "ab", `\q`} {
+		if _, _, err := readProbeFile(path, sep); err == nil {
+			t.Errorf("separator %q: expected an error", sep)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package mc
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// proxySignature starts every PROXY protocol v2 header.
+var proxySignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	// proxyV2Proxy is version 2 with the PROXY command.
+	proxyV2Proxy = 0x21
+
+	// Address family and transport bytes for TCP over IPv4 and IPv6.
+	proxyTCP4 = 0x11
+	proxyTCP6 = 0x21
+)
+
+// buildProxyHeader returns the PROXY protocol v2 header announcing a TCP
+// connection from local to remote. Mixed IPv4 and IPv6 addresses are both
+// sent as IPv6.
+func buildProxyHeader(local, remote net.Addr) ([]byte, error) {
+	src, srcOK := local.(*net.TCPAddr)
+	dst, dstOK := remote.(*net.TCPAddr)
+	if !srcOK || !dstOK {
+		return nil, errors.New("PROXY header needs TCP addresses")
+	}
+	header := append([]byte{}, proxySignature...)
+	var addrs []byte
+	if src4, dst4 := src.IP.To4(), dst.IP.To4(); src4 != nil && dst4 != nil {
+		header = append(header, proxyV2Proxy, proxyTCP4)
+		addrs = append(append(addrs, src4...), dst4...)
+	} else {
+		header = append(header, proxyV2Proxy, proxyTCP6)
+		addrs = append(append(addrs, src.IP.To16()...), dst.IP.To16()...)
+	}
+	addrs = binary.BigEndian.AppendUint16(addrs, uint16(src.Port))
+	addrs = binary.BigEndian.AppendUint16(addrs, uint16(dst.Port))
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addrs)))
+	return append(header, addrs...), nil
+}
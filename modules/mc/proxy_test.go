@@ -0,0 +1,72 @@
+package mc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestBuildProxyHeader(t *testing.T) {
+	local := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 40000}
+	remote := &net.TCPAddr{IP: net.ParseIP("198.51.100.7"), Port: 25565}
+	got, err := buildProxyHeader(local, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte("\r\n\r\n\x00\r\nQUIT\n"), 0x21, 0x11, 0x00, 0x0c,
+		192, 0, 2, 1, 198, 51, 100, 7, 0x9c, 0x40, 0x63, 0xdd)
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %x, wanted %x", got, want)
+	}
+
+	remote.IP = net.ParseIP("2001:db8::1")
+	got, err = buildProxyHeader(local, remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[13] != proxyTCP6 || len(got) != 16+36 || !net.IP(got[16:32]).Equal(local.IP) {
+		t.Errorf("mixed families: got %x", got)
+	}
+
+	if _, err := buildProxyHeader(&net.UDPAddr{}, remote); err == nil {
+		t.Error("accepted a UDP address")
+	}
+}
+
+// TestScanProxyProtocol checks that the header comes first, ahead of the
+// handshake.
+func TestScanProxyProtocol(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		header := make([]byte, 28)
+		io.ReadFull(conn, header)
+		received <- header
+	}()
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+
+	scanner := newTestScanner(testHandshake(765))
+	scanner.config.ProxyProtocol = true
+	scanner.Scan(target)
+	header := <-received
+	if !bytes.HasPrefix(header, proxySignature) || header[13] != proxyTCP4 ||
+		!net.IP(header[20:24]).Equal(net.ParseIP("127.0.0.1")) || binary.BigEndian.Uint16(header[26:]) != uint16(port) {
+		t.Errorf("got header %x", header)
+	}
+}
@@ -0,0 +1,230 @@
+package mc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+// Query protocol packet types.
+const (
+	queryTypeHandshake = 0x09
+	queryTypeStat      = 0x00
+)
+
+// queryMagic starts every query request.
+var queryMagic = []byte{0xFE, 0xFD}
+
+// queryPadding separates the full stat header from the key/value section,
+// and querySplitPlayers the key/value section from the player list.
+var (
+	queryPadding      = []byte("splitnum\x00\x80\x00")
+	querySplitPlayers = []byte("\x01player_\x00\x00")
+)
+
+// QueryStatus is the full stat returned by the UDP query protocol.
+type QueryStatus struct {
+	HostName   string `json:"hostname"`
+	GameType   string `json:"game_type,omitempty"`
+	GameID     string `json:"game_id,omitempty"`
+	Version    string `json:"version,omitempty"`
+	Map        string `json:"map,omitempty"`
+	NumPlayers int    `json:"num_players"`
+	MaxPlayers int    `json:"max_players"`
+	HostPort   int    `json:"host_port,omitempty"`
+	HostIP     string `json:"host_ip,omitempty"`
+
+	// Plugins is the plugins value as sent, e.g. "Paper on Bukkit 1.20.4:
+	// WorldEdit 7.2.15; LuckPerms 5.4"; ServerMod and PluginList are its
+	// two halves.
+	Plugins    string   `json:"plugins,omitempty"`
+	ServerMod  string   `json:"server_mod,omitempty"`
+	PluginList []string `json:"plugin_list,omitempty"`
+
+	Players []string `json:"players,omitempty"`
+
+	// Extra holds any keys not covered above.
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// querySessionID picks a session ID. Only the low four bits of each byte
+// are used, as vanilla servers mask the rest away.
+func querySessionID() uint32 {
+	return rand.Uint32() & 0x0F0F0F0F
+}
+
+// queryHandshake builds the handshake that asks for a challenge token.
+func queryHandshake(session uint32) []byte {
+	b := append([]byte{}, queryMagic...)
+	b = append(b, queryTypeHandshake)
+	return binary.BigEndian.AppendUint32(b, session)
+}
+
+// queryChallenge parses the handshake response: the type, the session ID,
+// then the challenge token as a NUL-terminated ASCII integer.
+func queryChallenge(resp []byte, session uint32) (int32, error) {
+	if len(resp) < 6 || resp[0] != queryTypeHandshake || binary.BigEndian.Uint32(resp[1:]) != session {
+		return 0, errors.New("not a query handshake response")
+	}
+	token := string(bytes.TrimRight(resp[5:], "\x00"))
+	challenge, err := strconv.ParseInt(token, 10, 32)
+	if err != nil {
+		return 0, errors.New("bad query challenge token")
+	}
+	return int32(challenge), nil
+}
+
+// queryFullStat builds the full stat request: the challenge token packed
+// big-endian, then four bytes of padding that ask for the full stat rather
+// than the basic one.
+func queryFullStat(session uint32, challenge int32) []byte {
+	b := append([]byte{}, queryMagic...)
+	b = append(b, queryTypeStat)
+	b = binary.BigEndian.AppendUint32(b, session)
+	b = binary.BigEndian.AppendUint32(b, uint32(challenge))
+	return append(b, 0, 0, 0, 0)
+}
+
+// parseFullStat parses a full stat response: the type and session ID,
+// padding, NUL-terminated key/value pairs ending with an empty key, then the
+// player list.
+func parseFullStat(resp []byte, session uint32) (*QueryStatus, error) {
+	if len(resp) < 5 || resp[0] != queryTypeStat || binary.BigEndian.Uint32(resp[1:]) != session {
+		return nil, errors.New("not a query stat response")
+	}
+	body := resp[5:]
+	if !bytes.HasPrefix(body, queryPadding) {
+		return nil, errors.New("not a full stat response")
+	}
+	body = body[len(queryPadding):]
+	players := []byte(nil)
+	if i := bytes.Index(body, querySplitPlayers); i >= 0 {
+		body, players = body[:i], body[i+len(querySplitPlayers):]
+	}
+	fields := strings.Split(string(body), "\x00")
+	status := new(QueryStatus)
+	for i := 0; i+1 < len(fields) && fields[i] != ""; i += 2 {
+		key, value := fields[i], fields[i+1]
+		switch key {
+		case "hostname":
+			status.HostName = value
+		case "gametype":
+			status.GameType = value
+		case "game_id":
+			status.GameID = value
+		case "version":
+			status.Version = value
+		case "plugins":
+			status.Plugins = value
+			status.ServerMod, status.PluginList = splitPlugins(value)
+		case "map":
+			status.Map = value
+		case "numplayers":
+			status.NumPlayers, _ = strconv.Atoi(value)
+		case "maxplayers":
+			status.MaxPlayers, _ = strconv.Atoi(value)
+		case "hostport":
+			status.HostPort, _ = strconv.Atoi(value)
+		case "hostip":
+			status.HostIP = value
+		default:
+			if status.Extra == nil {
+				status.Extra = make(map[string]string)
+			}
+			status.Extra[key] = value
+		}
+	}
+	for _, player := range strings.Split(string(players), "\x00") {
+		if player != "" {
+			status.Players = append(status.Players, player)
+		}
+	}
+	return status, nil
+}
+
+// splitPlugins splits the plugins value, "server mod: plugin; plugin",
+// into its parts.
+func splitPlugins(plugins string) (string, []string) {
+	mod, list, found := strings.Cut(plugins, ": ")
+	if !found {
+		return plugins, nil
+	}
+	var names []string
+	for _, name := range strings.Split(list, "; ") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return mod, names
+}
+
+// scanQuery performs the query handshake and full stat exchange over UDP.
+// As with --bedrock, a closed or filtered port is only detected by the read
+// timing out after --read-timeout, or --timeout if that is not set.
+func (s *Scanner) scanQuery(target *zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	results := new(Results)
+	conn, err := s.openUDP(target, results)
+	if errors.Is(err, ErrExcluded) {
+		return zgrab2.SCAN_SKIPPED, &Results{Skipped: skippedExcluded}, ErrExcluded
+	}
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), nil, err
+	}
+	defer conn.Close()
+
+	session := querySessionID()
+	buf := make([]byte, 1<<16)
+	exchange := func(request []byte) ([]byte, error) {
+		if _, err := conn.Write(request); err != nil {
+			return nil, err
+		}
+		if timeout := s.responseTimeout(); timeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(timeout))
+		}
+		n, err := conn.Read(buf)
+		if err != nil {
+			if zgrab2.IsTimeoutError(err) {
+				results.TimeoutPhase = phaseRead
+			}
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+
+	resp, err := exchange(queryHandshake(session))
+	if err != nil {
+		return zgrab2.TryGetScanStatus(err), results, err
+	}
+	challenge, err := queryChallenge(resp, session)
+	if err != nil {
+		return zgrab2.SCAN_PROTOCOL_ERROR, results, err
+	}
+	sent := time.Now()
+	if resp, err = exchange(queryFullStat(session, challenge)); err != nil {
+		return zgrab2.TryGetScanStatus(err), results, err
+	}
+	results.LatencyMS = float64(time.Since(sent)) / float64(time.Millisecond)
+	if s.config.RawBanner {
+		results.Banner1 = s.encodeBanner(resp)
+	}
+	if s.config.Decode {
+		results.Banner1Text = decodeText(resp)
+	}
+	results.responses = append(results.responses, resp)
+	if results.Query, err = parseFullStat(resp, session); err != nil {
+		return zgrab2.SCAN_PROTOCOL_ERROR, results, err
+	}
+	if results.QueryHostPort = results.Query.HostPort; results.QueryHostPort != 0 {
+		results.QueryPortMismatch = uint(results.QueryHostPort) != s.port(target)
+	}
+	results.MOTD = results.Query.HostName
+	results.MOTDClean = stripFormatting(results.MOTD)
+	s.matchTrap(resp, results)
+	return s.succeed(target, results)
+}
@@ -0,0 +1,103 @@
+package mc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+// testFullStat builds a full stat response for session.
+func testFullStat(session []byte) []byte {
+	b := append([]byte{queryTypeStat}, session...)
+	b = append(b, queryPadding...)
+	b = append(b, "hostname\x00A Minecraft Server\x00gametype\x00SMP\x00game_id\x00MINECRAFT\x00"+
+		"version\x001.20.4\x00plugins\x00Paper on Bukkit 1.20.4: WorldEdit 7.2.15; LuckPerms 5.4\x00"+
+		"map\x00world\x00numplayers\x002\x00maxplayers\x0020\x00hostport\x0025565\x00hostip\x00127.0.0.1\x00\x00"...)
+	b = append(b, querySplitPlayers...)
+	return append(b, "Notch\x00jeb_\x00\x00"...)
+}
+
+func TestParseFullStat(t *testing.T) {
+	session := []byte{0x01, 0x02, 0x03, 0x04}
+	status, err := parseFullStat(testFullStat(session), binary.BigEndian.Uint32(session))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &QueryStatus{
+		HostName:   "A Minecraft Server",
+		GameType:   "SMP",
+		GameID:     "MINECRAFT",
+		Version:    "1.20.4",
+		Map:        "world",
+		NumPlayers: 2,
+		MaxPlayers: 20,
+		HostPort:   25565,
+		HostIP:     "127.0.0.1",
+		Plugins:    "Paper on Bukkit 1.20.4: WorldEdit 7.2.15; LuckPerms 5.4",
+		ServerMod:  "Paper on Bukkit 1.20.4",
+		PluginList: []string{"WorldEdit 7.2.15", "LuckPerms 5.4"},
+		Players:    []string{"Notch", "jeb_"},
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("got %+v, wanted %+v", status, want)
+	}
+	if _, err := parseFullStat(testFullStat(session), 0x0F0F0F0F); err == nil {
+		t.Error("accepted a response for another session")
+	}
+}
+
+func TestScanQuery(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		n, addr, err := server.ReadFromUDP(buf)
+		if err != nil || n != 7 || !bytes.HasPrefix(buf, queryMagic) || buf[2] != queryTypeHandshake {
+			return
+		}
+		session := append([]byte{}, buf[3:7]...)
+		// A negative token checks that it is packed as a signed integer.
+		server.WriteToUDP(append(append([]byte{queryTypeHandshake}, session...), "-1234567\x00"...), addr)
+		if n, addr, err = server.ReadFromUDP(buf); err != nil || n != 15 || buf[2] != queryTypeStat {
+			return
+		}
+		if !bytes.Equal(buf[3:7], session) || int32(binary.BigEndian.Uint32(buf[7:11])) != -1234567 {
+			return
+		}
+		server.WriteToUDP(testFullStat(session), addr)
+	}()
+	port := uint(server.LocalAddr().(*net.UDPAddr).Port)
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+
+	scanner := newTestScanner(nil)
+	scanner.config.Query = true
+	status, res, err := scanner.Scan(target)
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("received unexpected status: %s (%v)", status, err)
+	}
+	results := res.(*Results)
+	if results.Query == nil || len(results.Query.Players) != 2 || results.MOTD != "A Minecraft Server" {
+		t.Errorf("received unexpected results: %+v", results)
+	}
+	// The server reports 25565, not the port it was scanned on.
+	if results.QueryHostPort != 25565 || !results.QueryPortMismatch {
+		t.Errorf("got query host port %d, mismatch %v", results.QueryHostPort, results.QueryPortMismatch)
+	}
+
+	// Nothing answers a second handshake; with no --read-timeout, the read
+	// times out after --timeout.
+	scanner.config.ReadTimeout = 0
+	scanner.config.Timeout = 100 * time.Millisecond
+	status, res, _ = scanner.Scan(target)
+	if status != zgrab2.SCAN_IO_TIMEOUT || res.(*Results).TimeoutPhase != phaseRead {
+		t.Errorf("silent server: got %s, %+v", status, res)
+	}
+}
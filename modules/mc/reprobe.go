@@ -0,0 +1,61 @@
+package mc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+
+	"github.com/zmap/zgrab2"
+)
+
+// fetchStatus opens a new connection to the target, or to the SRV target
+// recorded in via, sends a built handshake addressed to host with protoVer,
+// and returns the status packet the server answers with. It backs the
+// probes that compare the server's answers to different handshakes, each of
+// which needs a connection of its own.
+func (s *Scanner) fetchStatus(target *zgrab2.ScanTarget, via *Results, host string, protoVer int) ([]byte, error) {
+	scratch := &Results{SRVHost: via.SRVHost, SRVPort: via.SRVPort}
+	conn, err := s.dial(target, scratch)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if s.config.ProxyProtocol {
+		header, err := buildProxyHeader(conn.LocalAddr(), conn.RemoteAddr())
+		if err != nil {
+			return nil, err
+		}
+		if _, err := conn.Write(header); err != nil {
+			return nil, err
+		}
+	}
+	port := uint16(s.port(target))
+	if scratch.SRVPort != 0 {
+		port = scratch.SRVPort
+	}
+	if _, err := conn.Write(buildHandshake(host, port, protoVer)); err != nil {
+		return nil, err
+	}
+	phase := &deadlineReader{conn: conn}
+	reader := bufio.NewReader(phase)
+	phase.startPhase(s.config.ReadTimeout)
+	data, count, err := readFramedPacket(reader, s.statusLimit())
+	if err == nil && isSetCompression(data) {
+		threshold, terr := readVarInt(bytes.NewReader(data[1:]))
+		if terr != nil {
+			return nil, compressionError("bad compression threshold")
+		}
+		if threshold >= 0 {
+			data, count, err = readCompressedPacket(reader, s.statusLimit())
+		} else {
+			data, count, err = readFramedPacket(reader, s.statusLimit())
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if count.Read < count.Declared {
+		return nil, errors.New("status response truncated")
+	}
+	return data, nil
+}
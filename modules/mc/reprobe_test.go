@@ -0,0 +1,55 @@
+package mc
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/varint"
+)
+
+// serveHandshakes answers every connection to a local listener with the
+// status answer returns for the server address and protocol version in its
+// handshake, then echoes a ping if one is sent.
+func serveHandshakes(t *testing.T, answer func(host string, protocol int) []byte) zgrab2.ScanTarget {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				handshake, err := readTestPacket(conn)
+				if err != nil {
+					return
+				}
+				r := bytes.NewReader(handshake[1:])
+				protocol, _, _ := varint.Read(r)
+				host, _ := readString(r)
+				if _, err := readTestPacket(conn); err != nil {
+					return
+				}
+				status := answer(host, protocol)
+				if status == nil {
+					return
+				}
+				payload := append(varint.Append(nil, len(status)), status...)
+				conn.Write(framePacket(0x00, payload))
+				if ping, err := readTestPacket(conn); err == nil {
+					conn.Write(append(varint.Append(nil, len(ping)), ping...))
+				}
+			}()
+		}
+	}()
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	return zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+}
@@ -0,0 +1,155 @@
+package mc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDNSPort  = "53"
+	dnsMessageMedia = "application/dns-message"
+)
+
+// newResolver builds the resolver for --resolver. spec is either a DNS
+// server address, with port 53 if none is given, or an https:// DNS-over-HTTPS
+// endpoint (RFC 8484). An empty spec returns nil, for the system resolver.
+func newResolver(spec string) (*net.Resolver, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(spec, "https://") {
+		return newDoHResolver(spec, &http.Client{Timeout: 10 * time.Second}), nil
+	}
+	addr := spec
+	if _, _, err := net.SplitHostPort(spec); err != nil {
+		addr = net.JoinHostPort(spec, defaultDNSPort)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) == nil {
+		return nil, fmt.Errorf("resolver %q is not an IP address", host)
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}, nil
+}
+
+func newDoHResolver(url string, client *http.Client) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, client: client, url: url}, nil
+		},
+	}
+}
+
+// dohConn carries the Go resolver's DNS-over-TCP exchange over HTTPS. Since
+// it is not a net.PacketConn, the resolver writes each query with a two-byte
+// length prefix and expects replies framed the same way; each query is
+// POSTed to the endpoint and the reply queued for reading.
+type dohConn struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+
+	mu       sync.Mutex
+	pending  bytes.Buffer
+	replies  bytes.Buffer
+	deadline time.Time
+}
+
+// errDoHClosed is returned by reads on a dohConn with no reply queued.
+var errDoHClosed = errors.New("doh: no reply pending")
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending.Write(b)
+	for c.pending.Len() >= 2 {
+		n := int(binary.BigEndian.Uint16(c.pending.Bytes()))
+		if c.pending.Len() < 2+n {
+			break
+		}
+		c.pending.Next(2)
+		reply, err := c.exchange(c.pending.Next(n))
+		if err != nil {
+			return 0, err
+		}
+		var prefix [2]byte
+		binary.BigEndian.PutUint16(prefix[:], uint16(len(reply)))
+		c.replies.Write(prefix[:])
+		c.replies.Write(reply)
+	}
+	return len(b), nil
+}
+
+func (c *dohConn) exchange(query []byte) ([]byte, error) {
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dnsMessageMedia)
+	req.Header.Set("Accept", dnsMessageMedia)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("doh: %s returned %s", c.url, resp.Status)
+	}
+	// A DNS message is at most 64KiB.
+	reply, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.replies.Len() == 0 {
+		return 0, errDoHClosed
+	}
+	return c.replies.Read(b)
+}
+
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
+
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr(c.url) }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr(c.url) }
+
+type dohAddr string
+
+func (a dohAddr) Network() string { return "https" }
+func (a dohAddr) String() string  { return string(a) }
@@ -0,0 +1,152 @@
+package mc
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zmap/zgrab2"
+)
+
+// answerA replies to a single-question DNS query with one A record for ip,
+// or with no answers if the question is not type A.
+func answerA(query []byte, ip net.IP) []byte {
+	// Skip the 12-byte header and the question name.
+	end := 12
+	for query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	end += 5 // root label, QTYPE, QCLASS
+	qtype := binary.BigEndian.Uint16(query[end-4:])
+
+	reply := append([]byte{}, query[:end]...)
+	reply[2] = 0x81                           // QR, RD
+	reply[3] = 0x80                           // RA, NOERROR
+	binary.BigEndian.PutUint16(reply[6:], 0)  // ANCOUNT
+	binary.BigEndian.PutUint16(reply[8:], 0)  // NSCOUNT
+	binary.BigEndian.PutUint16(reply[10:], 0) // ARCOUNT
+	if qtype != 1 {
+		return reply
+	}
+	binary.BigEndian.PutUint16(reply[6:], 1)
+	return append(reply,
+		0xc0, 0x0c, // pointer to the question name
+		0x00, 0x01, 0x00, 0x01, // A, IN
+		0x00, 0x00, 0x00, 0x3c, // TTL
+		0x00, 0x04, ip[0], ip[1], ip[2], ip[3])
+}
+
+func TestDoHResolver(t *testing.T) {
+	want := net.IPv4(192, 0, 2, 7).To4()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dnsMessageMedia {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		query, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", dnsMessageMedia)
+		w.Write(answerA(query, want))
+	}))
+	defer server.Close()
+
+	resolver := newDoHResolver(server.URL, server.Client())
+	addrs, err := resolver.LookupIPAddr(context.Background(), "mc.example.com")
+	if err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+	if len(addrs) != 1 || !addrs[0].IP.Equal(want) {
+		t.Errorf("got %v, wanted [%v]", addrs, want)
+	}
+}
+
+func TestNewResolver(t *testing.T) {
+	if r, err := newResolver(""); r != nil || err != nil {
+		t.Errorf("empty spec: got %v, %v; wanted the system resolver", r, err)
+	}
+	for _, spec := range []string{"1.1.1.1", "1.1.1.1:53", "[2606:4700:4700::1111]:53", "https://dns.example/dns-query"} {
+		if _, err := newResolver(spec); err != nil {
+			t.Errorf("%s: unexpected error %v", spec, err)
+		}
+	}
+	if _, err := newResolver("dns.example"); err == nil {
+		t.Errorf("hostname resolver: expected an error")
+	}
+}
+
+// serveDNS answers every query on a local UDP port with an A record for
+// 127.0.0.1, returning the server's address.
+func serveDNS(t *testing.T) string {
+	dns, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dns.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := dns.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			dns.WriteToUDP(answerA(buf[:n], net.IPv4(127, 0, 0, 1).To4()), addr)
+		}
+	}()
+	return dns.LocalAddr().String()
+}
+
+func TestResolveTargets(t *testing.T) {
+	// A Bedrock server, resolved with --resolver before the ping is sent.
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		n, addr, err := server.ReadFromUDP(buf)
+		if err == nil {
+			server.WriteToUDP(bedrockPong(buf[:n], testBedrockID), addr)
+		}
+	}()
+	port := uint(server.LocalAddr().(*net.UDPAddr).Port)
+
+	scanner := newTestScanner(nil)
+	scanner.config.Bedrock = true
+	scanner.config.ConnMetadata = true
+	if scanner.resolver, err = newResolver(serveDNS(t)); err != nil {
+		t.Fatal(err)
+	}
+	status, res, err := scanner.Scan(zgrab2.ScanTarget{Domain: "bedrock.example", Port: &port})
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("bedrock: received unexpected status: %s (%v)", status, err)
+	}
+	if results := res.(*Results); results.Bedrock == nil || results.ResolveMS <= 0 {
+		t.Errorf("bedrock: got %+v, wanted a pong and resolve_ms", results)
+	}
+
+	// A Java server over TCP, with and without --conn-metadata.
+	scanner = newTestScanner(testHandshake(765))
+	if scanner.resolver, err = newResolver(serveDNS(t)); err != nil {
+		t.Fatal(err)
+	}
+	for _, metadata := range []bool{true, false} {
+		target := serveStatus(t, []byte(`{"description":"resolved"}`))
+		target.IP, target.Domain = nil, "java.example"
+		scanner.config.ConnMetadata = metadata
+		status, res, err = scanner.Scan(target)
+		if err != nil || status != zgrab2.SCAN_SUCCESS {
+			t.Fatalf("java: received unexpected status: %s (%v)", status, err)
+		}
+		if got := res.(*Results).ResolveMS; (got > 0) != metadata {
+			t.Errorf("java, --conn-metadata %v: got resolve_ms %v", metadata, got)
+		}
+	}
+}
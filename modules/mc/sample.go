@@ -0,0 +1,26 @@
+package mc
+
+import (
+	"regexp"
+	"strings"
+)
+
+// realPlayers returns the distinct names in the players sample that are not
+// decorative. Servers pad the sample with entries used as extra MOTD lines:
+// names that are empty or blank once formatting codes are stripped, and any
+// that decorative (--decorative-sample) matches after stripping.
+func realPlayers(sample []PlayerSample, decorative *regexp.Regexp) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, player := range sample {
+		clean := strings.TrimSpace(stripFormatting(player.Name))
+		if clean == "" || (decorative != nil && decorative.MatchString(clean)) {
+			continue
+		}
+		if !seen[player.Name] {
+			seen[player.Name] = true
+			names = append(names, player.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,26 @@
+package mc
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRealPlayers(t *testing.T) {
+	sample := []PlayerSample{
+		{Name: "Notch"},
+		{Name: ""},
+		{Name: "   "},
+		{Name: "§a§l"},
+		{Name: "§6Join our Discord!"},
+		{Name: "jeb_"},
+		{Name: "Notch"},
+	}
+	if got, want := realPlayers(sample, nil), "Notch,§6Join our Discord!,jeb_"; strings.Join(got, ",") != want {
+		t.Errorf("realPlayers() = %q, wanted %s", got, want)
+	}
+	decorative := regexp.MustCompile(`(?i)discord|\s`)
+	if got, want := realPlayers(sample, decorative), "Notch,jeb_"; strings.Join(got, ",") != want {
+		t.Errorf("realPlayers() with --decorative-sample = %q, wanted %s", got, want)
+	}
+}
@@ -4,49 +4,427 @@
 package mc
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/varint"
 )
 
 // Flags give the command-line flags for the banner module.
 type Flags struct {
 	zgrab2.BaseFlags
-	Probe1 string `long:"probe1" default:"\\n" description:"Probe to send to the server. Use triple slashes to escape, for example \\\\\\n is literal \\n. Mutually exclusive with --probe-file."`
-	Probe2 string `long:"probe2" default:"\\n" description:"Second probe to send to the server. Use triple slashes to escape, for example \\\\\\n is literal \\n. Mutually exclusive with --probe-file."`
+	zgrab2.UDPFlags
+	Probe1         string        `long:"probe1" default:"\\n" description:"Probe to send to the server. Use triple slashes to escape, for example \\\\\\n is literal \\n. Mutually exclusive with --probe-file."`
+	Probe2         string        `long:"probe2" default:"\\n" description:"Second probe to send to the server. Use triple slashes to escape, for example \\\\\\n is literal \\n. Mutually exclusive with --probe-file."`
+	BuildHandshake bool          `long:"build-handshake" description:"Build the handshake and status request from the target and --protocol-version instead of sending --probe1, and a ping with a fresh payload unless --probe2 is given"`
+	HandshakeHost  string        `long:"handshake-host" description:"Server address to send in the handshake built by --build-handshake or --login-probe, in place of the target's name or IP"`
+	FML            bool          `long:"fml" description:"Append the Forge marker for --protocol-version (\\0FML\\0, \\0FML2\\0 or \\0FML3\\0) to the handshake's server address, as Forge clients do"`
+	ProtocolVer    int           `long:"protocol-version" default:"-1" description:"Protocol version sent by --build-handshake; -1 is the conventional value for a status-only client (see the module help for others)"`
+	Bedrock        bool          `long:"bedrock" description:"Send a Bedrock edition RakNet unconnected ping over UDP instead of the Java probes (Bedrock servers listen on 19132)"`
+	Query          bool          `long:"query" description:"Fetch the full stat with the UDP query protocol (enable-query in server.properties) instead of the Server List Ping"`
+	LoginProbe     bool          `long:"login-probe" description:"Send a login handshake and Login Start instead of the status request, and record whether the server asks for encryption (online-mode); never authenticates"`
+	ClientBrand    string        `long:"client-brand" description:"With --login-probe, carry an offline-mode login on to the configuration state (1.20.2 onwards), announce this client brand on minecraft:brand, and record whether the server kicks or proceeds"`
+	Legacy         bool          `long:"legacy" description:"Send the pre-1.7 legacy ping (0xFE 0x01) instead of the probes and parse the status from the kick packet"`
+	ProbeFile      string        `long:"probe-file" description:"Read the probes from a file as raw bytes: probe1, then --probe-separator and probe2 if one is given. Mutually exclusive with --probe1 and --probe2."`
+	ProbeSep       string        `long:"probe-separator" description:"Single byte, escaped as for --probe1 (e.g. \\x00), separating probe1 from probe2 in --probe-file"`
+	ProxyProtocol  bool          `long:"proxy-protocol" description:"Send a PROXY protocol v2 header with the connection's source and destination addresses before the probes, for servers behind a proxy that requires one"`
+	SoRcvBuf       int           `long:"so-rcvbuf" description:"Set SO_RCVBUF on the TCP socket, in bytes (0 = system default)"`
+	SoSndBuf       int           `long:"so-sndbuf" description:"Set SO_SNDBUF on the TCP socket, in bytes (0 = system default)"`
+	ConnectTimeout time.Duration `long:"connect-timeout" default:"3s" description:"Time to wait for the TCP connection to be established, separately from --timeout for the rest of the session"`
+	MaxBannerSize  int           `long:"max-banner-size" default:"32800" description:"Longest packet, in bytes, accepted in reply to either probe, before and after decompression"`
+	MaxFaviconSize int           `long:"max-favicon-size" description:"Bytes allowed for the favicon in the status response on top of --max-banner-size, which then bounds the rest; a larger favicon is dropped (0 = the favicon counts against --max-banner-size)"`
+	PingCount      int           `long:"ping-count" default:"1" description:"Pings to send on the connection, one after another, recording their latency statistics as latency_stats when more than one"`
+	ReadTimeout    time.Duration `long:"read-timeout" default:"5s" description:"Time to wait for each response (the status, then the pong) to be read in full"`
+	ExcludeRanges  string        `long:"exclude-ranges" description:"File of IP addresses / CIDR blocks, one per line, that will not be scanned"`
+	AllowPrivate   bool          `long:"allow-private" description:"Scan private, loopback and other non-routable ranges, which are skipped by default"`
+	SRV            bool          `long:"srv" description:"Look up the _minecraft._tcp SRV record of domain targets and scan the host and port it names, as clients do"`
+	SRVAll         bool          `long:"srv-all" description:"With --srv, also fetch the status from every other server the SRV record names, recording each in srv_targets"`
+	Resolver       string        `long:"resolver" description:"DNS server (host[:port]) or DNS-over-HTTPS URL (https://...) used to resolve domain targets instead of the system resolver"`
+	ConnMetadata   bool          `long:"conn-metadata" description:"Record connection metadata: resolve_ms, the time spent resolving a domain target"`
+	ExpectedPorts  string        `long:"expected-ports" default:"25565,25566" description:"Comma-separated ports Minecraft is expected on; responses from other ports are flagged as unusual_port (19132,19133 with --bedrock unless given)"`
+	Liveness       bool          `long:"liveness" description:"Only complete the TCP handshake and record whether the port is open, closed or filtered; no Minecraft bytes are sent"`
+	OutputFields   string        `long:"output-fields" description:"Comma-separated result fields to output, dropping all others (e.g. banner1,legacy_only)"`
+	TrapSignatures string        `long:"trap-signatures" description:"File of known honeypot responses, one \"label regexp\" per line, matched against the raw status and legacy kick responses"`
+	FrameDetail    bool          `long:"frame-detail" description:"Record the packet ID, string length and any trailing bytes of the status response"`
+	RecordTrailing bool          `long:"record-trailing" description:"After the exchange, count the bytes the server sends beyond what the protocol requires (waits up to 500ms)"`
+	Match          string        `long:"match" description:"Expression over the result fields, e.g. 'banner1_bytes.read > 1000 && !legacy_only'; results it matches are flagged as matched"`
+	Pattern        string        `long:"pattern" description:"Regexp matched against the raw responses (status, pong, legacy kick or Bedrock pong); results it matches are flagged as matched"`
+	PatternMiss    string        `long:"pattern-miss-status" default:"protocol-error" choice:"protocol-error" choice:"application-error" choice:"success" description:"Status of results that do not match --pattern"`
+	MatchOnly      bool          `long:"match-only" description:"Replace results that do not satisfy --match and --pattern with a bare skipped: unmatched"`
+	Encoding       string        `long:"encoding" default:"hex" choice:"hex" choice:"base64" description:"Encoding of the raw packets output as banner1 and banner2"`
+	RawBanner      bool          `long:"raw-banner" description:"Also output the raw status response packet, hex-encoded, as banner1"`
+	Decode         bool          `long:"decode" description:"Also output the status and pong packets as UTF-8 text, with invalid bytes replaced, as banner1_text and banner2_text"`
+	CatchAll       bool          `long:"catch-all" description:"Fetch the status twice more, addressed to the target's name and to a made-up one, and flag catch_all if the two are identical"`
+	MismatchProbe  bool          `long:"mismatch-probe" description:"Fetch the status again with an unsupported protocol version and record the version name the server answers that with"`
+	AddressProbe   int           `long:"address-probe" description:"Robustness probe: fetch the status again with a server address of this many bytes (at most 1024; the protocol allows 255 characters) and record whether the server accepted it, kicked or closed (0 = off)"`
+	ForcedHosts    string        `long:"enumerate-forced-hosts" description:"File of candidate hostnames, one per line; fetch the status addressed to each and record the backend each routes to, to map a BungeeCord or Velocity proxy's forced hosts"`
+	ForcedDelay    time.Duration `long:"forced-host-delay" default:"100ms" description:"Pause before each --enumerate-forced-hosts fetch, which all go to the same host"`
+	CleanSample    bool          `long:"clean-sample" description:"Record the players sample without its decorative entries (blank names and names of only formatting codes) and duplicates, as real_players"`
+	Decorative     string        `long:"decorative-sample" description:"Regexp marking further sample names as decorative for --clean-sample, matched after formatting codes are stripped"`
+	DefaultMOTDs   string        `long:"default-motds" description:"File of default MOTDs, one per line without formatting codes, replacing the built-in list that default_motd is matched against"`
+	StartKeywords  string        `long:"starting-keywords" description:"File of MOTD keywords, one per line without formatting codes, replacing the built-in list that marks a server as starting up"`
+	Cluster        bool          `long:"cluster" description:"Group the servers of the scan into clusters by shared favicon, or by MOTD template (with hosts removed), version and player limit, recording cluster_id and cluster_size"`
+	NetworkPrefix  int           `long:"aggregate-prefix" description:"Summarize the servers found per IPv4 network of this prefix length, e.g. 24 or 16, in the scan summary: servers answering, distinct versions and total players (0 = off)"`
+	MOTDHosts      bool          `long:"motd-hosts" description:"Record the hostnames and IP addresses advertised in the MOTD as motd_hosts"`
+	CaptureExtra   bool          `long:"capture-extra" description:"Record the translation keys used in the status MOTD's chat components as translate_keys"`
+	EOFPolicy      string        `long:"eof-policy" default:"truncate" choice:"error" choice:"truncate" choice:"success" description:"How to treat the server closing mid-packet: fail the scan, emit the truncated result, or carry on as if complete"`
 }
 
+// defaultProbe is the default value of --probe1 and --probe2.
+const defaultProbe = "\\n"
+
+// Encodings accepted by --encoding.
+const (
+	encodingHex    = "hex"
+	encodingBase64 = "base64"
+)
+
+// EOF policies accepted by --eof-policy.
+const (
+	eofPolicyError    = "error"
+	eofPolicyTruncate = "truncate"
+	eofPolicySuccess  = "success"
+)
+
 // Module is the implementation of the zgrab2.Module interface.
 type Module struct {
 }
 
 // Scanner is the implementation of the zgrab2.Scanner interface.
 type Scanner struct {
-	config *Flags
-	probe1 []byte
-	probe2 []byte
+	config   *Flags
+	probe1   []byte
+	probe2   []byte
+	excluded *rangeSet
+	resolver *net.Resolver
+	expected map[uint]bool
+	fields   map[string]bool
+	traps    []trapSignature
+	matcher  matcher
+	pattern  *regexp.Regexp
+
+	// decorative is the compiled --decorative-sample.
+	decorative *regexp.Regexp
+
+	// defaultMOTDs are what default_motd is matched against, and
+	// startingKeywords what marks a server as starting.
+	defaultMOTDs     []string
+	startingKeywords []string
+
+	// forcedHosts are the --enumerate-forced-hosts candidates.
+	forcedHosts []string
+
+	// clusters holds the --cluster traits seen so far in the scan.
+	clusters *clusterSet
+
+	// networks aggregates the servers found with --aggregate-prefix.
+	networks *networkSet
 }
 
+// ErrExcluded is returned for targets in an excluded range.
+var ErrExcluded = errors.New("target is in an excluded range")
+
+// Results.Skipped reasons.
+const (
+	skippedExcluded  = "excluded"
+	skippedUnmatched = "unmatched"
+)
+
+// errUnmatched is returned with --match-only for results --match rejects.
+var errUnmatched = errors.New("result does not satisfy --match")
+
+// errNoPatternMatch is returned for responses --pattern does not match.
+var errNoPatternMatch = errors.New("response does not match --pattern")
+
 // ScanResults instances are returned by the module's Scan function.
 type Results struct {
-	Banner1 string `json:"banner1,omitempty"`
-	Banner2 string `json:"banner2,omitempty"`
+	// Status is the parsed status response, or nil if the server did not
+	// send a valid one.
+	Status *StatusResponse `json:"status,omitempty"`
+
+	// RequestedProtocol is the protocol version sent in the handshake, and
+	// ProtocolVersion the one the server reported in its status, side by
+	// side to show whether the server took the version sent or answered
+	// with its own. Either is left out when not known.
+	RequestedProtocol *int `json:"requested_protocol,omitempty"`
+	ProtocolVersion   *int `json:"protocol_version,omitempty"`
+
+	// MOTD is the status description flattened to plain text; MOTDClean
+	// is MOTD with the § formatting codes removed.
+	MOTD      string `json:"motd,omitempty"`
+	MOTDClean string `json:"motd_clean,omitempty"`
+
+	// TranslateKeys are the translation keys of the status description's
+	// chat components, with --capture-extra, in the order they first
+	// appear. MOTD leaves translated components out.
+	TranslateKeys []string `json:"translate_keys,omitempty"`
+
+	// DefaultMOTD is set when MOTDClean is empty or one of the MOTDs servers
+	// ship with (see --default-motds); DefaultMOTDMatch is the one it is.
+	DefaultMOTD      bool   `json:"default_motd,omitempty"`
+	DefaultMOTDMatch string `json:"default_motd_match,omitempty"`
+
+	// Starting is set for a server that answers the status while it is
+	// still starting up, as managed hosts do: no player slots yet, and a
+	// MOTD or version name with one of --starting-keywords in it, which
+	// StartingKeyword is.
+	Starting        bool   `json:"starting,omitempty"`
+	StartingKeyword string `json:"starting_keyword,omitempty"`
+
+	// MOTDHosts are the hostnames and IP addresses found in MOTDClean, with
+	// --motd-hosts, lowercased and in the order they first appear.
+	MOTDHosts []string `json:"motd_hosts,omitempty"`
+
+	// RealPlayers are the distinct names in the players sample that are
+	// not decorative, with --clean-sample; the sample as sent stays in
+	// Status.
+	RealPlayers []string `json:"real_players,omitempty"`
+
+	// Favicon is the server icon from the status response, if it sent a
+	// valid PNG.
+	Favicon *Favicon `json:"favicon,omitempty"`
+
+	// Mods and ForgeChannels are what a Forge server lists in its status
+	// response. ModsTruncated is set when the server cut the list short.
+	Mods          []ModInfo      `json:"mods,omitempty"`
+	ForgeChannels []ForgeChannel `json:"forge_channels,omitempty"`
+	ModsTruncated bool           `json:"mods_truncated,omitempty"`
+
+	// Modpack is the pack named in the status response's modpackData, if
+	// the server sent one.
+	Modpack *Modpack `json:"modpack,omitempty"`
+
+	// LatencyMS is the time from sending the ping to receiving the pong.
+	// PongPayload is the value the pong carried back, and PongEchoed is set
+	// if it matches the one sent.
+	LatencyMS   float64 `json:"latency_ms,omitempty"`
+	PongPayload int64   `json:"pong_payload,omitempty"`
+	PongEchoed  bool    `json:"pong_echoed,omitempty"`
+
+	// LatencyStats covers every ping round, the first included, with a
+	// --ping-count above 1.
+	LatencyStats *LatencyStats `json:"latency_stats,omitempty"`
+
+	// ResolveMS is the time spent looking up the target's name, and its SRV
+	// record with --srv, with --conn-metadata.
+	ResolveMS float64 `json:"resolve_ms,omitempty"`
+
+	// Banner1 is the raw status response packet, with --raw-banner, and
+	// Banner2 the reply to probe2, both encoded as Encoding says (hex or
+	// base64, from --encoding).
+	Banner1  string `json:"banner1,omitempty"`
+	Banner2  string `json:"banner2,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+
+	// Banner1Text and Banner2Text are the packets read as UTF-8, with
+	// --decode.
+	Banner1Text string `json:"banner1_text,omitempty"`
+	Banner2Text string `json:"banner2_text,omitempty"`
+
+	// Banner1Bytes and Banner2Bytes compare each packet's declared length
+	// with the number of bytes actually read before the server closed.
+	Banner1Bytes *ReadCount `json:"banner1_bytes,omitempty"`
+	Banner2Bytes *ReadCount `json:"banner2_bytes,omitempty"`
+
+	// SizeLimitExceeded names the limit the status response broke with
+	// --max-favicon-size: favicon, for which the favicon is dropped, or
+	// banner, for which the scan fails.
+	SizeLimitExceeded string `json:"size_limit_exceeded,omitempty"`
+
+	// Framing is the decoded layout of the status packet, with
+	// --frame-detail.
+	Framing *Framing `json:"framing,omitempty"`
+
+	// StatusCompression is set when the server sent Set Compression before
+	// the status response, with CompressionThreshold the threshold it
+	// announced. Banner1 and Banner2 then hold the decompressed packets. A
+	// negative threshold, which leaves compression off, is recorded without
+	// StatusCompression.
+	StatusCompression    bool `json:"status_compression,omitempty"`
+	CompressionThreshold int  `json:"compression_threshold,omitempty"`
+
+	// LegacyKickMessage is the message of the legacy (pre-1.7) kick packet
+	// the server answered the handshake or --legacy ping with. LegacyOnly is
+	// set when it answered the handshake, since such servers need to be
+	// re-probed with --legacy.
+	LegacyKickMessage string `json:"legacy_kick_message,omitempty"`
+	LegacyOnly        bool   `json:"legacy_only,omitempty"`
+
+	// Bedrock is the status from a --bedrock unconnected pong.
+	Bedrock *BedrockStatus `json:"bedrock,omitempty"`
+
+	// Query is the full stat from --query.
+	Query *QueryStatus `json:"query,omitempty"`
+
+	// QueryHostPort is the port the server reports in its --query full
+	// stat. QueryPortMismatch is set when that is not the port scanned, as
+	// behind NAT or a proxy.
+	QueryHostPort     int  `json:"query_host_port,omitempty"`
+	QueryPortMismatch bool `json:"query_port_mismatch,omitempty"`
+
+	// LoginResponse names the packet the server answered --login-probe
+	// with: encryption_request, login_success, set_compression,
+	// plugin_request or disconnect. OnlineMode is set for an Encryption
+	// Request that asks the client to authenticate, which only online-mode
+	// servers send, and PublicKeyLength is the length of the key it carried.
+	// LoginDisconnect is the reason given by a disconnect.
+	LoginResponse   string `json:"login_response,omitempty"`
+	OnlineMode      bool   `json:"online_mode,omitempty"`
+	PublicKeyLength int    `json:"public_key_length,omitempty"`
+	LoginDisconnect string `json:"login_disconnect,omitempty"`
+
+	// ClientBrand is the brand announced with --client-brand, set once it
+	// was sent, and BrandOutcome what the server did next: kicked, with
+	// BrandDisconnect its reason, proceeded or closed.
+	ClientBrand     string `json:"client_brand,omitempty"`
+	BrandOutcome    string `json:"brand_outcome,omitempty"`
+	BrandDisconnect string `json:"brand_disconnect,omitempty"`
+
+	// Legacy is the status parsed from the legacy kick message, if it is
+	// one; always the case for a --legacy ping.
+	Legacy *LegacyStatus `json:"legacy,omitempty"`
+
+	// TimeoutPhase names the phase (connect, write or read) that timed out,
+	// if the scan failed on a timeout.
+	TimeoutPhase string `json:"timeout_phase,omitempty"`
+
+	// HandshakeHost is the server address sent in a built handshake.
+	HandshakeHost string `json:"handshake_host,omitempty"`
+
+	// SRVHost and SRVPort are the server named by the target's SRV record,
+	// with --srv, which was scanned in place of the target.
+	SRVHost string `json:"srv_host,omitempty"`
+	SRVPort uint16 `json:"srv_port,omitempty"`
+
+	// SRVTargets are all the servers the SRV record names, with --srv-all,
+	// in the order a client would try them: the first is the one scanned
+	// above, and the status was fetched from each of the others.
+	SRVTargets []SRVTarget `json:"srv_targets,omitempty"`
+
+	// UnusualPort is set when a valid response came from Port, which is not
+	// one of --expected-ports.
+	UnusualPort bool `json:"unusual_port,omitempty"`
+	Port        uint `json:"port,omitempty"`
+
+	// CatchAll is set, with --catch-all, when the server answered a
+	// handshake addressed to the made-up CatchAllHost with the same bytes
+	// as one addressed to the target, as catch-all proxies and honeypots
+	// do. CatchAllHashes are the hex SHA-256 digests of the two status
+	// responses, the target's first.
+	CatchAll       bool     `json:"catch_all,omitempty"`
+	CatchAllHost   string   `json:"catch_all_host,omitempty"`
+	CatchAllHashes []string `json:"catch_all_hashes,omitempty"`
+
+	// MismatchVersionName is the version name the server gave, with
+	// --mismatch-probe, for a protocol version it cannot support.
+	// MismatchChanged is set if that differs from the name in the status,
+	// and MismatchVersion is the release version found in it, if any.
+	MismatchVersionName string `json:"mismatch_version_name,omitempty"`
+	MismatchChanged     bool   `json:"mismatch_changed,omitempty"`
+	MismatchVersion     string `json:"mismatch_version,omitempty"`
+
+	// AddressProbe is how the server took a handshake with a server address
+	// AddressProbeSize bytes long, with --address-probe: accepted, kicked,
+	// closed or timeout.
+	AddressProbe     string `json:"address_probe,omitempty"`
+	AddressProbeSize int    `json:"address_probe_size,omitempty"`
+
+	// ClusterID identifies, with --cluster, the cluster of servers in the
+	// scan that share this one's favicon, or its MOTD template, version and
+	// player limit, directly or through other servers. ClusterSize is the
+	// number of servers in the cluster when this result was emitted; a
+	// cluster merged into an older one takes on its ID from then on.
+	ClusterID   string `json:"cluster_id,omitempty"`
+	ClusterSize int    `json:"cluster_size,omitempty"`
+
+	// ForcedHosts maps each --enumerate-forced-hosts candidate whose status
+	// could be fetched to the fingerprint of the backend that answered,
+	// the hex SHA-256 of its version, player limit, MOTD and favicon.
+	// ForcedDefault is the fingerprint of the server a made-up name routes
+	// to, ForcedRouted the candidates routed to a different one, and
+	// ForcedBackends the number of distinct backends seen, the default
+	// included.
+	ForcedHosts    map[string]string `json:"forced_hosts,omitempty"`
+	ForcedDefault  string            `json:"forced_default,omitempty"`
+	ForcedRouted   []string          `json:"forced_routed,omitempty"`
+	ForcedBackends int               `json:"forced_backends,omitempty"`
+
+	// Honeypot is set when a response matched one of --trap-signatures;
+	// HoneypotSignature is the label of the signature that matched.
+	Honeypot          bool   `json:"honeypot,omitempty"`
+	HoneypotSignature string `json:"honeypot_signature,omitempty"`
+
+	// TrailingBytes counts what the server sent after the exchange was
+	// complete, with --record-trailing; capped at 64KiB.
+	TrailingBytes int `json:"trailing_bytes,omitempty"`
+
+	// Liveness is the port state (open, closed or filtered) in --liveness
+	// mode, where nothing else is recorded.
+	Liveness string `json:"liveness,omitempty"`
+
+	// ServiceGuess names the service the response looks like, such as http
+	// or ssh, when the server did not send a Minecraft status.
+	ServiceGuess string `json:"service_guess,omitempty"`
+
+	// Matched is set when the result satisfies --match and --pattern,
+	// whichever are given. PatternMatch is the text --pattern matched.
+	Matched      bool   `json:"matched,omitempty"`
+	PatternMatch string `json:"pattern_match,omitempty"`
+
+	// Skipped gives the reason the target was not scanned, if it was not.
+	Skipped string `json:"skipped,omitempty"`
+
+	// Truncated is set when the server closed mid-packet and --eof-policy
+	// is truncate; the exchange stops at the truncated packet.
+	Truncated bool `json:"truncated,omitempty"`
+
+	// SocketWarnings holds non-fatal socket-level problems, such as failing
+	// to apply a buffer size or a pending SO_ERROR on the socket.
+	SocketWarnings []string `json:"socket_warnings,omitempty"`
+
+	// responses are the raw responses read, for --pattern.
+	responses [][]byte
+
+	// head is the start of the reply to probe1, for ServiceGuess.
+	head []byte
+
+	// srvRecords are the SRV records looked up, for SRVTargets.
+	srvRecords []*net.SRV
+
+	// remote is the address the scan connected to, for --aggregate-prefix.
+	remote net.IP
+}
+
+// ReadCount is the length a packet declared versus the bytes received.
+type ReadCount struct {
+	Declared int `json:"declared"`
+	Read     int `json:"read"`
 }
 
 // RegisterModule is called by modules/mc.go to register the scanner.
 func RegisterModule() {
 	var m Module
-	_, err := zgrab2.AddCommand("mc", "MC", m.Description(), 80, &m)
+	cmd, err := zgrab2.AddCommand("mc", "MC", m.Description(), 80, &m)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if _, err := cmd.AddCommand("schema", "Print a JSON Schema of the results", "Print a JSON Schema of the results and exit, instead of scanning.", &schemaCommand{}); err != nil {
+		log.Fatal(err)
+	}
+	cmd.SubcommandsOptional = true
 }
 
 // NewFlags returns a new default flags object.
@@ -81,6 +459,81 @@ func (m *Module) NewScanner() zgrab2.Scanner {
 
 // Validate validates the flags and returns nil on success.
 func (f *Flags) Validate(args []string) error {
+	if f.ConnectTimeout < 0 {
+		return fmt.Errorf("--connect-timeout must not be negative")
+	}
+	if f.ReadTimeout <= 0 {
+		return fmt.Errorf("--read-timeout must be positive")
+	}
+	if f.PingCount < 1 {
+		return fmt.Errorf("--ping-count must be at least 1")
+	}
+	if f.MaxBannerSize <= 0 {
+		return fmt.Errorf("--max-banner-size must be positive")
+	}
+	if f.MaxFaviconSize < 0 {
+		return fmt.Errorf("--max-favicon-size must not be negative")
+	}
+	if f.ProbeFile != "" && (f.Probe1 != defaultProbe || f.Probe2 != defaultProbe) {
+		return fmt.Errorf("--probe-file cannot be combined with --probe1 or --probe2")
+	}
+	if f.ProbeSep != "" && f.ProbeFile == "" {
+		return fmt.Errorf("--probe-separator requires --probe-file")
+	}
+	if f.Pattern != "" {
+		if _, err := regexp.Compile(f.Pattern); err != nil {
+			return fmt.Errorf("invalid --pattern: %v", err)
+		}
+	}
+	if f.Decorative != "" {
+		if !f.CleanSample {
+			return fmt.Errorf("--decorative-sample requires --clean-sample")
+		}
+		if _, err := regexp.Compile(f.Decorative); err != nil {
+			return fmt.Errorf("invalid --decorative-sample: %v", err)
+		}
+	}
+	if f.ProbeFile == "" {
+		if _, err := unquoteProbe("--probe1", f.Probe1); err != nil {
+			return err
+		}
+		if _, err := unquoteProbe("--probe2", f.Probe2); err != nil {
+			return err
+		}
+	}
+	if (f.HandshakeHost != "" || f.FML) && !f.BuildHandshake && !f.LoginProbe {
+		return fmt.Errorf("--handshake-host and --fml require --build-handshake or --login-probe")
+	}
+	if f.Legacy && (f.BuildHandshake || f.ProbeFile != "") {
+		return fmt.Errorf("--legacy cannot be combined with --build-handshake or --probe-file")
+	}
+	if f.Bedrock && (f.Legacy || f.BuildHandshake || f.ProbeFile != "" || f.Liveness) {
+		return fmt.Errorf("--bedrock cannot be combined with --legacy, --build-handshake, --probe-file or --liveness")
+	}
+	if f.Query && (f.Bedrock || f.Legacy || f.BuildHandshake || f.ProbeFile != "" || f.Liveness) {
+		return fmt.Errorf("--query cannot be combined with --bedrock, --legacy, --build-handshake, --probe-file or --liveness")
+	}
+	if f.ProxyProtocol && (f.Bedrock || f.Query || f.Liveness) {
+		return fmt.Errorf("--proxy-protocol cannot be combined with --bedrock, --query or --liveness")
+	}
+	if f.NetworkPrefix < 0 || f.NetworkPrefix > 32 {
+		return fmt.Errorf("--aggregate-prefix must be in the range [0,32]")
+	}
+	if f.AddressProbe < 0 || f.AddressProbe > maxAddressProbe {
+		return fmt.Errorf("--address-probe must be in the range [0,%d]", maxAddressProbe)
+	}
+	if (f.CatchAll || f.MismatchProbe || f.AddressProbe > 0 || f.ForcedHosts != "") && (f.Bedrock || f.Query || f.Legacy || f.LoginProbe || f.Liveness) {
+		return fmt.Errorf("--catch-all, --mismatch-probe, --address-probe and --enumerate-forced-hosts cannot be combined with --bedrock, --query, --legacy, --login-probe or --liveness")
+	}
+	if f.SRVAll && (!f.SRV || f.Bedrock || f.Query || f.Legacy || f.LoginProbe || f.Liveness) {
+		return fmt.Errorf("--srv-all requires --srv, and cannot be combined with --bedrock, --query, --legacy, --login-probe or --liveness")
+	}
+	if f.ClientBrand != "" && (!f.LoginProbe || (f.ProtocolVer >= 0 && f.ProtocolVer < protocolConfigState)) {
+		return fmt.Errorf("--client-brand requires --login-probe and a --protocol-version of -1 or at least %d", protocolConfigState)
+	}
+	if f.LoginProbe && (f.Bedrock || f.Query || f.Legacy || f.ProbeFile != "" || f.Liveness) {
+		return fmt.Errorf("--login-probe cannot be combined with --bedrock, --query, --legacy, --probe-file or --liveness")
+	}
 	return nil
 }
 
@@ -91,140 +544,732 @@ func (m *Module) Description() string {
 
 // Help returns the module's help string.
 func (f *Flags) Help() string {
-	return ""
+	return protocolVersionHelp
 }
 
+// protocolVersionHelp lists common --protocol-version values. Servers that
+// check the version answer others with an "outdated client/server" MOTD.
+const protocolVersionHelp = `Common --protocol-version values:
+  -1   any version (status request only)
+  4    1.7.2 - 1.7.5
+  47   1.8 - 1.8.9
+  340  1.12.2
+  754  1.16.4 - 1.16.5
+  758  1.18.2
+  762  1.19.4
+  763  1.20 - 1.20.1
+  764  1.20.2
+  765  1.20.3 - 1.20.4
+  766  1.20.5 - 1.20.6
+  767  1.21 - 1.21.1`
+
 // Init initializes the Scanner with the command-line flags.
 func (s *Scanner) Init(flags zgrab2.ScanFlags) error {
 	f, _ := flags.(*Flags)
 	s.config = f
-	{
-		strProbe, err := strconv.Unquote(fmt.Sprintf(`"%s"`, s.config.Probe1))
+	excluded, err := newExcludedRanges(s.config.AllowPrivate, s.config.ExcludeRanges)
+	if err != nil {
+		return fmt.Errorf("invalid --exclude-ranges: %w", err)
+	}
+	s.excluded = excluded
+	resolver, err := newResolver(s.config.Resolver)
+	if err != nil {
+		return fmt.Errorf("invalid --resolver: %w", err)
+	}
+	s.resolver = resolver
+	ports := s.config.ExpectedPorts
+	if s.config.Bedrock && ports == defaultJavaPorts {
+		ports = defaultBedrockPorts
+	}
+	expected, err := parsePorts(ports)
+	if err != nil {
+		return fmt.Errorf("invalid --expected-ports: %w", err)
+	}
+	s.expected = expected
+	fields, err := parseOutputFields(s.config.OutputFields)
+	if err != nil {
+		return fmt.Errorf("invalid --output-fields: %w", err)
+	}
+	s.fields = fields
+	traps, err := loadTrapSignatures(s.config.TrapSignatures)
+	if err != nil {
+		return fmt.Errorf("invalid --trap-signatures: %w", err)
+	}
+	s.traps = traps
+	if s.config.Pattern != "" {
+		if s.pattern, err = regexp.Compile(s.config.Pattern); err != nil {
+			return fmt.Errorf("invalid --pattern: %w", err)
+		}
+	}
+	if s.defaultMOTDs, err = loadMOTDList(s.config.DefaultMOTDs, builtinDefaultMOTDs); err != nil {
+		return fmt.Errorf("invalid --default-motds: %w", err)
+	}
+	if s.startingKeywords, err = loadMOTDList(s.config.StartKeywords, builtinStartingKeywords); err != nil {
+		return fmt.Errorf("invalid --starting-keywords: %w", err)
+	}
+	if s.forcedHosts, err = loadHostList(s.config.ForcedHosts); err != nil {
+		return fmt.Errorf("invalid --enumerate-forced-hosts: %w", err)
+	}
+	if s.config.Cluster {
+		s.clusters = newClusterSet()
+	}
+	if s.config.NetworkPrefix > 0 {
+		s.networks = newNetworkSet(s.config.NetworkPrefix)
+	}
+	if s.config.Decorative != "" {
+		if s.decorative, err = regexp.Compile(s.config.Decorative); err != nil {
+			return fmt.Errorf("invalid --decorative-sample: %w", err)
+		}
+	}
+	if s.config.Match != "" {
+		if s.matcher, err = compileMatcher(s.config.Match); err != nil {
+			return fmt.Errorf("invalid --match: %w", err)
+		}
+	} else if s.config.MatchOnly && s.pattern == nil {
+		return errors.New("--match-only requires --match or --pattern")
+	}
+	if s.config.ProbeFile != "" {
+		if s.probe1, s.probe2, err = readProbeFile(s.config.ProbeFile, s.config.ProbeSep); err != nil {
+			return fmt.Errorf("invalid --probe-file: %w", err)
+		}
+		return nil
+	}
+	if s.probe1, err = unquoteProbe("--probe1", s.config.Probe1); err != nil {
+		return err
+	}
+	if s.probe2, err = unquoteProbe("--probe2", s.config.Probe2); err != nil {
+		return err
+	}
+	return nil
+}
+
+// unquoteProbe interprets the escapes in the --probe1 or --probe2 value.
+func unquoteProbe(name, value string) ([]byte, error) {
+	probe, err := strconv.Unquote(fmt.Sprintf(`"%s"`, value))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return []byte(probe), nil
+}
+
+// readVarInt is varint.Read without the byte count, which no caller here
+// needs.
+func readVarInt(r io.Reader) (int, error) {
+	v, _, err := varint.Read(r)
+	return v, err
+}
+
+// encodeBanner encodes a raw packet for Banner1 or Banner2 as --encoding
+// says.
+func (s *Scanner) encodeBanner(b []byte) string {
+	if s.config.Encoding == encodingBase64 {
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	return hex.EncodeToString(b)
+}
+
+// decodeText reads b as UTF-8, replacing invalid sequences with U+FFFD.
+func decodeText(b []byte) string {
+	return strings.ToValidUTF8(string(b), "\uFFFD")
+}
+
+// isExcluded reports whether ip must not be scanned.
+func (s *Scanner) isExcluded(ip net.IP) bool {
+	return ip != nil && s.excluded != nil && s.excluded.Contains(ip)
+}
+
+// readPacket reads the body of a packet whose length has already been read,
+// stopping early if the server closes the connection. The read is bounded by
+// the deadline of the reader's phase. The returned ReadCount records the
+// declared length against the number of bytes actually read.
+func readPacket(r io.Reader, length int) ([]byte, *ReadCount, error) {
+	data := make([]byte, length)
+	totalRead := 0
+	for totalRead < length {
+		n, err := r.Read(data[totalRead:])
+		totalRead += n
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			panic("Probe error")
+			if zgrab2.IsTimeoutError(err) {
+				err = zgrab2.NewScanError(zgrab2.SCAN_IO_TIMEOUT, errors.New("read timeout"))
+			}
+			return data[:totalRead], &ReadCount{Declared: length, Read: totalRead}, err
 		}
-		s.probe1 = []byte(strProbe)
 	}
-	{
-		strProbe2, err := strconv.Unquote(fmt.Sprintf(`"%s"`, s.config.Probe2))
+	return data[:totalRead], &ReadCount{Declared: length, Read: totalRead}, nil
+}
+
+// checkEOF applies the configured --eof-policy to a packet read. It returns
+// done=true if the scan should stop here, along with the error (if any) to
+// report.
+func (s *Scanner) checkEOF(count *ReadCount, results *Results) (done bool, err error) {
+	if count.Read >= count.Declared {
+		return false, nil
+	}
+	switch s.config.EOFPolicy {
+	case eofPolicyError:
+		return true, zgrab2.NewScanError(zgrab2.SCAN_CONNECTION_CLOSED, fmt.Errorf("connection closed after %d of %d bytes", count.Read, count.Declared))
+	case eofPolicyTruncate:
+		results.Truncated = true
+		return true, nil
+	case eofPolicySuccess:
+		return false, nil
+	}
+	return false, nil
+}
+
+// parsePorts parses a comma-separated list of ports.
+func parsePorts(list string) (map[uint]bool, error) {
+	ports := make(map[uint]bool)
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		port, err := strconv.ParseUint(field, 10, 16)
 		if err != nil {
-			panic("Probe error")
+			return nil, fmt.Errorf("bad port %q", field)
 		}
-		s.probe2 = []byte(strProbe2)
+		ports[uint(port)] = true
 	}
-	return nil
+	return ports, nil
+}
+
+func (s *Scanner) port(target *zgrab2.ScanTarget) uint {
+	if target.Port != nil {
+		return *target.Port
+	}
+	return s.config.Port
+}
+
+// succeed flags the result if it came from an unexpected port.
+func (s *Scanner) succeed(target *zgrab2.ScanTarget, results *Results) (zgrab2.ScanStatus, interface{}, error) {
+	port := s.port(target)
+	if results.SRVPort != 0 {
+		port = uint(results.SRVPort)
+	}
+	if !s.expected[port] {
+		results.UnusualPort = true
+		results.Port = port
+	}
+	return zgrab2.SCAN_SUCCESS, results, nil
+}
+
+// handshakeAddr returns the server address and port to send in a built
+// handshake, and records the address in results.
+func (s *Scanner) handshakeAddr(target *zgrab2.ScanTarget, results *Results) (string, uint16) {
+	host, port := target.Domain, uint16(s.port(target))
+	if host == "" {
+		host = target.IP.String()
+	}
+	// Clients address the handshake to the server the SRV record names.
+	if results.SRVHost != "" {
+		host, port = results.SRVHost, results.SRVPort
+	}
+	if s.config.HandshakeHost != "" {
+		host = s.config.HandshakeHost
+	}
+	if s.config.FML {
+		host += fmlMarker(s.config.ProtocolVer)
+	}
+	results.HandshakeHost = host
+	return host, port
+}
+
+// Results.SizeLimitExceeded values.
+const (
+	sizeLimitBanner  = "banner"
+	sizeLimitFavicon = "favicon"
+)
+
+// statusLimit is the longest status response accepted: --max-banner-size,
+// plus --max-favicon-size for the favicon.
+func (s *Scanner) statusLimit() int {
+	return s.config.MaxBannerSize + s.config.MaxFaviconSize
+}
+
+// responseTimeout is how long the UDP scans wait for each datagram: the
+// --read-timeout, or --timeout if that is unset.
+func (s *Scanner) responseTimeout() time.Duration {
+	if s.config.ReadTimeout > 0 {
+		return s.config.ReadTimeout
+	}
+	return s.config.Timeout
+}
+
+// checkSizeLimits holds the status packet and its document to
+// --max-favicon-size and --max-banner-size separately, and names the limit
+// broken, if either is. Without --max-favicon-size, reading the packet has
+// already enforced --max-banner-size on the whole.
+func (s *Scanner) checkSizeLimits(packet, doc []byte) string {
+	if s.config.MaxFaviconSize == 0 || doc == nil {
+		return ""
+	}
+	favicon := faviconLength(doc)
+	if len(packet)-favicon > s.config.MaxBannerSize {
+		return sizeLimitBanner
+	}
+	if favicon > s.config.MaxFaviconSize {
+		return sizeLimitFavicon
+	}
+	return ""
+}
+
+// dial opens the TCP connection to the target. Unlike target.Open, the
+// connect phase is bounded by --connect-timeout rather than the session
+// timeout, so hosts that never answer the SYN can be abandoned quickly.
+// With --srv, the server named by the target's SRV record is dialled
+// instead, and recorded in results; one already recorded there is dialled
+// without a fresh lookup. Domains are resolved before dialling, so that an
+// excluded address is never contacted.
+func (s *Scanner) dial(target *zgrab2.ScanTarget, results *Results) (net.Conn, error) {
+	port := s.port(target)
+	host := target.Host()
+	domain := target.Domain
+	started := time.Now()
+	looked := false
+	if s.config.SRV && domain != "" && results.SRVHost == "" {
+		looked = true
+		if records := s.lookupSRV(domain); len(records) > 0 {
+			results.SRVHost, results.SRVPort = records[0].Target, records[0].Port
+			if s.config.SRVAll {
+				results.srvRecords = records
+			}
+		}
+	}
+	if results.SRVHost != "" {
+		domain, port = results.SRVHost, uint(results.SRVPort)
+	}
+	if target.IP == nil || results.SRVHost != "" {
+		ip, err := s.resolve(domain)
+		if err != nil {
+			return nil, err
+		}
+		host = ip.String()
+		looked = true
+	}
+	if looked {
+		s.recordResolve(results, started)
+	}
+	address := net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10))
+	timeout := s.config.Timeout
+	return zgrab2.DialTimeoutConnectionEx("tcp", address, s.config.ConnectTimeout, timeout, timeout, timeout, s.config.BytesReadLimit)
+}
+
+// lookupTimeout bounds DNS lookups, which count towards the connect phase.
+func (s *Scanner) lookupTimeout() time.Duration {
+	if s.config.ConnectTimeout == 0 {
+		return s.config.Timeout
+	}
+	return s.config.ConnectTimeout
+}
+
+// resolve looks domain up with --resolver if given, within the connect
+// timeout, and returns the first address that is not excluded. If every
+// address is excluded it returns ErrExcluded.
+func (s *Scanner) resolve(domain string) (net.IP, error) {
+	resolver := s.resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.lookupTimeout())
+	defer cancel()
+	addrs, err := resolver.LookupIPAddr(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if !s.isExcluded(addr.IP) {
+			return addr.IP, nil
+		}
+	}
+	return nil, ErrExcluded
 }
 
-func readVarInt(conn net.Conn) (int, error) {
-	var result int
-	var shift uint
-	const maxBytes = 5
-	for i := 0; i < maxBytes; i++ {
-		var b [1]byte
-		_, err := conn.Read(b[:])
+// openUDP opens the UDP socket for --bedrock and --query. As in dial, a
+// domain is resolved with --resolver first, so that an excluded address is
+// never sent to.
+func (s *Scanner) openUDP(target *zgrab2.ScanTarget, results *Results) (net.Conn, error) {
+	if target.IP == nil {
+		started := time.Now()
+		ip, err := s.resolve(target.Domain)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
-		result |= int(b[0]&0x7F) << shift
-		if b[0]&0x80 == 0 {
-			return result, nil
+		s.recordResolve(results, started)
+		resolved := *target
+		resolved.IP = ip
+		target = &resolved
+	}
+	conn, err := target.OpenUDP(&s.config.BaseFlags, &s.config.UDPFlags)
+	if err != nil {
+		return nil, err
+	}
+	if addr, ok := conn.RemoteAddr().(*net.UDPAddr); ok {
+		if s.isExcluded(addr.IP) {
+			conn.Close()
+			return nil, ErrExcluded
 		}
-		shift += 7
+		results.remote = addr.IP
 	}
-	return 0, fmt.Errorf("varint too long")
+	return conn, nil
 }
 
+// recordResolve records in results, with --conn-metadata, the time since
+// started, when the lookups for the target began.
+func (s *Scanner) recordResolve(results *Results, started time.Time) {
+	if s.config.ConnMetadata {
+		results.ResolveMS = float64(time.Since(started)) / float64(time.Millisecond)
+	}
+}
+
+// Phases of the scan recorded in Results.TimeoutPhase.
+const (
+	phaseConnect = "connect"
+	phaseWrite   = "write"
+	phaseRead    = "read"
+)
+
+// matchPattern looks for --pattern in each response in turn, returning the
+// first match.
+func (s *Scanner) matchPattern(responses [][]byte) (string, bool) {
+	for _, response := range responses {
+		if loc := s.pattern.FindIndex(response); loc != nil {
+			return decodeText(response[loc[0]:loc[1]]), true
+		}
+	}
+	return "", false
+}
+
+// Scan probes the target, then applies --pattern and --match and trims the
+// results to --output-fields if given.
 func (s *Scanner) Scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
+	status, res, err := s.scan(target)
+	results, ok := res.(*Results)
+	if !ok {
+		return status, res, err
+	}
+	if results.Banner1 != "" || results.Banner2 != "" {
+		results.Encoding = s.config.Encoding
+		if results.Encoding == "" {
+			results.Encoding = encodingHex
+		}
+	}
+	if results.Status == nil && len(results.head) > 0 {
+		results.ServiceGuess = guessService(results.head)
+	}
+	results.ProtocolVersion = reportedProtocol(results)
+	if results.Status != nil || results.Legacy != nil || results.Bedrock != nil || results.Query != nil {
+		results.DefaultMOTDMatch, results.DefaultMOTD = matchDefaultMOTD(results.MOTDClean, s.defaultMOTDs)
+	}
+	if results.Status != nil {
+		results.StartingKeyword, results.Starting = matchStarting(results.Status, results.MOTDClean, s.startingKeywords)
+	}
+	if s.config.MOTDHosts {
+		results.MOTDHosts = motdHosts(results.MOTDClean)
+	}
+	if s.config.CleanSample && results.Status != nil {
+		results.RealPlayers = realPlayers(results.Status.Players.Sample, s.decorative)
+	}
+	if status == zgrab2.SCAN_SUCCESS && results.Status != nil {
+		if s.clusters != nil {
+			s.cluster(results)
+		}
+		if s.config.CatchAll {
+			s.probeCatchAll(&target, results)
+		}
+		if s.config.MismatchProbe {
+			s.probeMismatch(&target, results)
+		}
+		if s.config.AddressProbe > 0 {
+			s.probeAddress(&target, results)
+		}
+		if s.config.ForcedHosts != "" {
+			s.probeForcedHosts(&target, results)
+		}
+	}
+	if len(results.srvRecords) > 0 {
+		s.probeSRVTargets(&target, status, results)
+	}
+	if s.networks != nil && status == zgrab2.SCAN_SUCCESS {
+		s.aggregate(results.remote, results)
+	}
+	patternMatched := s.pattern == nil
+	if s.pattern != nil && status == zgrab2.SCAN_SUCCESS {
+		results.PatternMatch, patternMatched = s.matchPattern(results.responses)
+		if !patternMatched {
+			status = zgrab2.ScanStatus(s.config.PatternMiss)
+			if status != zgrab2.SCAN_SUCCESS {
+				err = errNoPatternMatch
+			}
+		}
+	}
+	if s.pattern != nil || s.matcher != nil {
+		results.Matched = patternMatched && (s.matcher == nil || s.matcher.match(results))
+		if !results.Matched && s.config.MatchOnly {
+			return zgrab2.SCAN_SKIPPED, &Results{Skipped: skippedUnmatched}, errUnmatched
+		}
+	}
+	return status, filterResults(results, s.fields), err
+}
+
+func (s *Scanner) scan(target zgrab2.ScanTarget) (zgrab2.ScanStatus, interface{}, error) {
 	var (
 		conn    net.Conn
 		err     error
 		readErr error
 	)
 
-	conn, err = target.Open(&s.config.BaseFlags)
+	if s.isExcluded(target.IP) {
+		return zgrab2.SCAN_SKIPPED, &Results{Skipped: skippedExcluded}, ErrExcluded
+	}
+
+	if s.config.Bedrock {
+		return s.scanBedrock(&target)
+	}
+	if s.config.Query {
+		return s.scanQuery(&target)
+	}
+
+	results := new(Results)
+	fail := func(phase string, err error) (zgrab2.ScanStatus, interface{}, error) {
+		if zgrab2.IsTimeoutError(err) {
+			results.TimeoutPhase = phase
+		}
+		return zgrab2.TryGetScanStatus(err), results, err
+	}
+
+	conn, err = s.dial(&target, results)
+	if errors.Is(err, ErrExcluded) {
+		return zgrab2.SCAN_SKIPPED, &Results{Skipped: skippedExcluded}, ErrExcluded
+	}
 	if err != nil {
+		if s.config.Liveness {
+			return livenessFailure(err)
+		}
+		if zgrab2.IsTimeoutError(err) {
+			return zgrab2.TryGetScanStatus(err), &Results{TimeoutPhase: phaseConnect}, err
+		}
 		return zgrab2.TryGetScanStatus(err), nil, err
 	}
 	defer conn.Close()
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		results.remote = addr.IP
+	}
+
+	if s.config.Liveness {
+		return zgrab2.SCAN_SUCCESS, &Results{Liveness: livenessOpen}, nil
+	}
+
+	results.SocketWarnings = applySocketBuffers(conn, s.config.SoRcvBuf, s.config.SoSndBuf)
+	defer results.recordSocketError(conn)
+
+	if s.config.ProxyProtocol {
+		header, err := buildProxyHeader(conn.LocalAddr(), conn.RemoteAddr())
+		if err != nil {
+			return zgrab2.SCAN_UNKNOWN_ERROR, results, err
+		}
+		if _, err := conn.Write(header); err != nil {
+			return fail(phaseWrite, err)
+		}
+	}
+
+	if s.config.LoginProbe {
+		return s.scanLogin(conn, &target, results)
+	}
 
-	_, err = conn.Write(s.probe1)
+	probe1 := s.probe1
+	if s.config.Legacy {
+		probe1 = legacyPing
+	} else if s.config.BuildHandshake {
+		host, port := s.handshakeAddr(&target, results)
+		probe1 = buildHandshake(host, port, s.config.ProtocolVer)
+	}
+	if protoVer, ok := handshakeProtocol(probe1); ok {
+		results.RequestedProtocol = &protoVer
+	}
+	_, err = conn.Write(probe1)
 	if err != nil {
-		return zgrab2.TryGetScanStatus(err), nil, err
+		return fail(phaseWrite, err)
+	}
+
+	// Reads go through a buffered reader so that the start of the response
+	// can be peeked at to detect legacy servers. Each read phase, up to the
+	// whole response being read, is bounded by --read-timeout.
+	phase := &deadlineReader{conn: conn}
+	reader := bufio.NewReader(phase)
+	phase.startPhase(s.config.ReadTimeout)
+	if s.config.Legacy {
+		head, err := reader.Peek(1)
+		if err != nil {
+			return fail(phaseRead, err)
+		}
+		if head[0] != legacyKickID {
+			return zgrab2.SCAN_PROTOCOL_ERROR, results, errors.New("legacy ping not answered with a kick packet")
+		}
+	}
+	if s.config.Legacy || isLegacyKick(reader) {
+		results.LegacyKickMessage, err = readLegacyKick(reader)
+		if err != nil {
+			return fail(phaseRead, err)
+		}
+		// Modern servers still answer the legacy ping, so only a kick in
+		// reply to the modern handshake marks the server as legacy-only.
+		results.LegacyOnly = !s.config.Legacy
+		if results.Legacy = parseLegacyStatus(results.LegacyKickMessage); results.Legacy != nil {
+			results.MOTD = results.Legacy.MOTD
+			results.MOTDClean = stripFormatting(results.MOTD)
+		}
+		results.responses = append(results.responses, []byte(results.LegacyKickMessage))
+		s.matchTrap([]byte(results.LegacyKickMessage), results)
+		if s.config.RecordTrailing {
+			results.TrailingBytes = countTrailing(phase, reader)
+		}
+		return s.succeed(&target, results)
 	}
+	// Whatever has arrived so far, kept to guess at the service if this
+	// does not turn out to be a status response.
+	head, _ := reader.Peek(reader.Buffered())
+	results.head = append([]byte(nil), head...)
 
 	var length int
-	length, readErr = readVarInt(conn)
+	length, readErr = readVarInt(reader)
 	if readErr != nil {
-		return zgrab2.TryGetScanStatus(readErr), nil, readErr
+		return fail(phaseRead, readErr)
 	}
 
-	if length > 32800 {
-		return zgrab2.SCAN_PROTOCOL_ERROR, nil, errors.New("banner too long")
+	if length > s.statusLimit() {
+		return zgrab2.SCAN_PROTOCOL_ERROR, results, errors.New("banner too long")
 	}
 	if length < 1 {
-		return zgrab2.SCAN_PROTOCOL_ERROR, nil, errors.New("zero/negative banner length")
+		return zgrab2.SCAN_PROTOCOL_ERROR, results, errors.New("zero/negative banner length")
 	}
 
-	data := make([]byte, length)
-	totalRead := 0
-	timeout := time.After(5 * time.Second)
-
-readLoop:
-	for totalRead < length {
-		select {
-		case <-timeout:
-			return zgrab2.SCAN_PROTOCOL_ERROR, nil, errors.New("read timeout")
-		default:
-			n, err := conn.Read(data[totalRead:])
-			if err != nil && err != io.EOF {
-				return zgrab2.TryGetScanStatus(err), nil, err
-			}
-			totalRead += n
-			if err == io.EOF {
-				break readLoop
-			}
+	data, count, err := readPacket(reader, length)
+	if err == nil && isSetCompression(data) {
+		// Some servers turn on compression before the status response; the
+		// rest of the exchange uses compressed framing. A negative threshold
+		// leaves compression off.
+		threshold, terr := readVarInt(bytes.NewReader(data[1:]))
+		if terr != nil {
+			return zgrab2.SCAN_PROTOCOL_ERROR, results, compressionError("bad compression threshold")
+		}
+		results.CompressionThreshold = threshold
+		if threshold >= 0 {
+			results.StatusCompression = true
+			data, count, err = readCompressedPacket(reader, s.statusLimit())
+		} else {
+			data, count, err = readFramedPacket(reader, s.statusLimit())
 		}
 	}
-
-	_, err = conn.Write(s.probe2)
+	if s.config.RawBanner {
+		results.Banner1 = s.encodeBanner(data)
+	}
+	if s.config.Decode {
+		results.Banner1Text = decodeText(data)
+	}
+	results.Banner1Bytes = count
+	results.responses = append(results.responses, data)
+	doc := statusJSON(data)
+	if results.SizeLimitExceeded = s.checkSizeLimits(data, doc); results.SizeLimitExceeded == sizeLimitBanner {
+		return zgrab2.SCAN_PROTOCOL_ERROR, results, errors.New("banner too long")
+	}
+	results.Status = decodeStatus(doc)
+	if results.Status != nil {
+		results.MOTD = flattenChat(results.Status.Description)
+		results.MOTDClean = stripFormatting(results.MOTD)
+		if s.config.CaptureExtra {
+			results.TranslateKeys = translateKeys(results.Status.Description)
+		}
+		if results.SizeLimitExceeded == "" {
+			results.Favicon = decodeFavicon(doc)
+		}
+		if forge := decodeForge(doc); forge != nil {
+			results.Mods = forge.mods
+			results.ForgeChannels = forge.channels
+			results.ModsTruncated = forge.truncated
+		}
+		results.Modpack = decodeModpack(doc)
+	}
+	s.matchTrap(data, results)
+	if s.config.FrameDetail {
+		results.Framing = decodeFraming(data)
+	}
 	if err != nil {
-		return zgrab2.TryGetScanStatus(err), nil, err
+		return fail(phaseRead, err)
 	}
-
-	length, readErr = readVarInt(conn)
-	if readErr != nil {
-		return zgrab2.TryGetScanStatus(readErr), nil, readErr
+	if done, err := s.checkEOF(count, results); done {
+		return zgrab2.TryGetScanStatus(err), results, err
 	}
 
-	if length != 9 {
-		return zgrab2.SCAN_PROTOCOL_ERROR, nil, errors.New("banner length mismatch")
+	// A --probe-file without a separator has no ping to send.
+	if len(s.probe2) == 0 {
+		if s.config.RecordTrailing {
+			results.TrailingBytes = countTrailing(phase, reader)
+		}
+		return s.succeed(&target, results)
 	}
 
-	data2 := make([]byte, length)
-	totalRead = 0
-	timeout = time.After(5 * time.Second)
+	probe2 := s.probe2
+	if s.config.BuildHandshake && s.config.Probe2 == defaultProbe {
+		probe2 = buildPing(time.Now().UnixNano())
+	}
+	sentPing := probe2
+	if results.StatusCompression {
+		probe2 = compressedFrame(probe2)
+	}
+	pingSent := time.Now()
+	_, err = conn.Write(probe2)
+	if err != nil {
+		return fail(phaseWrite, err)
+	}
+	phase.startPhase(s.config.ReadTimeout)
 
-readLoop2:
-	for totalRead < length {
-		select {
-		case <-timeout:
-			return zgrab2.SCAN_PROTOCOL_ERROR, nil, errors.New("read timeout")
-		default:
-			n, err := conn.Read(data2[totalRead:])
-			if err != nil && err != io.EOF {
-				return zgrab2.TryGetScanStatus(err), nil, err
-			}
-			totalRead += n
-			if err == io.EOF {
-				break readLoop2
-			}
+	// The reply is read as whatever packet the server sends; for a ping
+	// that is a 9-byte pong, but --probe2 may be any request.
+	var data2 []byte
+	if results.StatusCompression {
+		data2, count, err = readCompressedPacket(reader, s.config.MaxBannerSize)
+	} else {
+		length, readErr = readVarInt(reader)
+		if readErr != nil {
+			return fail(phaseRead, readErr)
+		}
+		if length > s.config.MaxBannerSize {
+			return zgrab2.SCAN_PROTOCOL_ERROR, results, errors.New("banner too long")
+		}
+		if length < 1 {
+			return zgrab2.SCAN_PROTOCOL_ERROR, results, errors.New("zero/negative banner length")
 		}
+		data2, count, err = readPacket(reader, length)
+	}
+	results.Banner2 = s.encodeBanner(data2)
+	if s.config.Decode {
+		results.Banner2Text = decodeText(data2)
+	}
+	results.Banner2Bytes = count
+	results.responses = append(results.responses, data2)
+	if payload, ok := pongPayload(data2); ok && err == nil {
+		results.LatencyMS = float64(time.Since(pingSent)) / float64(time.Millisecond)
+		results.PongPayload = payload
+		sent, sentOK := pingPayload(sentPing)
+		results.PongEchoed = sentOK && sent == payload
+	}
+	if err != nil {
+		return fail(phaseRead, err)
+	}
+	if done, err := s.checkEOF(count, results); done {
+		return zgrab2.TryGetScanStatus(err), results, err
+	}
+	if s.config.PingCount > 1 && results.LatencyMS > 0 {
+		samples := append([]float64{results.LatencyMS}, s.pingRounds(conn, phase, reader, results.StatusCompression, s.config.PingCount-1)...)
+		results.LatencyStats = newLatencyStats(samples)
 	}
 
-	var results Results
-
-	results.Banner1 = hex.EncodeToString(data)
-	results.Banner2 = hex.EncodeToString(data2)
-
-	return zgrab2.SCAN_SUCCESS, &results, nil
+	if s.config.RecordTrailing {
+		results.TrailingBytes = countTrailing(phase, reader)
+	}
+	return s.succeed(&target, results)
 }
@@ -0,0 +1,686 @@
+package mc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/zmap/zgrab2"
+	"github.com/zmap/zgrab2/lib/varint"
+)
+
+func framePacket(id int, payload []byte) []byte {
+	body := append(varint.Append(nil, id), payload...)
+	return append(varint.Append(nil, len(body)), body...)
+}
+
+func testHandshake(protocol int) []byte {
+	host := "localhost"
+	payload := varint.Append(nil, protocol)
+	payload = varint.Append(payload, len(host))
+	payload = append(payload, host...)
+	payload = append(payload, 0x63, 0xdd) // port 25565
+	payload = varint.Append(payload, 1)   // next state: status
+	return append(framePacket(0x00, payload), framePacket(0x00, nil)...)
+}
+
+var testPing = framePacket(0x01, []byte{0, 0, 0, 0, 0, 0, 0x30, 0x39})
+
+// readTestPacket reads one length-prefixed packet from conn.
+func readTestPacket(conn net.Conn) ([]byte, error) {
+	length, err := readVarInt(conn)
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(conn, body)
+	return body, err
+}
+
+// serveStatus plays the server side of one Server List Ping exchange on a
+// local listener, answering the status request with status.
+func serveStatus(t *testing.T, status []byte) zgrab2.ScanTarget {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		// handshake, then status request
+		for i := 0; i < 2; i++ {
+			if _, err := readTestPacket(conn); err != nil {
+				return
+			}
+		}
+		payload := varint.Append(nil, len(status))
+		payload = append(payload, status...)
+		conn.Write(framePacket(0x00, payload))
+		ping, err := readTestPacket(conn)
+		if err != nil {
+			return
+		}
+		// the pong echoes the ping payload back under the same packet ID
+		conn.Write(append(varint.Append(nil, len(ping)), ping...))
+	}()
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	return zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+}
+
+func newTestScanner(probe1 []byte) *Scanner {
+	return &Scanner{
+		config: &Flags{BaseFlags: zgrab2.BaseFlags{Timeout: 5 * time.Second}, ReadTimeout: 5 * time.Second, MaxBannerSize: 32800, PingCount: 1},
+		probe1: probe1,
+		probe2: testPing,
+
+		defaultMOTDs:     builtinDefaultMOTDs,
+		startingKeywords: builtinStartingKeywords,
+	}
+}
+
+// Servers from 1.20.2 onwards add a configuration state between login and
+// play, but the status state is untouched: the handshake, status response
+// and ping/pong framing are the same as in earlier versions.
+func TestScanLatestProtocol(t *testing.T) {
+	tests := map[string]struct {
+		fixture    string
+		protocol   int
+		maxPlayers int
+	}{
+		"1.20.2": {fixture: "testdata/status-1.20.2.json", protocol: 764, maxPlayers: 20},
+		"1.20.4": {fixture: "testdata/status-1.20.4.json", protocol: 765, maxPlayers: 100},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			status, err := os.ReadFile(test.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			status = bytes.TrimSpace(status)
+			target := serveStatus(t, status)
+
+			scanner := newTestScanner(testHandshake(test.protocol))
+			scanner.config.RawBanner = true
+			scanner.config.Decode = true
+			scanStatus, res, err := scanner.Scan(target)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if scanStatus != zgrab2.SCAN_SUCCESS {
+				t.Fatalf("received unexpected status: %s, wanted: %s", scanStatus, zgrab2.SCAN_SUCCESS)
+			}
+			results := res.(*Results)
+			if results.Status == nil {
+				t.Fatalf("status response was not parsed")
+			}
+			if results.Status.Version.Protocol != test.protocol {
+				t.Errorf("received unexpected protocol: %d, wanted: %d", results.Status.Version.Protocol, test.protocol)
+			}
+			if results.Status.Players.Max != test.maxPlayers {
+				t.Errorf("received unexpected players.max: %d, wanted: %d", results.Status.Players.Max, test.maxPlayers)
+			}
+
+			banner, err := hex.DecodeString(results.Banner1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append([]byte{0x00}, varint.Append(nil, len(status))...)
+			want = append(want, status...)
+			if !bytes.Equal(banner, want) {
+				t.Errorf("received unexpected banner1: %q, wanted: %q", banner, want)
+			}
+			if !strings.HasSuffix(results.Banner1Text, string(status)) {
+				t.Errorf("received unexpected banner1_text: %q", results.Banner1Text)
+			}
+			if want := hex.EncodeToString(testPing[1:]); results.Banner2 != want {
+				t.Errorf("received unexpected banner2: %s, wanted: %s", results.Banner2, want)
+			}
+			if results.PongPayload != 12345 || !results.PongEchoed {
+				t.Errorf("received unexpected pong payload: %d (echoed=%v), wanted: 12345", results.PongPayload, results.PongEchoed)
+			}
+			if results.LatencyMS <= 0 {
+				t.Errorf("received unexpected latency: %v", results.LatencyMS)
+			}
+		})
+	}
+}
+
+func TestScanUnusualPort(t *testing.T) {
+	status := []byte(`{"version":{"name":"1.20.4","protocol":765}}`)
+	for _, expected := range []bool{true, false} {
+		target := serveStatus(t, status)
+		scanner := newTestScanner(testHandshake(765))
+		scanner.expected = map[uint]bool{25565: true}
+		if expected {
+			scanner.expected[*target.Port] = true
+		}
+		_, res, err := scanner.Scan(target)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		results := res.(*Results)
+		if results.UnusualPort == expected {
+			t.Errorf("port expected=%v: got unusual_port=%v", expected, results.UnusualPort)
+		}
+		if !expected && results.Port != *target.Port {
+			t.Errorf("got port %d, wanted %d", results.Port, *target.Port)
+		}
+	}
+}
+
+func TestScanEOFPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy        string
+		status        zgrab2.ScanStatus
+		wantErr       bool
+		wantTruncated bool
+	}{
+		"error":    {policy: eofPolicyError, status: zgrab2.SCAN_CONNECTION_CLOSED, wantErr: true},
+		"truncate": {policy: eofPolicyTruncate, status: zgrab2.SCAN_SUCCESS, wantTruncated: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				// declare 100 bytes, send 3, then hang up
+				conn.Write([]byte{100, 0x00, 0x01, 'x'})
+				conn.Close()
+			}()
+			port := uint(listener.Addr().(*net.TCPAddr).Port)
+			target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+
+			scanner := newTestScanner(testHandshake(765))
+			scanner.config.EOFPolicy = test.policy
+			status, res, err := scanner.Scan(target)
+			if status != test.status {
+				t.Errorf("received unexpected status: %s, wanted: %s", status, test.status)
+			}
+			if (err != nil) != test.wantErr {
+				t.Errorf("received unexpected error: %v", err)
+			}
+			results := res.(*Results)
+			if results.Truncated != test.wantTruncated {
+				t.Errorf("received unexpected truncated flag: %v", results.Truncated)
+			}
+			if want := (ReadCount{Declared: 100, Read: 3}); results.Banner1Bytes == nil || *results.Banner1Bytes != want {
+				t.Errorf("received unexpected banner1 byte count: %+v, wanted: %+v", results.Banner1Bytes, want)
+			}
+		})
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	if got, want := decodeText([]byte("\x00\xffmotd \xc2\xa7a")), "\x00\uFFFDmotd §a"; got != want {
+		t.Errorf("decodeText() = %q, wanted %q", got, want)
+	}
+}
+
+func TestVarIntRoundTrip(t *testing.T) {
+	for _, v := range []int{0, 1, 127, 128, 25565, 2097151, 2147483647, -1, -2147483648} {
+		got, err := readVarInt(bytes.NewReader(varint.Append(nil, v)))
+		if err != nil || got != v {
+			t.Errorf("%d: got %d, %v", v, got, err)
+		}
+	}
+}
+
+func TestScanPattern(t *testing.T) {
+	status := []byte(`{"version":{"name":"Paper 1.20.4","protocol":765},"description":"hi"}`)
+	tests := map[string]struct {
+		pattern    string
+		miss       string
+		wantStatus zgrab2.ScanStatus
+		wantMatch  string
+	}{
+		"status match": {pattern: `Paper [0-9.]+`, miss: "protocol-error", wantStatus: zgrab2.SCAN_SUCCESS, wantMatch: "Paper 1.20.4"},
+		"pong match":   {pattern: `\x30\x39$`, miss: "protocol-error", wantStatus: zgrab2.SCAN_SUCCESS, wantMatch: "09"},
+		"miss":         {pattern: `Velocity`, miss: "protocol-error", wantStatus: zgrab2.SCAN_PROTOCOL_ERROR},
+		"miss success": {pattern: `Velocity`, miss: "success", wantStatus: zgrab2.SCAN_SUCCESS},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			scanner := newTestScanner(testHandshake(765))
+			scanner.config.PatternMiss = test.miss
+			scanner.pattern = regexp.MustCompile(test.pattern)
+			scanStatus, res, _ := scanner.Scan(serveStatus(t, status))
+			if scanStatus != test.wantStatus {
+				t.Fatalf("got status %s, wanted %s", scanStatus, test.wantStatus)
+			}
+			results := res.(*Results)
+			if results.Matched != (test.wantMatch != "") || results.PatternMatch != test.wantMatch {
+				t.Errorf("got matched=%v pattern_match=%q, wanted %q", results.Matched, results.PatternMatch, test.wantMatch)
+			}
+		})
+	}
+}
+
+func TestScanArbitraryProbe2(t *testing.T) {
+	scanner := newTestScanner(testHandshake(765))
+	scanner.probe2 = framePacket(0x05, []byte("not a ping"))
+	status, res, err := scanner.Scan(serveStatus(t, []byte(`{"description":"x"}`)))
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("received unexpected status: %s (%v)", status, err)
+	}
+	results := res.(*Results)
+	if want := hex.EncodeToString(append([]byte{0x05}, "not a ping"...)); results.Banner2 != want {
+		t.Errorf("received unexpected banner2: %s, wanted: %s", results.Banner2, want)
+	}
+	if results.PongPayload != 0 || results.LatencyMS != 0 {
+		t.Errorf("a non-pong reply was read as a pong: %+v", results)
+	}
+}
+
+func TestScanBuiltPing(t *testing.T) {
+	scanner := newTestScanner(nil)
+	scanner.config.BuildHandshake = true
+	scanner.config.ProtocolVer = 765
+	scanner.config.Probe2 = defaultProbe
+	status, res, err := scanner.Scan(serveStatus(t, []byte(`{"description":"x"}`)))
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("received unexpected status: %s (%v)", status, err)
+	}
+	if results := res.(*Results); !results.PongEchoed || results.PongPayload == 0 {
+		t.Errorf("built ping not echoed: payload %d, echoed %v", results.PongPayload, results.PongEchoed)
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	f := &Flags{Probe1: "\\n", Probe2: "\\n", ReadTimeout: time.Second, MaxBannerSize: 32800, PingCount: 1, Pattern: "Paper ("}
+	if err := f.Validate(nil); err == nil || !strings.HasPrefix(err.Error(), "invalid --pattern") {
+		t.Errorf("got %v, wanted an invalid --pattern error", err)
+	}
+}
+
+func TestValidateProbes(t *testing.T) {
+	tests := map[string]struct {
+		probe1, probe2 string
+		wantErr        string
+	}{
+		"default":     {probe1: "\\n", probe2: "\\n"},
+		"hex escapes": {probe1: "\\x0f\\x00", probe2: "\\x01"},
+		"bad escape":  {probe1: "\\q", probe2: "\\n", wantErr: "invalid --probe1"},
+		"trailing \\": {probe1: "\\n", probe2: "abc\\", wantErr: "invalid --probe2"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := &Flags{Probe1: test.probe1, Probe2: test.probe2, ReadTimeout: time.Second, MaxBannerSize: 32800, PingCount: 1}
+			err := f.Validate(nil)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.HasPrefix(err.Error(), test.wantErr) {
+				t.Errorf("got %v, wanted %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	tests := map[string]struct {
+		flags   func(*Flags)
+		wantErr string
+	}{
+		"exclude ranges": {flags: func(f *Flags) { f.ExcludeRanges = "10.0.0.0/33" }, wantErr: "invalid --exclude-ranges"},
+		"match":          {flags: func(f *Flags) { f.Match = "legacy_only &&" }, wantErr: "invalid --match"},
+		"match only":     {flags: func(f *Flags) { f.MatchOnly = true }, wantErr: "--match-only requires"},
+		"probe":          {flags: func(f *Flags) { f.Probe1 = "\\q" }, wantErr: "invalid --probe1"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := &Flags{Probe1: "\\n", Probe2: "\\n", ReadTimeout: time.Second, MaxBannerSize: 32800, PingCount: 1}
+			test.flags(f)
+			if err := new(Scanner).Init(f); err == nil || !strings.HasPrefix(err.Error(), test.wantErr) {
+				t.Errorf("got %v, wanted %q", err, test.wantErr)
+			}
+		})
+	}
+	// The probe error wraps the strconv one.
+	f := &Flags{Probe1: "\\q", Probe2: "\\n", ReadTimeout: time.Second, MaxBannerSize: 32800, PingCount: 1}
+	if err := new(Scanner).Init(f); !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("got %v, wanted it to wrap strconv.ErrSyntax", err)
+	}
+}
+
+func TestReadPacketTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	// A server that trickles the packet out too slowly to finish in time.
+	go func() {
+		for i := 0; i < 10; i++ {
+			time.Sleep(30 * time.Millisecond)
+			if _, err := server.Write([]byte{'x'}); err != nil {
+				return
+			}
+		}
+	}()
+	phase := &deadlineReader{conn: client}
+	phase.startPhase(100 * time.Millisecond)
+	_, count, err := readPacket(phase, 10)
+	if zgrab2.TryGetScanStatus(err) != zgrab2.SCAN_IO_TIMEOUT {
+		t.Fatalf("got %v, wanted a read timeout", err)
+	}
+	if count.Read == 0 || count.Read >= 10 {
+		t.Errorf("read %d bytes, wanted a partial packet", count.Read)
+	}
+}
+
+func encodeLegacyKick(msg string) []byte {
+	units := utf16.Encode([]rune(msg))
+	b := []byte{legacyKickID, byte(len(units) >> 8), byte(len(units))}
+	for _, u := range units {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return b
+}
+
+func TestIsLegacyKick(t *testing.T) {
+	// a modern 255-byte status packet: 0xFF 0x01 length, packet ID 0,
+	// 252-byte string
+	modern := append([]byte{0xFF, 0x01, 0x00}, varint.Append(nil, 252)...)
+	tests := map[string]struct {
+		data []byte
+		want bool
+	}{
+		"legacy kick":      {data: encodeLegacyKick("Outdated server!"), want: true},
+		"legacy ping data": {data: encodeLegacyKick("§1\x0061\x001.5.2\x00A Minecraft Server\x000\x0020"), want: true},
+		"modern 0xFF":      {data: modern, want: false},
+		"modern short":     {data: []byte{0x10, 0x00, 0x0E, '{'}, want: false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := isLegacyKick(bufio.NewReader(bytes.NewReader(test.data))); got != test.want {
+				t.Errorf("isLegacyKick() = %v, wanted %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestScanLegacyKick(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(encodeLegacyKick("Outdated server! I'm still on 1.5.2"))
+	}()
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+
+	status, res, err := newTestScanner(testHandshake(765)).Scan(target)
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("received unexpected status: %s (%v)", status, err)
+	}
+	results := res.(*Results)
+	if !results.LegacyOnly {
+		t.Errorf("legacy_only not set")
+	}
+	if want := "Outdated server! I'm still on 1.5.2"; results.LegacyKickMessage != want {
+		t.Errorf("received unexpected kick message: %q, wanted: %q", results.LegacyKickMessage, want)
+	}
+}
+
+func TestParseLegacyStatus(t *testing.T) {
+	tests := map[string]struct {
+		msg  string
+		want *LegacyStatus
+	}{
+		"1.4+": {
+			msg:  "§1\x0078\x001.6.4\x00§aA Minecraft Server\x003\x0020",
+			want: &LegacyStatus{ProtocolVersion: 78, ServerVersion: "1.6.4", MOTD: "§aA Minecraft Server", OnlinePlayers: 3, MaxPlayers: 20},
+		},
+		"beta 1.8": {
+			msg:  "§cRed §lserver§0§12",
+			want: &LegacyStatus{MOTD: "§cRed §lserver", OnlinePlayers: 0, MaxPlayers: 12},
+		},
+		"kick":             {msg: "Outdated server!"},
+		"short 1.4+":       {msg: "§1\x0078\x001.6.4"},
+		"bad player count": {msg: "A server§many§20"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := parseLegacyStatus(test.msg)
+			if (got == nil) != (test.want == nil) || got != nil && *got != *test.want {
+				t.Errorf("parseLegacyStatus() = %+v, wanted %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestScanLegacyPing(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		ping := make([]byte, len(legacyPing))
+		if _, err := io.ReadFull(conn, ping); err != nil || !bytes.Equal(ping, legacyPing) {
+			return
+		}
+		conn.Write(encodeLegacyKick("§1\x0061\x001.5.2\x00A Minecraft Server\x000\x0020"))
+	}()
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+
+	scanner := newTestScanner(testHandshake(765))
+	scanner.config.Legacy = true
+	status, res, err := scanner.Scan(target)
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("received unexpected status: %s (%v)", status, err)
+	}
+	results := res.(*Results)
+	if results.LegacyOnly {
+		t.Errorf("legacy_only set for a --legacy ping")
+	}
+	want := LegacyStatus{ProtocolVersion: 61, ServerVersion: "1.5.2", MOTD: "A Minecraft Server", OnlinePlayers: 0, MaxPlayers: 20}
+	if results.Legacy == nil || *results.Legacy != want {
+		t.Errorf("received unexpected legacy status: %+v, wanted: %+v", results.Legacy, want)
+	}
+	if results.MOTDClean != "A Minecraft Server" || !results.DefaultMOTD || results.DefaultMOTDMatch != "A Minecraft Server" {
+		t.Errorf("got motd %q, default %v (%q)", results.MOTDClean, results.DefaultMOTD, results.DefaultMOTDMatch)
+	}
+}
+
+func TestScanLiveness(t *testing.T) {
+	scanner := newTestScanner(testHandshake(765))
+	scanner.config.Liveness = true
+
+	target := serveStatus(t, nil)
+	status, res, err := scanner.Scan(target)
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("open port: got %s, %v", status, err)
+	}
+	if got := res.(*Results).Liveness; got != livenessOpen {
+		t.Errorf("open port: got liveness %q, wanted %q", got, livenessOpen)
+	}
+
+	// A port that was just released is closed.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+	status, res, err = scanner.Scan(zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port})
+	if err == nil || status != zgrab2.SCAN_CONNECTION_REFUSED {
+		t.Fatalf("closed port: got %s, %v", status, err)
+	}
+	if got := res.(*Results).Liveness; got != livenessClosed {
+		t.Errorf("closed port: got liveness %q, wanted %q", got, livenessClosed)
+	}
+}
+
+func TestScanMaxFaviconSize(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 30000)...)
+	favicon := faviconPrefix + base64.StdEncoding.EncodeToString(png)
+	status := []byte(`{"description":"small","favicon":"` + favicon + `"}`)
+	scanner := newTestScanner(testHandshake(765))
+	if scanStatus, _, err := scanner.Scan(serveStatus(t, status)); scanStatus != zgrab2.SCAN_PROTOCOL_ERROR {
+		t.Errorf("favicon counted against --max-banner-size: got %s, %v", scanStatus, err)
+	}
+
+	// The favicon has its own budget, and the rest is small.
+	scanner.config.MaxFaviconSize = 1 << 16
+	scanStatus, res, err := scanner.Scan(serveStatus(t, status))
+	if err != nil || scanStatus != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("own favicon budget: got %s, %v", scanStatus, err)
+	}
+	if results := res.(*Results); results.Favicon == nil || results.SizeLimitExceeded != "" {
+		t.Errorf("own favicon budget: got favicon %v, exceeded %q", results.Favicon != nil, results.SizeLimitExceeded)
+	}
+
+	// A tight favicon budget drops the favicon but keeps the status.
+	scanner.config.MaxBannerSize = 1 << 17
+	scanner.config.MaxFaviconSize = 100
+	scanStatus, res, err = scanner.Scan(serveStatus(t, status))
+	if err != nil || scanStatus != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("tight favicon budget: got %s, %v", scanStatus, err)
+	}
+	if results := res.(*Results); results.Favicon != nil || results.SizeLimitExceeded != sizeLimitFavicon || results.MOTD != "small" {
+		t.Errorf("tight favicon budget: got favicon %v, exceeded %q, motd %q", results.Favicon != nil, results.SizeLimitExceeded, results.MOTD)
+	}
+
+	// The rest of the status is still held to --max-banner-size.
+	scanner.config.MaxBannerSize = 1000
+	scanner.config.MaxFaviconSize = 1 << 16
+	big := []byte(`{"description":"` + strings.Repeat("x", 2000) + `"}`)
+	scanStatus, res, _ = scanner.Scan(serveStatus(t, big))
+	if scanStatus != zgrab2.SCAN_PROTOCOL_ERROR || res.(*Results).SizeLimitExceeded != sizeLimitBanner {
+		t.Errorf("large description: got %s, %+v", scanStatus, res)
+	}
+}
+
+func TestScanMaxBannerSize(t *testing.T) {
+	status := []byte(`{"description":"` + strings.Repeat("x", 40000) + `"}`)
+	scanner := newTestScanner(testHandshake(765))
+	if scanStatus, _, err := scanner.Scan(serveStatus(t, status)); scanStatus != zgrab2.SCAN_PROTOCOL_ERROR {
+		t.Errorf("default limit: got %s, %v", scanStatus, err)
+	}
+	scanner.config.MaxBannerSize = 1 << 16
+	scanStatus, res, err := scanner.Scan(serveStatus(t, status))
+	if err != nil || scanStatus != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("raised limit: got %s, %v", scanStatus, err)
+	}
+	if results := res.(*Results); len(results.MOTD) != 40000 {
+		t.Errorf("got a %d byte motd", len(results.MOTD))
+	}
+}
+
+func TestScanEncoding(t *testing.T) {
+	status := []byte(`{"description":"x"}`)
+	scanner := newTestScanner(testHandshake(765))
+	scanner.config.RawBanner = true
+	scanner.config.Encoding = encodingBase64
+	scanStatus, res, err := scanner.Scan(serveStatus(t, status))
+	if err != nil || scanStatus != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("got %s, %v", scanStatus, err)
+	}
+	results := res.(*Results)
+	if results.Encoding != encodingBase64 {
+		t.Errorf("got encoding %q", results.Encoding)
+	}
+	banner, err := base64.StdEncoding.DecodeString(results.Banner1)
+	if err != nil || !bytes.HasSuffix(banner, status) {
+		t.Errorf("banner1 %q does not decode to the status: %v", results.Banner1, err)
+	}
+	if want := base64.StdEncoding.EncodeToString(testPing[1:]); results.Banner2 != want {
+		t.Errorf("got banner2 %s, wanted %s", results.Banner2, want)
+	}
+}
+
+func TestScanPingCount(t *testing.T) {
+	// Like vanilla, serveStatus closes after the first pong.
+	scanner := newTestScanner(testHandshake(765))
+	scanner.config.PingCount = 3
+	status, res, err := scanner.Scan(serveStatus(t, []byte(`{"description":"x"}`)))
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("got %s, %v", status, err)
+	}
+	if stats := res.(*Results).LatencyStats; stats == nil || stats.Rounds != 1 {
+		t.Errorf("closing server: got %+v", stats)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		for i := 0; i < 2; i++ {
+			if _, err := readTestPacket(conn); err != nil {
+				return
+			}
+		}
+		doc := []byte(`{"description":"x"}`)
+		conn.Write(framePacket(0x00, append(varint.Append(nil, len(doc)), doc...)))
+		// Answer three pings, then stop.
+		for i := 0; i < 3; i++ {
+			ping, err := readTestPacket(conn)
+			if err != nil {
+				return
+			}
+			conn.Write(append(varint.Append(nil, len(ping)), ping...))
+		}
+	}()
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	scanner.config.PingCount = 5
+	status, res, err = scanner.Scan(zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port})
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("got %s, %v", status, err)
+	}
+	stats := res.(*Results).LatencyStats
+	if stats == nil || stats.Rounds != 3 || stats.MinMS > stats.AvgMS || stats.AvgMS > stats.MaxMS {
+		t.Errorf("answering server: got %+v", stats)
+	}
+}
+
+func TestNewLatencyStats(t *testing.T) {
+	stats := newLatencyStats([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	want := LatencyStats{Rounds: 8, MinMS: 2, AvgMS: 5, MaxMS: 9, StdDevMS: 2}
+	if *stats != want {
+		t.Errorf("got %+v, wanted %+v", *stats, want)
+	}
+}
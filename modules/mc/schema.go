@@ -0,0 +1,98 @@
+package mc
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+
+	flags "github.com/zmap/zflags"
+)
+
+// jsonSchemaDialect is the JSON Schema version mc schema produces.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaCommand is the mc schema subcommand, which prints a JSON Schema of
+// the results instead of scanning.
+type schemaCommand struct{}
+
+// Validate returns the schema as a help message, so that, as for --help,
+// the parser prints it to stdout and zgrab2 exits without scanning.
+func (c *schemaCommand) Validate(args []string) error {
+	var b strings.Builder
+	if err := writeSchema(&b); err != nil {
+		return err
+	}
+	return &flags.Error{Type: flags.ErrHelp, Message: strings.TrimSuffix(b.String(), "\n")}
+}
+
+// writeSchema writes a JSON Schema for Results, generated from the struct
+// itself so that it cannot drift from the output.
+func writeSchema(w io.Writer) error {
+	schema := typeSchema(reflect.TypeOf(Results{}))
+	schema["$schema"] = jsonSchemaDialect
+	schema["title"] = "zgrab2 mc results"
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(schema)
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		// Any JSON value.
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			tag := strings.Split(field.Tag.Get("json"), ",")
+			name := tag[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = typeSchema(field.Type)
+			omitempty := false
+			for _, opt := range tag[1:] {
+				omitempty = omitempty || opt == "omitempty"
+			}
+			if !omitempty && field.Type.Kind() != reflect.Ptr {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
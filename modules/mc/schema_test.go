@@ -0,0 +1,56 @@
+package mc
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	flags "github.com/zmap/zflags"
+)
+
+func TestWriteSchema(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeSchema(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Type       string `json:"type"`
+		Properties map[string]struct {
+			Type       string                     `json:"type"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Type != "object" {
+		t.Errorf("got type %q, wanted object", schema.Type)
+	}
+	// Every output field must be described.
+	for name := range resultFields() {
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("field %s missing from the schema", name)
+		}
+	}
+	if got := schema.Properties["banner1_bytes"]; got.Type != "object" || got.Properties["declared"] == nil {
+		t.Errorf("banner1_bytes: got %+v, wanted an object with declared", got)
+	}
+	if got := schema.Properties["legacy_only"].Type; got != "boolean" {
+		t.Errorf("legacy_only: got type %q, wanted boolean", got)
+	}
+}
+
+func TestSchemaCommand(t *testing.T) {
+	err := new(schemaCommand).Validate(nil)
+	flagsErr, ok := err.(*flags.Error)
+	if !ok || flagsErr.Type != flags.ErrHelp {
+		t.Fatalf("got %v, wanted a help error", err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(flagsErr.Message), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema["$schema"] != jsonSchemaDialect {
+		t.Errorf("got $schema %v, wanted %s", schema["$schema"], jsonSchemaDialect)
+	}
+}
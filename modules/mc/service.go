@@ -0,0 +1,44 @@
+package mc
+
+import "bytes"
+
+// Results.ServiceGuess values for the services guessService recognises.
+const (
+	serviceHTTP    = "http"
+	serviceSSH     = "ssh"
+	serviceTLS     = "tls"
+	serviceSMTP    = "smtp"
+	serviceFTP     = "ftp"
+	servicePOP3    = "pop3"
+	serviceIMAP    = "imap"
+	serviceUnknown = "unknown"
+)
+
+// guessService names the service whose response starts with head, for
+// responses that are not a Minecraft status. The checks only look at the
+// first bytes, so they are cheap but no more than a hint.
+func guessService(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("HTTP/")):
+		return serviceHTTP
+	case bytes.HasPrefix(head, []byte("SSH-")):
+		return serviceSSH
+	case len(head) >= 3 && (head[0] == 0x15 || head[0] == 0x16) && head[1] == 0x03 && head[2] <= 0x04:
+		// a TLS alert or handshake record
+		return serviceTLS
+	case bytes.HasPrefix(head, []byte("220")):
+		line := head
+		if end := bytes.IndexByte(line, '\n'); end >= 0 {
+			line = line[:end]
+		}
+		if bytes.Contains(bytes.ToUpper(line), []byte("FTP")) {
+			return serviceFTP
+		}
+		return serviceSMTP
+	case bytes.HasPrefix(head, []byte("+OK")):
+		return servicePOP3
+	case bytes.HasPrefix(head, []byte("* OK")):
+		return serviceIMAP
+	}
+	return serviceUnknown
+}
@@ -0,0 +1,56 @@
+package mc
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zmap/zgrab2"
+)
+
+func TestGuessService(t *testing.T) {
+	tests := map[string]string{
+		"HTTP/1.1 400 Bad Request\r\n":          serviceHTTP,
+		"SSH-2.0-OpenSSH_9.6\r\n":               serviceSSH,
+		"\x15\x03\x01\x00\x02\x02\x46":          serviceTLS,
+		"220 mail.example ESMTP Postfix\r\n":    serviceSMTP,
+		"220 (vsFTPd 3.0.5)\r\n":                serviceFTP,
+		"+OK Dovecot ready.\r\n":                servicePOP3,
+		"* OK [CAPABILITY IMAP4rev1] ready\r\n": serviceIMAP,
+		"\x10\x00\x0e{}":                        serviceUnknown,
+	}
+	for head, want := range tests {
+		if got := guessService([]byte(head)); got != want {
+			t.Errorf("guessService(%q) = %q, wanted %q", head, got, want)
+		}
+	}
+}
+
+func TestScanServiceGuess(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+		time.Sleep(100 * time.Millisecond)
+	}()
+	port := uint(listener.Addr().(*net.TCPAddr).Port)
+	target := zgrab2.ScanTarget{IP: net.ParseIP("127.0.0.1"), Port: &port}
+
+	scanner := newTestScanner(testHandshake(765))
+	scanner.config.EOFPolicy = eofPolicyError
+	status, res, _ := scanner.Scan(target)
+	if status == zgrab2.SCAN_SUCCESS {
+		t.Fatalf("an SSH banner was accepted as a status response")
+	}
+	if got := res.(*Results).ServiceGuess; got != serviceSSH {
+		t.Errorf("got service guess %q, wanted %q", got, serviceSSH)
+	}
+}
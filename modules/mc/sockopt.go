@@ -0,0 +1,71 @@
+package mc
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/zmap/zgrab2"
+)
+
+// tcpConn returns the *net.TCPConn underlying conn, or nil if conn is not a
+// plain TCP connection (e.g. it was dialed through a proxy).
+func tcpConn(conn net.Conn) *net.TCPConn {
+	if tc, ok := conn.(*zgrab2.TimeoutConnection); ok {
+		conn = tc.Conn
+	}
+	tcp, _ := conn.(*net.TCPConn)
+	return tcp
+}
+
+// applySocketBuffers sets SO_RCVBUF / SO_SNDBUF on conn. Sizes of zero leave
+// the system default in place. Failures are not fatal to the scan, so they
+// are returned as warnings instead, as is the kernel clamping a buffer below
+// the requested size.
+func applySocketBuffers(conn net.Conn, rcvbuf, sndbuf int) []string {
+	if rcvbuf == 0 && sndbuf == 0 {
+		return nil
+	}
+	tcp := tcpConn(conn)
+	if tcp == nil {
+		return nil
+	}
+	var warnings []string
+	rcvSet, sndSet := false, false
+	if rcvbuf > 0 {
+		if err := tcp.SetReadBuffer(rcvbuf); err != nil {
+			warnings = append(warnings, fmt.Sprintf("set SO_RCVBUF: %v", err))
+		} else {
+			rcvSet = true
+		}
+	}
+	if sndbuf > 0 {
+		if err := tcp.SetWriteBuffer(sndbuf); err != nil {
+			warnings = append(warnings, fmt.Sprintf("set SO_SNDBUF: %v", err))
+		} else {
+			sndSet = true
+		}
+	}
+	gotRcv, gotSnd, ok := socketBufferSizes(tcp)
+	if !ok {
+		return warnings
+	}
+	if rcvSet && gotRcv < rcvbuf {
+		warnings = append(warnings, fmt.Sprintf("SO_RCVBUF clamped to %d (wanted %d)", gotRcv, rcvbuf))
+	}
+	if sndSet && gotSnd < sndbuf {
+		warnings = append(warnings, fmt.Sprintf("SO_SNDBUF clamped to %d (wanted %d)", gotSnd, sndbuf))
+	}
+	return warnings
+}
+
+// recordSocketError appends any pending socket-level error on conn to the
+// results. It must be called before conn is closed.
+func (r *Results) recordSocketError(conn net.Conn) {
+	tcp := tcpConn(conn)
+	if tcp == nil {
+		return
+	}
+	if warning := socketError(tcp); warning != "" {
+		r.SocketWarnings = append(r.SocketWarnings, warning)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package mc
+
+import "net"
+
+// socketError is not supported on this platform.
+func socketError(conn *net.TCPConn) string {
+	return ""
+}
+
+// socketBufferSizes is not supported on this platform.
+func socketBufferSizes(conn *net.TCPConn) (rcvbuf, sndbuf int, ok bool) {
+	return 0, 0, false
+}
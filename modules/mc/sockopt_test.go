@@ -0,0 +1,64 @@
+package mc
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+// loopbackConn returns the client side of a loopback TCP connection.
+func loopbackConn(t *testing.T) *net.TCPConn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return conn.(*net.TCPConn)
+}
+
+func TestApplySocketBuffers(t *testing.T) {
+	conn := loopbackConn(t)
+	if warnings := applySocketBuffers(conn, 0, 0); warnings != nil {
+		t.Errorf("got warnings %q with no sizes set", warnings)
+	}
+	const size = 16384
+	warnings := applySocketBuffers(conn, size, size)
+	if len(warnings) != 0 {
+		t.Errorf("got warnings %q for %d-byte buffers", warnings, size)
+	}
+	rcvbuf, sndbuf, ok := socketBufferSizes(conn)
+	if !ok {
+		t.Skip("socket buffer sizes cannot be read on this platform")
+	}
+	if rcvbuf < size || sndbuf < size {
+		t.Errorf("got SO_RCVBUF=%d SO_SNDBUF=%d, wanted at least %d", rcvbuf, sndbuf, size)
+	}
+}
+
+func TestApplySocketBuffersClamped(t *testing.T) {
+	conn := loopbackConn(t)
+	if _, _, ok := socketBufferSizes(conn); !ok {
+		t.Skip("socket buffer sizes cannot be read on this platform")
+	}
+	// Far beyond any default rmem_max / wmem_max, so the kernel either
+	// clamps the buffers or refuses the sizes outright.
+	const size = 1 << 30
+	warnings := applySocketBuffers(conn, size, size)
+	if len(warnings) != 2 {
+		t.Fatalf("got warnings %q, wanted one per buffer", warnings)
+	}
+	if !strings.Contains(warnings[0], "SO_RCVBUF") || !strings.Contains(warnings[1], "SO_SNDBUF") {
+		t.Errorf("got warnings %q", warnings)
+	}
+}
@@ -0,0 +1,53 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package mc
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// socketError reads and clears SO_ERROR on the socket, returning a
+// description of the pending error, or "" if there is none.
+func socketError(conn *net.TCPConn) string {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return ""
+	}
+	var soErr int
+	var getErr error
+	if err := raw.Control(func(fd uintptr) {
+		soErr, getErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_ERROR)
+	}); err != nil {
+		return ""
+	}
+	if getErr != nil {
+		return fmt.Sprintf("get SO_ERROR: %v", getErr)
+	}
+	if soErr != 0 {
+		return fmt.Sprintf("SO_ERROR: %v", syscall.Errno(soErr))
+	}
+	return ""
+}
+
+// socketBufferSizes returns the effective SO_RCVBUF and SO_SNDBUF of the
+// socket. ok is false if they could not be read.
+func socketBufferSizes(conn *net.TCPConn) (rcvbuf, sndbuf int, ok bool) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, 0, false
+	}
+	var rcvErr, sndErr error
+	if err := raw.Control(func(fd uintptr) {
+		rcvbuf, rcvErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+		sndbuf, sndErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+	}); err != nil {
+		return 0, 0, false
+	}
+	if rcvErr != nil || sndErr != nil {
+		return 0, 0, false
+	}
+	return rcvbuf, sndbuf, true
+}
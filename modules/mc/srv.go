@@ -0,0 +1,90 @@
+package mc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/zmap/zgrab2"
+)
+
+// lookupSRV looks up the _minecraft._tcp SRV record clients use to find the
+// server for domain, with --resolver if given, and returns its usable
+// records with the trailing dot trimmed from their targets. It returns none
+// if there is no usable record, in which case the target is scanned as
+// given.
+func (s *Scanner) lookupSRV(domain string) []*net.SRV {
+	resolver := s.resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.lookupTimeout())
+	defer cancel()
+	_, records, err := resolver.LookupSRV(ctx, "minecraft", "tcp", domain)
+	if err != nil {
+		return nil
+	}
+	// Records come sorted by priority and randomized by weight, as a client
+	// would pick them.
+	var usable []*net.SRV
+	for _, record := range records {
+		if host := strings.TrimSuffix(record.Target, "."); host != "" {
+			usable = append(usable, &net.SRV{Target: host, Port: record.Port, Priority: record.Priority, Weight: record.Weight})
+		}
+	}
+	return usable
+}
+
+// SRVTarget is a server named by the target's SRV record, with --srv-all,
+// and what it answered: the scan status, and the error or the version, MOTD
+// and player counts of its status.
+type SRVTarget struct {
+	Host     string `json:"host"`
+	Port     uint16 `json:"port"`
+	Priority uint16 `json:"priority"`
+	Weight   uint16 `json:"weight"`
+
+	Status        zgrab2.ScanStatus `json:"status"`
+	Error         string            `json:"error,omitempty"`
+	VersionName   string            `json:"version_name,omitempty"`
+	Protocol      int               `json:"protocol,omitempty"`
+	MOTD          string            `json:"motd,omitempty"`
+	PlayersOnline int               `json:"players_online,omitempty"`
+	PlayersMax    int               `json:"players_max,omitempty"`
+}
+
+// probeSRVTargets records every server the SRV record names. The first is
+// the one already scanned, with status; the status is fetched from each of
+// the others on a connection of its own, addressed to that server as a
+// client would address it.
+func (s *Scanner) probeSRVTargets(target *zgrab2.ScanTarget, status zgrab2.ScanStatus, results *Results) {
+	for i, record := range results.srvRecords {
+		srv := SRVTarget{Host: record.Target, Port: record.Port, Priority: record.Priority, Weight: record.Weight}
+		var parsed *StatusResponse
+		if i == 0 {
+			srv.Status, parsed = status, results.Status
+		} else {
+			via := &Results{SRVHost: record.Target, SRVPort: record.Port}
+			host, _ := s.handshakeAddr(target, via)
+			packet, err := s.fetchStatus(target, via, host, s.config.ProtocolVer)
+			if errors.Is(err, ErrExcluded) {
+				srv.Status, srv.Error = zgrab2.SCAN_SKIPPED, skippedExcluded
+			} else if err != nil {
+				srv.Status, srv.Error = zgrab2.TryGetScanStatus(err), err.Error()
+			} else if parsed = parseStatus(packet); parsed == nil {
+				srv.Status, srv.Error = zgrab2.SCAN_PROTOCOL_ERROR, "invalid status response"
+			} else {
+				srv.Status = zgrab2.SCAN_SUCCESS
+			}
+		}
+		if parsed != nil {
+			srv.VersionName = parsed.Version.Name
+			srv.Protocol = parsed.Version.Protocol
+			srv.MOTD = stripFormatting(flattenChat(parsed.Description))
+			srv.PlayersOnline = parsed.Players.Online
+			srv.PlayersMax = parsed.Players.Max
+		}
+		results.SRVTargets = append(results.SRVTargets, srv)
+	}
+}
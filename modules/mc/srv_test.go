@@ -0,0 +1,160 @@
+package mc
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/zmap/zgrab2"
+)
+
+// answerSRV replies to a single-question DNS query with one SRV record for
+// target:port if the question is type SRV, and otherwise as answerA does.
+func answerSRV(query []byte, target string, port uint16, ip net.IP) []byte {
+	return answerSRVRecords(query, ip, net.SRV{Target: target, Port: port, Weight: 5})
+}
+
+// answerSRVRecords replies to a single-question DNS query with records if
+// the question is type SRV, and otherwise as answerA does.
+func answerSRVRecords(query []byte, ip net.IP, records ...net.SRV) []byte {
+	end := 12
+	for query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	end += 5
+	if binary.BigEndian.Uint16(query[end-4:]) != 33 {
+		return answerA(query, ip)
+	}
+	reply := answerA(query[:end], ip) // header and question, no answers
+	for _, record := range records {
+		var rdata []byte
+		rdata = binary.BigEndian.AppendUint16(rdata, record.Priority)
+		rdata = binary.BigEndian.AppendUint16(rdata, record.Weight)
+		rdata = binary.BigEndian.AppendUint16(rdata, record.Port)
+		for _, label := range strings.Split(record.Target, ".") {
+			rdata = append(append(rdata, byte(len(label))), label...)
+		}
+		rdata = append(rdata, 0)
+		reply = append(reply,
+			0xc0, 0x0c, // pointer to the question name
+			0x00, 0x21, 0x00, 0x01, // SRV, IN
+			0x00, 0x00, 0x00, 0x3c) // TTL
+		reply = binary.BigEndian.AppendUint16(reply, uint16(len(rdata)))
+		reply = append(reply, rdata...)
+	}
+	binary.BigEndian.PutUint16(reply[6:], uint16(len(records)))
+	return reply
+}
+
+func TestScanSRV(t *testing.T) {
+	target := serveStatus(t, []byte(`{"description":"behind srv"}`))
+	srvPort := uint16(*target.Port)
+
+	dns, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dns.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := dns.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			dns.WriteToUDP(answerSRV(buf[:n], "play.mc.example", srvPort, net.IPv4(127, 0, 0, 1).To4()), addr)
+		}
+	}()
+
+	scanner := newTestScanner(testHandshake(765))
+	scanner.config.SRV = true
+	if scanner.resolver, err = newResolver(dns.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	// The port given is not the one the SRV record names.
+	port := uint(1)
+	status, res, err := scanner.Scan(zgrab2.ScanTarget{Domain: "mc.example", Port: &port})
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("received unexpected status: %s (%v)", status, err)
+	}
+	results := res.(*Results)
+	if results.SRVHost != "play.mc.example" || results.SRVPort != srvPort {
+		t.Errorf("got srv %s:%d, wanted play.mc.example:%d", results.SRVHost, results.SRVPort, srvPort)
+	}
+	if results.MOTD != "behind srv" {
+		t.Errorf("got motd %q", results.MOTD)
+	}
+}
+
+func TestScanSRVAll(t *testing.T) {
+	primary := serveStatus(t, []byte(`{"version":{"name":"1.20.4","protocol":765},"players":{"online":3,"max":50},"description":"node one"}`))
+	backup := serveHandshakes(t, func(host string, _ int) []byte {
+		return []byte(`{"version":{"name":"1.20.4","protocol":765},"players":{"online":1,"max":50},"description":"` + host + `"}`)
+	})
+	// Nothing listens here.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := uint16(closed.Addr().(*net.TCPAddr).Port)
+	closed.Close()
+
+	records := []net.SRV{
+		{Target: "one.mc.example", Port: uint16(*primary.Port), Priority: 0, Weight: 10},
+		{Target: "two.mc.example", Port: uint16(*backup.Port), Priority: 10, Weight: 5},
+		{Target: "three.mc.example", Port: closedPort, Priority: 20, Weight: 5},
+	}
+	dns, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dns.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := dns.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			dns.WriteToUDP(answerSRVRecords(buf[:n], net.IPv4(127, 0, 0, 1).To4(), records...), addr)
+		}
+	}()
+
+	scanner := newTestScanner(testHandshake(765))
+	scanner.config.SRV = true
+	scanner.config.SRVAll = true
+	if scanner.resolver, err = newResolver(dns.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	port := uint(25565)
+	status, res, err := scanner.Scan(zgrab2.ScanTarget{Domain: "mc.example", Port: &port})
+	if err != nil || status != zgrab2.SCAN_SUCCESS {
+		t.Fatalf("received unexpected status: %s (%v)", status, err)
+	}
+	results := res.(*Results)
+	if results.SRVHost != "one.mc.example" || results.MOTD != "node one" {
+		t.Errorf("got srv host %q, motd %q", results.SRVHost, results.MOTD)
+	}
+	if len(results.SRVTargets) != len(records) {
+		t.Fatalf("got srv targets %+v", results.SRVTargets)
+	}
+	for i, want := range []SRVTarget{
+		{Status: zgrab2.SCAN_SUCCESS, VersionName: "1.20.4", Protocol: 765, MOTD: "node one", PlayersOnline: 3, PlayersMax: 50},
+		{Status: zgrab2.SCAN_SUCCESS, VersionName: "1.20.4", Protocol: 765, MOTD: "two.mc.example", PlayersOnline: 1, PlayersMax: 50},
+		{}, // unreachable
+	} {
+		got := results.SRVTargets[i]
+		record := records[i]
+		want.Host, want.Port, want.Priority, want.Weight = record.Target, record.Port, record.Priority, record.Weight
+		if want.Status == "" {
+			if got.Status == zgrab2.SCAN_SUCCESS || got.Error == "" {
+				t.Errorf("target %d: got status %s, error %q", i, got.Status, got.Error)
+			}
+			want.Status, want.Error = got.Status, got.Error
+		}
+		if got != want {
+			t.Errorf("target %d: got %+v, wanted %+v", i, got, want)
+		}
+	}
+}
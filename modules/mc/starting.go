@@ -0,0 +1,35 @@
+package mc
+
+import "strings"
+
+// builtinStartingKeywords mark the placeholder MOTDs that managed hosts and
+// proxies answer with while the server behind them boots, used unless
+// --starting-keywords is given.
+var builtinStartingKeywords = []string{
+	"server is starting",
+	"server is loading",
+	"starting up",
+	"starting server",
+	"booting",
+	"please wait",
+}
+
+// matchStarting reports whether status, with motd its description with
+// formatting codes stripped, is that of a server still starting up: one
+// with no player slots whose MOTD or version name holds one of keywords,
+// matched without regard to case. It returns the keyword found.
+func matchStarting(status *StatusResponse, motd string, keywords []string) (string, bool) {
+	if status.Players.Max != 0 {
+		return "", false
+	}
+	texts := []string{strings.ToLower(motd), strings.ToLower(stripFormatting(status.Version.Name))}
+	for _, keyword := range keywords {
+		k := strings.ToLower(keyword)
+		for _, text := range texts {
+			if strings.Contains(text, k) {
+				return keyword, true
+			}
+		}
+	}
+	return "", false
+}
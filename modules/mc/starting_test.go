@@ -0,0 +1,32 @@
+package mc
+
+import "testing"
+
+func TestMatchStarting(t *testing.T) {
+	tests := map[string]struct {
+		status  StatusResponse
+		motd    string
+		keyword string
+	}{
+		"starting": {
+			motd:    "This Server Is Starting... please come back soon",
+			keyword: "server is starting",
+		},
+		"version name": {
+			status:  StatusResponse{Version: StatusVersion{Name: "§4● Booting", Protocol: -1}},
+			motd:    "Example Network",
+			keyword: "booting",
+		},
+		"has slots": {
+			status: StatusResponse{Players: StatusPlayers{Max: 20}},
+			motd:   "Server is starting",
+		},
+		"no keyword": {motd: "A Minecraft Server"},
+	}
+	for name, test := range tests {
+		keyword, ok := matchStarting(&test.status, test.motd, builtinStartingKeywords)
+		if keyword != test.keyword || ok != (test.keyword != "") {
+			t.Errorf("%s: got %q, %v", name, keyword, ok)
+		}
+	}
+}
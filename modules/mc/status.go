@@ -0,0 +1,172 @@
+package mc
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// statusResponseID is the ID of the status response packet.
+const statusResponseID = 0x00
+
+// StatusResponse is the JSON document of the Server List Ping status
+// response.
+type StatusResponse struct {
+	Version StatusVersion `json:"version"`
+	Players StatusPlayers `json:"players"`
+
+	// Description is the MOTD exactly as sent: either a string or a chat
+	// component object.
+	Description json.RawMessage `json:"description,omitempty"`
+
+	// Security gathers the chat signing flags the document carries at its
+	// top level; it is nil when the server sent none of them.
+	Security *StatusSecurity `json:"security,omitempty"`
+}
+
+// StatusSecurity holds the chat signing and reporting flags of the status
+// response. EnforcesSecureChat is sent from 1.19.1 onwards, PreviewsChat
+// from 1.19 to 1.19.2, and PreventsChatReports by servers running mods such
+// as No Chat Reports. Each is nil when the server left it out.
+type StatusSecurity struct {
+	EnforcesSecureChat  *bool `json:"enforcesSecureChat,omitempty"`
+	PreviewsChat        *bool `json:"previewsChat,omitempty"`
+	PreventsChatReports *bool `json:"preventsChatReports,omitempty"`
+}
+
+// UnmarshalJSON decodes the status document, collecting its security flags
+// into Security.
+func (s *StatusResponse) UnmarshalJSON(b []byte) error {
+	type plain StatusResponse
+	var status plain
+	if err := json.Unmarshal(b, &status); err != nil {
+		return err
+	}
+	var security StatusSecurity
+	if err := json.Unmarshal(b, &security); err != nil {
+		return err
+	}
+	*s = StatusResponse(status)
+	s.Security = nil
+	if security != (StatusSecurity{}) {
+		s.Security = &security
+	}
+	return nil
+}
+
+// StatusVersion is the version object of the status response.
+type StatusVersion struct {
+	Name     string `json:"name"`
+	Protocol int    `json:"protocol"`
+}
+
+// StatusPlayers is the players object of the status response.
+type StatusPlayers struct {
+	Online int `json:"online"`
+	Max    int `json:"max"`
+
+	// Sample is the sample of online players, in the order sent.
+	// SampleMalformed is set when the sample is not a list of players with
+	// a name and a hyphenated UUID, as is common for servers that use it
+	// for advertising; entries that do not decode at all are left out.
+	Sample          []PlayerSample `json:"sample,omitempty"`
+	SampleMalformed bool           `json:"sample_malformed,omitempty"`
+}
+
+// PlayerSample is an entry in the players sample of the status response.
+type PlayerSample struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// UnmarshalJSON decodes the players object, checking each sample entry
+// separately so that a malformed sample does not fail the whole status.
+func (p *StatusPlayers) UnmarshalJSON(b []byte) error {
+	var players struct {
+		Online int             `json:"online"`
+		Max    int             `json:"max"`
+		Sample json.RawMessage `json:"sample"`
+	}
+	if err := json.Unmarshal(b, &players); err != nil {
+		return err
+	}
+	*p = StatusPlayers{Online: players.Online, Max: players.Max}
+	if len(players.Sample) == 0 || string(players.Sample) == "null" {
+		return nil
+	}
+	var entries []json.RawMessage
+	if err := json.Unmarshal(players.Sample, &entries); err != nil {
+		p.SampleMalformed = true
+		return nil
+	}
+	for _, entry := range entries {
+		var sample struct {
+			Name *string `json:"name"`
+			ID   *string `json:"id"`
+		}
+		if err := json.Unmarshal(entry, &sample); err != nil || sample.Name == nil || sample.ID == nil {
+			p.SampleMalformed = true
+			continue
+		}
+		if *sample.Name == "" || !isUUID(*sample.ID) {
+			p.SampleMalformed = true
+		}
+		p.Sample = append(p.Sample, PlayerSample{Name: *sample.Name, ID: *sample.ID})
+	}
+	return nil
+}
+
+// isUUID reports whether id is a UUID in its hyphenated hex form.
+func isUUID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i, c := range id {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// statusJSON extracts the JSON string from a status response packet body:
+// the packet ID, then the string as a VarInt length and UTF-8 bytes. It
+// returns nil if the packet is not a complete status response.
+func statusJSON(packet []byte) []byte {
+	r := bytes.NewReader(packet)
+	if id, err := readVarInt(r); err != nil || id != statusResponseID {
+		return nil
+	}
+	length, err := readVarInt(r)
+	if err != nil {
+		return nil
+	}
+	rest := packet[len(packet)-r.Len():]
+	if length < 0 || length > len(rest) {
+		return nil
+	}
+	return rest[:length]
+}
+
+// parseStatus decodes a status response packet body, returning nil if it
+// does not hold a valid status document.
+func parseStatus(packet []byte) *StatusResponse {
+	return decodeStatus(statusJSON(packet))
+}
+
+// decodeStatus decodes a status document, returning nil if it is invalid.
+func decodeStatus(doc []byte) *StatusResponse {
+	if doc == nil {
+		return nil
+	}
+	status := new(StatusResponse)
+	if err := json.Unmarshal(doc, status); err != nil {
+		return nil
+	}
+	return status
+}
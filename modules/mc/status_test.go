@@ -0,0 +1,111 @@
+package mc
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/zmap/zgrab2/lib/varint"
+)
+
+func TestParseStatus(t *testing.T) {
+	packet := func(doc string) []byte {
+		p := append([]byte{statusResponseID}, varint.Append(nil, len(doc))...)
+		return append(p, doc...)
+	}
+	status := parseStatus(packet(`{"version":{"name":"1.8.9","protocol":47},"players":{"online":3,"max":60},"description":"A Minecraft Server"}`))
+	if status == nil {
+		t.Fatal("valid status was not parsed")
+	}
+	if status.Version.Name != "1.8.9" || status.Version.Protocol != 47 || status.Players.Online != 3 || status.Players.Max != 60 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if got := string(status.Description); got != `"A Minecraft Server"` {
+		t.Errorf("unexpected description: %s", got)
+	}
+
+	for name, p := range map[string][]byte{
+		"not json":  packet("HTTP/1.1 400 Bad Request"),
+		"wrong id":  append([]byte{0x01}, packet("{}")[1:]...),
+		"truncated": packet(`{"version":{}}`)[:5],
+		"empty":     nil,
+	} {
+		if status := parseStatus(p); status != nil {
+			t.Errorf("%s: got %+v, wanted nil", name, status)
+		}
+	}
+}
+
+func TestPlayerSample(t *testing.T) {
+	tests := map[string]struct {
+		players   string
+		want      []PlayerSample
+		malformed bool
+	}{
+		"none": {players: `{"online":0,"max":20}`},
+		"valid": {
+			players: `{"online":2,"max":20,"sample":[{"name":"Notch","id":"069a79f4-44e9-4726-a5be-fca90e38aaf5"},{"name":"jeb_","id":"853c80ef-3c37-49fd-aa49-938b674adae6"}]}`,
+			want:    []PlayerSample{{"Notch", "069a79f4-44e9-4726-a5be-fca90e38aaf5"}, {"jeb_", "853c80ef-3c37-49fd-aa49-938b674adae6"}},
+		},
+		"advert": {
+			players:   `{"online":1,"max":20,"sample":[{"name":"§aJoin play.example.com!","id":"not-a-uuid"}]}`,
+			want:      []PlayerSample{{"§aJoin play.example.com!", "not-a-uuid"}},
+			malformed: true,
+		},
+		"bad entry": {
+			players:   `{"online":1,"max":20,"sample":[{"name":"Notch","id":"069a79f4-44e9-4726-a5be-fca90e38aaf5"},{"name":5},"x"]}`,
+			want:      []PlayerSample{{"Notch", "069a79f4-44e9-4726-a5be-fca90e38aaf5"}},
+			malformed: true,
+		},
+		"not a list": {players: `{"online":1,"max":20,"sample":"Notch"}`, malformed: true},
+	}
+	for name, test := range tests {
+		status := decodeStatus([]byte(`{"version":{"name":"1.20.4","protocol":765},"players":` + test.players + `}`))
+		if status == nil {
+			t.Errorf("%s: status not decoded", name)
+			continue
+		}
+		if !reflect.DeepEqual(status.Players.Sample, test.want) || status.Players.SampleMalformed != test.malformed {
+			t.Errorf("%s: got %+v", name, status.Players)
+		}
+	}
+}
+
+func TestStatusSecurity(t *testing.T) {
+	doc, err := os.ReadFile("testdata/status-1.19.2-ncr.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := decodeStatus(doc)
+	if status == nil {
+		t.Fatal("status not decoded")
+	}
+	no, yes := false, true
+	want := &StatusSecurity{EnforcesSecureChat: &no, PreviewsChat: &yes, PreventsChatReports: &yes}
+	if !reflect.DeepEqual(status.Security, want) {
+		t.Errorf("got %+v, wanted %+v", status.Security, want)
+	}
+
+	// A "security" key in the document is not taken for the flags.
+	status = decodeStatus([]byte(`{"version":{"name":"1.18.2","protocol":758},"security":{"enforcesSecureChat":true}}`))
+	if status == nil || status.Security != nil {
+		t.Errorf("security key: got %+v", status)
+	}
+	if status := decodeStatus([]byte(`{"version":{"name":"1.19.2","protocol":760},"previewsChat":"yes"}`)); status != nil {
+		t.Errorf("bad flag: got %+v, wanted nil", status)
+	}
+}
+
+func TestChatFlags(t *testing.T) {
+	status := decodeStatus([]byte(`{"version":{"name":"1.19.2","protocol":760},"enforcesSecureChat":false,"previewsChat":true}`))
+	if status == nil || status.Security == nil {
+		t.Fatalf("got %+v", status)
+	}
+	if security := status.Security; security.EnforcesSecureChat == nil || *security.EnforcesSecureChat || security.PreviewsChat == nil || !*security.PreviewsChat || security.PreventsChatReports != nil {
+		t.Errorf("got %+v", security)
+	}
+	status = decodeStatus([]byte(`{"version":{"name":"1.18.2","protocol":758}}`))
+	if status == nil || status.Security != nil {
+		t.Errorf("omitted flags: got %+v", status)
+	}
+}
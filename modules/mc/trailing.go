@@ -0,0 +1,36 @@
+package mc
+
+import (
+	"bufio"
+	"time"
+)
+
+const (
+	// trailingCap is the most --record-trailing will read.
+	trailingCap = 64 << 10
+
+	// trailingTimeout bounds how long --record-trailing waits for more data.
+	trailingTimeout = 500 * time.Millisecond
+)
+
+// countTrailing reads whatever the server sends after the exchange is
+// complete, including anything already buffered in r, which reads from
+// phase, and returns how many bytes there were. It stops at trailingCap
+// bytes, at EOF, or once trailingTimeout has passed.
+func countTrailing(phase *deadlineReader, r *bufio.Reader) int {
+	phase.startPhase(trailingTimeout)
+	buf := make([]byte, 4096)
+	total := 0
+	for total < trailingCap {
+		want := len(buf)
+		if rest := trailingCap - total; rest < want {
+			want = rest
+		}
+		n, err := r.Read(buf[:want])
+		total += n
+		if err != nil {
+			break
+		}
+	}
+	return total
+}
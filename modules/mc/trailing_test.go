@@ -0,0 +1,24 @@
+package mc
+
+import (
+	"bufio"
+	"net"
+	"testing"
+)
+
+func TestCountTrailing(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		server.Write([]byte("keep-alive"))
+		server.Write([]byte("!!"))
+		// the connection stays open; the drain must time out
+	}()
+	defer server.Close()
+
+	phase := &deadlineReader{conn: client}
+	reader := bufio.NewReader(phase)
+	if n := countTrailing(phase, reader); n != 12 {
+		t.Errorf("got %d trailing bytes, wanted 12", n)
+	}
+}
@@ -0,0 +1,67 @@
+package mc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// trapSignature is a labelled pattern for a known honeypot response.
+type trapSignature struct {
+	label   string
+	pattern *regexp.Regexp
+}
+
+// parseTrapSignatures reads --trap-signatures: one signature per line, a
+// label followed by whitespace and a regular expression matched against the
+// raw response bytes. Blank lines and lines starting with # are ignored.
+func parseTrapSignatures(r io.Reader, name string) ([]trapSignature, error) {
+	var sigs []trapSignature
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		split := strings.IndexAny(line, " \t")
+		if split < 0 {
+			return nil, fmt.Errorf("%s:%d: expected a label and a pattern", name, lineno)
+		}
+		pattern, err := regexp.Compile(strings.TrimSpace(line[split:]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, lineno, err)
+		}
+		sigs = append(sigs, trapSignature{label: line[:split], pattern: pattern})
+	}
+	return sigs, scanner.Err()
+}
+
+// loadTrapSignatures reads the signature file at path, if one is given.
+func loadTrapSignatures(path string) ([]trapSignature, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseTrapSignatures(f, path)
+}
+
+// matchTrap marks results as a honeypot if response matches a signature.
+func (s *Scanner) matchTrap(response []byte, results *Results) {
+	if results.Honeypot {
+		return
+	}
+	for _, sig := range s.traps {
+		if sig.pattern.Match(response) {
+			results.Honeypot = true
+			results.HoneypotSignature = sig.label
+			return
+		}
+	}
+}
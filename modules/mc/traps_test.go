@@ -0,0 +1,37 @@
+package mc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTrapSignatures(t *testing.T) {
+	sigs, err := parseTrapSignatures(strings.NewReader(`# comment
+
+taunt	(?i)nice try,? scanner
+fixed-motd "text":"A Minecraft Server"
+`), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Scanner{traps: sigs}
+	tests := map[string]string{
+		`{"description":{"text":"Nice try scanner"}}`:   "taunt",
+		`{"description":{"text":"A Minecraft Server"}}`: "fixed-motd",
+		`{"description":{"text":"Welcome!"}}`:           "",
+	}
+	for response, label := range tests {
+		results := new(Results)
+		s.matchTrap([]byte(response), results)
+		if results.Honeypot != (label != "") || results.HoneypotSignature != label {
+			t.Errorf("%s: got honeypot=%v signature=%q, wanted %q", response, results.Honeypot, results.HoneypotSignature, label)
+		}
+	}
+
+	if _, err := parseTrapSignatures(strings.NewReader("nolabel\n"), "test"); err == nil {
+		t.Errorf("expected an error for a line without a pattern")
+	}
+	if _, err := parseTrapSignatures(strings.NewReader("bad ([\n"), "test"); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+}
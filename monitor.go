@@ -1,6 +1,9 @@
 package zgrab2
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 // Monitor is a collection of states per scans and a channel to communicate
 // those scans to the monitor
@@ -9,6 +12,10 @@ type Monitor struct {
 	statusesChan chan moduleStatus
 	// Callback is invoked after each scan.
 	Callback func(string)
+	// successes and failures are totals across all scanners. Unlike the
+	// per-scanner states, they are safe to read while the scan is running.
+	successes uint64
+	failures  uint64
 }
 
 // State contains the respective number of successes and failures
@@ -36,6 +43,12 @@ func (m *Monitor) GetStatuses() map[string]*State {
 	return m.states
 }
 
+// Totals returns the number of successful and failed scans across all
+// scanners so far. It is safe to call while the scan is running.
+func (m *Monitor) Totals() (successes, failures uint64) {
+	return atomic.LoadUint64(&m.successes), atomic.LoadUint64(&m.failures)
+}
+
 // Stop indicates the monitor is done and the internal channel should be closed.
 // This function does not block, but will allow a call to Wait() on the
 // WaitGroup passed to MakeMonitor to return.
@@ -62,8 +75,10 @@ func MakeMonitor(statusChanSize int, wg *sync.WaitGroup) *Monitor {
 			switch s.st {
 			case statusSuccess:
 				m.states[s.name].Successes++
+				atomic.AddUint64(&m.successes, 1)
 			case statusFailure:
 				m.states[s.name].Failures++
+				atomic.AddUint64(&m.failures, 1)
 			default:
 				continue
 			}
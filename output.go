@@ -2,8 +2,11 @@ package zgrab2
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 )
 
 // FlagMap is a function that maps a single-bit bitmask (i.e. a number of the
@@ -159,3 +162,62 @@ func OutputResults(w *bufio.Writer, results <-chan []byte) error {
 	}
 	return nil
 }
+
+// Output formats accepted by --output-format.
+const (
+	OutputFormatJSON   = "json"
+	OutputFormatESBulk = "es-bulk"
+)
+
+// esBulkAction is the action/metadata line that precedes each document in
+// the Elasticsearch _bulk NDJSON format.
+type esBulkAction struct {
+	Index esBulkIndex `json:"index"`
+}
+
+type esBulkIndex struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id,omitempty"`
+}
+
+// ESDocumentID expands an --es-id-template for the given grab. {ip},
+// {domain}, {host} (the IP if present, else the domain) and {port} are
+// substituted. When the target has no explicit port the trailing ":" left
+// by templates such as "{host}:{port}" is dropped.
+func ESDocumentID(template string, grab *Grab) string {
+	host := grab.IP
+	if host == "" {
+		host = grab.Domain
+	}
+	port := ""
+	if grab.Port != 0 {
+		port = strconv.FormatUint(uint64(grab.Port), 10)
+	}
+	replacer := strings.NewReplacer(
+		"{ip}", grab.IP,
+		"{domain}", grab.Domain,
+		"{host}", host,
+		"{port}", port,
+	)
+	id := replacer.Replace(template)
+	if port == "" {
+		id = strings.TrimSuffix(id, ":")
+	}
+	return id
+}
+
+// EncodeESBulk prefixes an encoded grab with its _bulk action line, so that
+// the pair can be written out as two lines of NDJSON.
+func EncodeESBulk(grab *Grab, document []byte, index string, idTemplate string) ([]byte, error) {
+	action, err := json.Marshal(esBulkAction{Index: esBulkIndex{
+		Index: index,
+		ID:    ESDocumentID(idTemplate, grab),
+	}})
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]byte, 0, len(action)+1+len(document))
+	ret = append(ret, action...)
+	ret = append(ret, '\n')
+	return append(ret, document...), nil
+}
@@ -75,3 +75,14 @@ func ExampleListFlagsToSet() {
 	// bit0: true
 	// Unknown: 0x4
 }
+
+func ExampleEncodeESBulk() {
+	grab := &Grab{IP: "192.0.2.1", Port: 25565}
+	out, _ := EncodeESBulk(grab, []byte(`{"ip":"192.0.2.1"}`), "zgrab2", "{host}:{port}")
+	fmt.Println(string(out))
+	fmt.Println(ESDocumentID("{host}:{port}", &Grab{Domain: "example.com"}))
+	// Output:
+	// {"index":{"_index":"zgrab2","_id":"192.0.2.1:25565"}}
+	// {"ip":"192.0.2.1"}
+	// example.com
+}
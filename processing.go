@@ -16,6 +16,10 @@ type Grab struct {
 	Port   uint                    `json:"port,omitempty"`
 	Domain string                  `json:"domain,omitempty"`
 	Data   map[string]ScanResponse `json:"data,omitempty"`
+
+	// ScanSeq numbers results in the order they are output. It is only set
+	// with --seq.
+	ScanSeq uint64 `json:"scan_seq,omitempty"`
 }
 
 // ScanTarget is the host that will be scanned
@@ -153,8 +157,10 @@ func EncodeGrab(raw *Grab, includeDebug bool) ([]byte, error) {
 	return json.Marshal(outputData)
 }
 
-// grabTarget calls handler for each action
-func grabTarget(input ScanTarget, m *Monitor) []byte {
+// grabTarget calls handler for each action. It also reports whether any
+// scanner succeeded.
+func grabTarget(input ScanTarget, m *Monitor) (*Grab, bool) {
+	success := false
 	moduleResult := make(map[string]ScanResponse)
 
 	for _, scannerName := range orderedScanners {
@@ -172,6 +178,9 @@ func grabTarget(input ScanTarget, m *Monitor) []byte {
 		}(scannerName)
 		name, res := RunScanner(*scanner, m, input)
 		moduleResult[name] = res
+		if res.Status == SCAN_SUCCESS {
+			success = true
+		}
 		if res.Error != nil && !config.Multiple.ContinueOnError {
 			break
 		}
@@ -180,15 +189,36 @@ func grabTarget(input ScanTarget, m *Monitor) []byte {
 		}
 	}
 
-	raw := BuildGrabFromInputResponse(&input, moduleResult)
+	return BuildGrabFromInputResponse(&input, moduleResult), success
+}
+
+// encodeResult encodes raw in the configured output format.
+func encodeResult(raw *Grab) []byte {
 	result, err := EncodeGrab(raw, includeDebugOutput())
 	if err != nil {
 		log.Errorf("unable to marshal data: %s", err)
 	}
+	if config.OutputFormat == OutputFormatESBulk && err == nil {
+		if result, err = EncodeESBulk(raw, result, config.ESIndex, config.ESIDTemplate); err != nil {
+			log.Errorf("unable to encode bulk action: %s", err)
+		}
+	}
 
 	return result
 }
 
+// numberResults sets ScanSeq on each result from grabs and passes it on
+// encoded. With --seq, it is the only sender on the output queue, so the
+// numbers follow the output order however many workers there are.
+func numberResults(grabs <-chan *Grab, outputQueue chan<- []byte) {
+	var seq uint64
+	for raw := range grabs {
+		seq++
+		raw.ScanSeq = seq
+		outputQueue <- encodeResult(raw)
+	}
+}
+
 // Process sets up an output encoder, input reader, and starts grab workers.
 func Process(mon *Monitor) {
 	workers := config.Senders
@@ -208,6 +238,17 @@ func Process(mon *Monitor) {
 			log.Fatal(err)
 		}
 	}()
+	// With --seq, results go through numberResults on their way out.
+	var grabs chan *Grab
+	var numberDone sync.WaitGroup
+	if config.ScanSeq {
+		grabs = make(chan *Grab, workers*4)
+		numberDone.Add(1)
+		go func() {
+			defer numberDone.Done()
+			numberResults(grabs, outputQueue)
+		}()
+	}
 	//Start all the workers
 	for i := 0; i < workers; i++ {
 		go func(i int) {
@@ -217,8 +258,18 @@ func Process(mon *Monitor) {
 			}
 			for obj := range processQueue {
 				for run := uint(0); run < uint(config.ConnectionsPerHost); run++ {
-					result := grabTarget(obj, mon)
-					outputQueue <- result
+					if resultSampler.stopScanning() {
+						break
+					}
+					raw, success := grabTarget(obj, mon)
+					if !resultSampler.admit(success) {
+						continue
+					}
+					if grabs != nil {
+						grabs <- raw
+					} else {
+						outputQueue <- encodeResult(raw)
+					}
 				}
 			}
 			workerDone.Done()
@@ -230,6 +281,10 @@ func Process(mon *Monitor) {
 	}
 	close(processQueue)
 	workerDone.Wait()
+	if grabs != nil {
+		close(grabs)
+		numberDone.Wait()
+	}
 	close(outputQueue)
 	outputDone.Wait()
 }
@@ -0,0 +1,46 @@
+package zgrab2
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestNumberResults(t *testing.T) {
+	const senders, perSender = 8, 50
+	grabs := make(chan *Grab)
+	outputQueue := make(chan []byte, senders)
+	go func() {
+		numberResults(grabs, outputQueue)
+		close(outputQueue)
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perSender; j++ {
+				grabs <- &Grab{IP: "192.0.2.1"}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(grabs)
+	}()
+
+	var want uint64
+	for line := range outputQueue {
+		var grab Grab
+		if err := json.Unmarshal(line, &grab); err != nil {
+			t.Fatalf("bad output %q: %v", line, err)
+		}
+		want++
+		if grab.ScanSeq != want {
+			t.Fatalf("result %d has scan_seq %d", want, grab.ScanSeq)
+		}
+	}
+	if want != senders*perSender {
+		t.Errorf("got %d results, wanted %d", want, senders*perSender)
+	}
+}
@@ -0,0 +1,69 @@
+package zgrab2
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/zmap/zgrab2/lib/ssh/terminal"
+)
+
+// progressInterval is how often the --progress line is refreshed.
+const progressInterval = time.Second
+
+// StartProgress prints a one-line summary of the monitor's counters to stderr
+// every second if --progress was given. On a terminal the line is redrawn in
+// place; otherwise a new line is written each time so logs stay readable.
+// The returned function stops the updates and prints a final line.
+func StartProgress(m *Monitor) (stop func()) {
+	if !config.Progress {
+		return func() {}
+	}
+	tty := terminal.IsTerminal(int(os.Stderr.Fd()))
+	return startProgress(m, os.Stderr, tty, progressInterval)
+}
+
+func startProgress(m *Monitor, w io.Writer, tty bool, interval time.Duration) func() {
+	start := time.Now()
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				writeProgress(w, m, time.Since(start), tty)
+			case <-done:
+				writeProgress(w, m, time.Since(start), tty)
+				if tty {
+					fmt.Fprintln(w)
+				}
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+func writeProgress(w io.Writer, m *Monitor, elapsed time.Duration, tty bool) {
+	successes, failures := m.Totals()
+	completed := successes + failures
+	rate := 0.0
+	if secs := elapsed.Seconds(); secs > 0 {
+		rate = float64(completed) / secs
+	}
+	line := fmt.Sprintf("%s elapsed; %d completed (%.1f/s); %d found; %d errors",
+		elapsed.Truncate(time.Second), completed, rate, successes, failures)
+	if tty {
+		// Clear to end of line in case the previous line was longer.
+		fmt.Fprintf(w, "\r%s\x1b[K", line)
+	} else {
+		fmt.Fprintln(w, line)
+	}
+}
@@ -0,0 +1,73 @@
+package zgrab2
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// parseRescanStatuses splits a comma-separated list of ScanStatus values.
+func parseRescanStatuses(list string) map[ScanStatus]bool {
+	statuses := make(map[ScanStatus]bool)
+	for _, status := range strings.Split(list, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			statuses[ScanStatus(status)] = true
+		}
+	}
+	return statuses
+}
+
+// InputTargetsRescan is an InputTargetsFunc that reads the targets to scan
+// from a previous run's output, given with --rescan-file.
+func InputTargetsRescan(ch chan<- ScanTarget) error {
+	return GetTargetsRescan(config.rescanFile, parseRescanStatuses(config.RescanStatuses), ch)
+}
+
+// GetTargetsRescan reads zgrab2 JSON output from source and delivers a
+// ScanTarget for each host where any scanner ended with one of statuses.
+func GetTargetsRescan(source io.Reader, statuses map[ScanStatus]bool, ch chan<- ScanTarget) error {
+	reader := bufio.NewReader(source)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var grab Grab
+			if jsonErr := json.Unmarshal(line, &grab); jsonErr != nil {
+				log.Errorf("parse error, skipping: %v", jsonErr)
+			} else if target, ok := rescanTarget(&grab, statuses); ok {
+				ch <- target
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func rescanTarget(grab *Grab, statuses map[ScanStatus]bool) (ScanTarget, bool) {
+	failed := false
+	for _, res := range grab.Data {
+		if statuses[res.Status] {
+			failed = true
+			break
+		}
+	}
+	if !failed {
+		return ScanTarget{}, false
+	}
+	target := ScanTarget{IP: net.ParseIP(grab.IP), Domain: grab.Domain}
+	if target.IP == nil && target.Domain == "" {
+		log.Errorf("parse error, skipping: result has no ip or domain")
+		return ScanTarget{}, false
+	}
+	if grab.Port != 0 {
+		port := grab.Port
+		target.Port = &port
+	}
+	return target, true
+}
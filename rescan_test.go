@@ -0,0 +1,36 @@
+package zgrab2
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetTargetsRescan(t *testing.T) {
+	input := `{"ip":"10.0.0.1","data":{"mc":{"status":"success"}}}
+{"ip":"10.0.0.2","data":{"mc":{"status":"io-timeout"}}}
+not json
+{"domain":"example.com","port":25566,"data":{"mc":{"status":"connection-timeout"}}}
+{"ip":"10.0.0.3","data":{"mc":{"status":"connection-refused"}}}
+{"ip":"10.0.0.4","data":{"a":{"status":"success"},"b":{"status":"io-timeout"}}}`
+
+	ch := make(chan ScanTarget)
+	go func() {
+		err := GetTargetsRescan(strings.NewReader(input), parseRescanStatuses("io-timeout, connection-timeout"), ch)
+		if err != nil {
+			t.Errorf("GetTargetsRescan error: %v", err)
+		}
+		close(ch)
+	}()
+	var res []string
+	for r := range ch {
+		s := r.String()
+		if r.Port != nil {
+			s += " port:25566"
+		}
+		res = append(res, s)
+	}
+	expected := []string{"10.0.0.2", "example.com port:25566", "10.0.0.4"}
+	if strings.Join(res, ";") != strings.Join(expected, ";") {
+		t.Errorf("got targets %q; expected %q", res, expected)
+	}
+}
@@ -0,0 +1,78 @@
+package zgrab2
+
+import (
+	"math/rand"
+	"sync/atomic"
+)
+
+// SampleStats reports how many targets were scanned versus how many results
+// were emitted when --sample-limit or --sample-rate is in use.
+type SampleStats struct {
+	Scanned uint64 `json:"scanned"`
+	Emitted uint64 `json:"emitted"`
+}
+
+// sampler decides which results are emitted. It is shared by all workers, so
+// every counter is updated atomically; the limit is never overshot.
+type sampler struct {
+	limit     int64
+	rate      float64
+	successes int64
+	scanned   uint64
+	emitted   uint64
+}
+
+var resultSampler *sampler
+
+func newSampler(limit int, rate float64) *sampler {
+	if limit <= 0 && rate >= 1 {
+		return nil
+	}
+	return &sampler{limit: int64(limit), rate: rate}
+}
+
+// admit records a scanned target and reports whether its result should be
+// emitted. Results are emitted with probability rate until limit successful
+// results have gone out, after which nothing more is emitted.
+func (s *sampler) admit(success bool) bool {
+	if s == nil {
+		return true
+	}
+	atomic.AddUint64(&s.scanned, 1)
+	if s.rate < 1 && rand.Float64() >= s.rate {
+		return false
+	}
+	if s.limit > 0 {
+		if s.limitReached() {
+			return false
+		}
+		if success && atomic.AddInt64(&s.successes, 1) > s.limit {
+			return false
+		}
+	}
+	atomic.AddUint64(&s.emitted, 1)
+	return true
+}
+
+// limitReached reports whether --sample-limit successful results have been
+// emitted.
+func (s *sampler) limitReached() bool {
+	return s != nil && s.limit > 0 && atomic.LoadInt64(&s.successes) >= s.limit
+}
+
+// stopScanning reports whether workers can skip the remaining targets.
+func (s *sampler) stopScanning() bool {
+	return config.SampleStop && s.limitReached()
+}
+
+// GetSampleStats returns the scanned / emitted counts, or nil if sampling is
+// not enabled.
+func GetSampleStats() *SampleStats {
+	if resultSampler == nil {
+		return nil
+	}
+	return &SampleStats{
+		Scanned: atomic.LoadUint64(&resultSampler.scanned),
+		Emitted: atomic.LoadUint64(&resultSampler.emitted),
+	}
+}
@@ -1,14 +1,23 @@
 package zgrab2
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"syscall"
 	"time"
 )
 
 var scanners map[string]*Scanner
 var orderedScanners []string
 
+// scanRetries is the --retries of each scanner, by name.
+var scanRetries map[string]int
+
+// retryBackoff is the pause before a scan's first retry, doubled for each
+// retry after it.
+var retryBackoff = 500 * time.Millisecond
+
 // RegisterScan registers each individual scanner to be ran by the framework
 func RegisterScan(name string, s Scanner) {
 	//add to list and map
@@ -19,6 +28,66 @@ func RegisterScan(name string, s Scanner) {
 	scanners[name] = &s
 }
 
+// SetScanRetries records the --retries given in flags, which embed
+// BaseFlags, for s.
+func SetScanRetries(s Scanner, flags ScanFlags) {
+	if base, ok := flags.(interface{ GetRetries() int }); ok {
+		scanRetries[s.GetName()] = base.GetRetries()
+	}
+}
+
+// ClientIdentifier is implemented by scanners that can describe how they
+// present themselves to servers, such as the protocol version and client
+// name they send, so that it is recorded once in the scan summary rather
+// than in every result.
+type ClientIdentifier interface {
+	ClientIdentity() interface{}
+}
+
+// GetClientIdentities returns the client identity of each registered
+// scanner that reports one, by scanner name, or nil if none do.
+func GetClientIdentities() map[string]interface{} {
+	var identities map[string]interface{}
+	for _, name := range orderedScanners {
+		identifier, ok := (*scanners[name]).(ClientIdentifier)
+		if !ok {
+			continue
+		}
+		if identity := identifier.ClientIdentity(); identity != nil {
+			if identities == nil {
+				identities = make(map[string]interface{})
+			}
+			identities[name] = identity
+		}
+	}
+	return identities
+}
+
+// ScanSummarizer is implemented by scanners that aggregate their results
+// over the scan, to be reported once in the summary when it ends.
+type ScanSummarizer interface {
+	ScanSummary() interface{}
+}
+
+// GetScanSummaries returns the aggregate of each registered scanner that
+// reports one, by scanner name, or nil if none do.
+func GetScanSummaries() map[string]interface{} {
+	var summaries map[string]interface{}
+	for _, name := range orderedScanners {
+		summarizer, ok := (*scanners[name]).(ScanSummarizer)
+		if !ok {
+			continue
+		}
+		if summary := summarizer.ScanSummary(); summary != nil {
+			if summaries == nil {
+				summaries = make(map[string]interface{})
+			}
+			summaries[name] = summary
+		}
+	}
+	return summaries
+}
+
 // PrintScanners prints all registered scanners
 func PrintScanners() {
 	for k, v := range scanners {
@@ -26,10 +95,19 @@ func PrintScanners() {
 	}
 }
 
-// RunScanner runs a single scan on a target and returns the resulting data
+// RunScanner runs a single scan on a target and returns the resulting data.
+// A scan that fails with a retryable error is run again, up to the
+// scanner's --retries times.
 func RunScanner(s Scanner, mon *Monitor, target ScanTarget) (string, ScanResponse) {
 	t := time.Now()
+	retries := scanRetries[s.GetName()]
 	status, res, e := s.Scan(target)
+	attempts := 1
+	for backoff := retryBackoff; attempts <= retries && retryable(status, e); backoff *= 2 {
+		time.Sleep(backoff)
+		status, res, e = s.Scan(target)
+		attempts++
+	}
 	var err *string
 	if e == nil {
 		mon.statusesChan <- moduleStatus{name: s.GetName(), st: statusSuccess}
@@ -40,9 +118,31 @@ func RunScanner(s Scanner, mon *Monitor, target ScanTarget) (string, ScanRespons
 		err = &errString
 	}
 	resp := ScanResponse{Result: res, Protocol: s.Protocol(), Error: err, Timestamp: t.Format(time.RFC3339), Status: status}
+	if retries > 0 {
+		resp.Attempts = attempts
+	}
 	return s.GetName(), resp
 }
 
+// retryable reports whether a scan that ended with status and err may
+// succeed if run again: it timed out or its connection was reset.
+// Refused connections, connections the peer closed cleanly and protocol
+// errors are usually deterministic, so are not.
+func retryable(status ScanStatus, err error) bool {
+	if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+		return false
+	}
+	if status == SCAN_UNKNOWN_ERROR {
+		status = TryGetScanStatus(err)
+	}
+	switch status {
+	case SCAN_CONNECTION_TIMEOUT, SCAN_IO_TIMEOUT:
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET)
+}
+
 func init() {
 	scanners = make(map[string]*Scanner)
+	scanRetries = make(map[string]int)
 }
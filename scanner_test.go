@@ -0,0 +1,119 @@
+package zgrab2
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyScanner fails each scan with the next of its errors, then succeeds.
+type flakyScanner struct {
+	errs  []error
+	scans int
+}
+
+func (s *flakyScanner) Init(ScanFlags) error    { return nil }
+func (s *flakyScanner) InitPerSender(int) error { return nil }
+func (s *flakyScanner) GetName() string         { return "flaky" }
+func (s *flakyScanner) GetTrigger() string      { return "" }
+func (s *flakyScanner) Protocol() string        { return "flaky" }
+
+type flakyFlags struct {
+	BaseFlags
+}
+
+func (f *flakyFlags) Help() string            { return "" }
+func (f *flakyFlags) Validate([]string) error { return nil }
+
+func (s *flakyScanner) Scan(ScanTarget) (ScanStatus, interface{}, error) {
+	s.scans++
+	if s.scans > len(s.errs) {
+		return SCAN_SUCCESS, nil, nil
+	}
+	err := s.errs[s.scans-1]
+	return TryGetScanStatus(err), nil, err
+}
+
+func TestRunScannerRetries(t *testing.T) {
+	defer func(backoff time.Duration) { retryBackoff = backoff }(retryBackoff)
+	retryBackoff = 0
+	reset := &ScanError{Status: SCAN_UNKNOWN_ERROR, Err: syscall.ECONNRESET}
+	timeout := &ScanError{Status: SCAN_IO_TIMEOUT, Err: errors.New("i/o timeout")}
+	protocol := &ScanError{Status: SCAN_PROTOCOL_ERROR, Err: errors.New("bad banner")}
+	tests := map[string]struct {
+		retries  int
+		errs     []error
+		status   ScanStatus
+		attempts int
+	}{
+		"no retries":     {errs: []error{timeout}, status: SCAN_IO_TIMEOUT},
+		"recovers":       {retries: 2, errs: []error{reset, timeout}, status: SCAN_SUCCESS, attempts: 3},
+		"gives up":       {retries: 1, errs: []error{timeout, timeout}, status: SCAN_IO_TIMEOUT, attempts: 2},
+		"protocol error": {retries: 2, errs: []error{protocol}, status: SCAN_PROTOCOL_ERROR, attempts: 1},
+		"closed":         {retries: 2, errs: []error{&ScanError{Status: SCAN_CONNECTION_CLOSED, Err: io.EOF}}, status: SCAN_CONNECTION_CLOSED, attempts: 1},
+		"refused":        {retries: 2, errs: []error{&ScanError{Status: SCAN_CONNECTION_TIMEOUT, Err: syscall.ECONNREFUSED}}, status: SCAN_CONNECTION_TIMEOUT, attempts: 1},
+	}
+	for name, test := range tests {
+		var wg sync.WaitGroup
+		mon := MakeMonitor(1, &wg)
+		s := &flakyScanner{errs: test.errs}
+		SetScanRetries(s, &flakyFlags{BaseFlags{Retries: test.retries}})
+		_, res := RunScanner(s, mon, ScanTarget{})
+		mon.Stop()
+		wg.Wait()
+		if res.Status != test.status || res.Attempts != test.attempts {
+			t.Errorf("%s: got %s after %d attempts", name, res.Status, res.Attempts)
+		}
+	}
+}
+
+// identifiedScanner is a flakyScanner that reports a client identity.
+type identifiedScanner struct {
+	flakyScanner
+}
+
+func (s *identifiedScanner) GetName() string             { return "identified" }
+func (s *identifiedScanner) ClientIdentity() interface{} { return "client/1.0" }
+
+func TestGetClientIdentities(t *testing.T) {
+	defer func(saved map[string]*Scanner, ordered []string) {
+		scanners, orderedScanners = saved, ordered
+	}(scanners, orderedScanners)
+	scanners, orderedScanners = make(map[string]*Scanner), nil
+	if identities := GetClientIdentities(); identities != nil {
+		t.Errorf("no scanners: got %v", identities)
+	}
+	RegisterScan("flaky", new(flakyScanner))
+	RegisterScan("identified", new(identifiedScanner))
+	identities := GetClientIdentities()
+	if len(identities) != 1 || identities["identified"] != "client/1.0" {
+		t.Errorf("got %v", identities)
+	}
+}
+
+// summarizingScanner is a flakyScanner that reports a scan summary.
+type summarizingScanner struct {
+	flakyScanner
+}
+
+func (s *summarizingScanner) GetName() string          { return "summarizing" }
+func (s *summarizingScanner) ScanSummary() interface{} { return map[string]int{"servers": 2} }
+
+func TestGetScanSummaries(t *testing.T) {
+	defer func(saved map[string]*Scanner, ordered []string) {
+		scanners, orderedScanners = saved, ordered
+	}(scanners, orderedScanners)
+	scanners, orderedScanners = make(map[string]*Scanner), nil
+	if summaries := GetScanSummaries(); summaries != nil {
+		t.Errorf("no scanners: got %v", summaries)
+	}
+	RegisterScan("flaky", new(flakyScanner))
+	RegisterScan("summarizing", new(summarizingScanner))
+	summaries := GetScanSummaries()
+	if summary, ok := summaries["summarizing"].(map[string]int); len(summaries) != 1 || !ok || summary["servers"] != 2 {
+		t.Errorf("got %v", summaries)
+	}
+}
@@ -24,6 +24,7 @@ const (
 	SCAN_PROTOCOL_ERROR     = ScanStatus("protocol-error")     // Received data incompatible with the target protocol
 	SCAN_APPLICATION_ERROR  = ScanStatus("application-error")  // The application reported an error
 	SCAN_UNKNOWN_ERROR      = ScanStatus("unknown-error")      // Catch-all for unrecognized errors
+	SCAN_SKIPPED            = ScanStatus("skipped")            // The target was deliberately not scanned (e.g. it is in an excluded range)
 )
 
 // ScanError an error that also includes a ScanStatus.
@@ -40,6 +41,11 @@ func (err *ScanError) Error() string {
 	return err.Err.Error()
 }
 
+// Unwrap returns the wrapped error, so that errors.Is and errors.As see it
+func (err *ScanError) Unwrap() error {
+	return err.Err
+}
+
 func (err *ScanError) Unpack(results interface{}) (ScanStatus, interface{}, error) {
 	return err.Status, results, err.Err
 }
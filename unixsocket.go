@@ -0,0 +1,183 @@
+package zgrab2
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// unixSocketBuffer is how many results are held while no consumer is
+	// connected to --unix-socket.
+	unixSocketBuffer = 4096
+
+	// unixSocketDrainTimeout bounds how long shutdown waits for a consumer
+	// to read the results still buffered.
+	unixSocketDrainTimeout = 5 * time.Second
+)
+
+// Policies accepted by --unix-socket-policy.
+const (
+	UnixSocketPolicyDrop  = "drop"
+	UnixSocketPolicyBlock = "block"
+)
+
+// unixSocketOutput streams results, one JSON object per line, to a local
+// consumer connected to a Unix domain socket. One consumer is served at a
+// time; when it disconnects, the next one to connect picks up where it left
+// off.
+type unixSocketOutput struct {
+	path     string
+	listener net.Listener
+	queue    chan []byte
+	block    bool
+	dropped  uint64
+	done     chan struct{}
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// newUnixSocketOutput listens on path, replacing a stale socket left there
+// by an earlier run. If block is set, results wait for a consumer once the
+// buffer is full; otherwise they are dropped.
+func newUnixSocketOutput(path string, block bool) (*unixSocketOutput, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	u := &unixSocketOutput{
+		path:     path,
+		listener: listener,
+		queue:    make(chan []byte, unixSocketBuffer),
+		block:    block,
+		done:     make(chan struct{}),
+	}
+	go u.serve()
+	return u, nil
+}
+
+func (u *unixSocketOutput) serve() {
+	defer close(u.done)
+	var pending []byte
+	for {
+		conn, err := u.listener.Accept()
+		if err != nil {
+			return
+		}
+		u.mu.Lock()
+		u.conn = conn
+		u.mu.Unlock()
+		var closed bool
+		pending, closed = u.drain(conn, pending)
+		conn.Close()
+		if closed {
+			return
+		}
+	}
+}
+
+// drain writes queued results to conn until the queue is closed, which it
+// reports, or the consumer goes away. A result that could not be written is
+// returned so it can go to the next consumer.
+func (u *unixSocketOutput) drain(conn net.Conn, pending []byte) ([]byte, bool) {
+	w := bufio.NewWriter(conn)
+	for {
+		result := pending
+		if result == nil {
+			var ok bool
+			if result, ok = <-u.queue; !ok {
+				w.Flush()
+				return nil, true
+			}
+		}
+		// result is shared with the other outputs, so it must not be
+		// appended to.
+		if _, err := w.Write(result); err != nil {
+			return result, false
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return result, false
+		}
+		pending = nil
+		if len(u.queue) == 0 {
+			if err := w.Flush(); err != nil {
+				// The consumer went away; what was buffered is lost.
+				return nil, false
+			}
+		}
+	}
+}
+
+func (u *unixSocketOutput) send(result []byte) {
+	if u.block {
+		u.queue <- result
+		return
+	}
+	select {
+	case u.queue <- result:
+	default:
+		atomic.AddUint64(&u.dropped, 1)
+	}
+}
+
+// Close stops accepting results, gives a connected consumer a few seconds
+// to read what is left, and removes the socket. If no consumer ever
+// connected, there is no one to wait for.
+func (u *unixSocketOutput) Close() {
+	close(u.queue)
+	u.mu.Lock()
+	connected := u.conn != nil
+	u.mu.Unlock()
+	if connected {
+		select {
+		case <-u.done:
+		case <-time.After(unixSocketDrainTimeout):
+			log.Warnf("unix socket %s: %d results not read before shutdown", u.path, len(u.queue))
+		}
+	} else if n := len(u.queue); n > 0 {
+		log.Warnf("unix socket %s: %d results not read; no consumer connected", u.path, n)
+	}
+	u.listener.Close()
+	u.mu.Lock()
+	if u.conn != nil {
+		u.conn.Close()
+	}
+	u.mu.Unlock()
+	<-u.done
+	os.Remove(u.path)
+	if dropped := atomic.LoadUint64(&u.dropped); dropped > 0 {
+		log.Warnf("unix socket %s: dropped %d results while no consumer was reading", u.path, dropped)
+	}
+}
+
+// teeUnixSocket returns an OutputResultsFunc that copies every result to u
+// as well as passing it on to next.
+func teeUnixSocket(next OutputResultsFunc, u *unixSocketOutput) OutputResultsFunc {
+	return func(results <-chan []byte) error {
+		forward := make(chan []byte)
+		errc := make(chan error, 1)
+		go func() {
+			errc <- next(forward)
+		}()
+		defer u.Close()
+		for result := range results {
+			u.send(result)
+			select {
+			case forward <- result:
+			case err := <-errc:
+				return err
+			}
+		}
+		close(forward)
+		return <-errc
+	}
+}
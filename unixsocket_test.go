@@ -0,0 +1,80 @@
+package zgrab2
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUnixSocketOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.sock")
+	sock, err := newUnixSocketOutput(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var file []string
+	output := teeUnixSocket(func(results <-chan []byte) error {
+		for result := range results {
+			file = append(file, string(result))
+		}
+		return nil
+	}, sock)
+
+	results := make(chan []byte)
+	done := make(chan error)
+	go func() {
+		done <- output(results)
+	}()
+	results <- []byte(`{"ip":"192.0.2.1"}`)
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Read the first result before shutting down, so the consumer is known
+	// to be connected and Close waits for it to drain.
+	var consumed []string
+	scanner := bufio.NewScanner(conn)
+	if scanner.Scan() {
+		consumed = append(consumed, scanner.Text())
+	}
+	results <- []byte(`{"ip":"192.0.2.2"}`)
+	close(results)
+
+	for scanner.Scan() {
+		consumed = append(consumed, scanner.Text())
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{`{"ip":"192.0.2.1"}`, `{"ip":"192.0.2.2"}`}
+	for name, got := range map[string][]string{"file": file, "socket": consumed} {
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("%s output: got %q, wanted %q", name, got, want)
+		}
+	}
+}
+
+func TestUnixSocketOutputNoConsumer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.sock")
+	u, err := newUnixSocketOutput(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.send([]byte(`{"ip":"192.0.2.1"}`))
+	// With no consumer to drain to, shutdown must not wait for one.
+	start := time.Now()
+	u.Close()
+	if elapsed := time.Since(start); elapsed >= unixSocketDrainTimeout {
+		t.Errorf("Close took %v with no consumer", elapsed)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket not removed: %v", err)
+	}
+}
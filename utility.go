@@ -49,6 +49,16 @@ func AddCommand(command string, shortDescription string, longDescription string,
 	return cmd, nil
 }
 
+// RegisterPlugin adds the plugin's command to the parser, as AddCommand does
+// for modules that describe themselves at the call site. It is meant to be
+// called from init(), and exits if the command cannot be added (e.g. because
+// the name is taken).
+func RegisterPlugin(p Plugin) {
+	if _, err := AddCommand(p.Command(), p.ShortDescription(), p.Description(), p.DefaultPort(), p); err != nil {
+		logrus.Fatalf("could not register module %s: %v", p.Command(), err)
+	}
+}
+
 // ParseCommandLine parses the commands given on the command line
 // and validates the framework configuration (global options)
 // immediately after parsing
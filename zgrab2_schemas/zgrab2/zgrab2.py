@@ -34,6 +34,9 @@ grab_result = Record(
             required=False, doc="The domain name of the target, if available."
         ),
         "data": SubRecord(scan_response_types, doc="The scan data for this host."),
+        "scan_seq": Signed64BitInteger(
+            required=False, doc="The order in which this result was output; only present with --seq."
+        ),
     }
 )
 
@@ -47,6 +50,7 @@ STATUS_VALUES = [
     "protocol-error",
     "application-error",
     "unknown-error",
+    "skipped",
 ]
 
 # zgrab2/module.go: ScanResponse
@@ -62,6 +66,9 @@ base_scan_response = SubRecord(
             required=False,
             doc="If the status was not success, error may contain information about the failure.",
         ),
+        "attempts": Signed32BitInteger(
+            required=False, doc="The number of times the scan was run; only present with --retries."
+        ),
         # TODO: error_component? domain?
     }
 )